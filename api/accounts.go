@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Hamaiz/go-rest-eg/email"
 	"github.com/Hamaiz/go-rest-eg/helper"
@@ -12,7 +14,6 @@ import (
 	"github.com/dchest/uniuri"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // AccountStore - account store interface
@@ -21,6 +22,7 @@ type AccountStore interface {
 	AlreadyLoggedIn(r *http.Request) bool
 	CleanSession(w http.ResponseWriter, r *http.Request) error
 	SaveSession(w http.ResponseWriter, r *http.Request, id string) error
+	SessionID(r *http.Request) (string, error)
 }
 
 // AccountDatabase - hold database functions
@@ -31,21 +33,56 @@ type AccountDatabase interface {
 	GetUserInLogin(email string) (model.UserGet, error)
 	LoginConfirm(e string) bool
 	ConfirmEmail(t string) (bool, error)
-	GetToken(e string) (string, error)
+	EmailToken(e string, hash string) error
 	ForgotToken(e string, token string) error
 	ConfirmToken(token string) (bool, error)
 	ResetPass(p string, t string) error
+	GetPassword(id string) (string, error)
+	SaveTOTPSecret(id string, secret string) error
+	GetTOTP(id string) (model.TOTP, error)
+	UseTOTPStep(id string, step int64) (bool, error)
+	ConfirmTOTP(id string, hashes []string) error
+	SaveRecoveryCodes(id string, hashes []string) error
+	UseRecoveryCode(id string, hash string) (bool, error)
+	DeleteTOTP(id string) error
+	CreateChallenge(id string, hash string, expires time.Time) error
+	UseChallenge(hash string, maxAttempts int) (string, error)
+	DeleteChallenge(hash string) error
+	Locked(scope string, key string) (time.Duration, error)
+	Failed(scope string, key string) (time.Duration, bool, error)
+	Succeeded(scope string, key string) error
+	LockoutAddress(key string) (string, error)
+	ChangePassword(id string, p string) error
+	RehashPassword(id string, old string, p string) error
+	RevokeOtherSessions(id string, keep string) error
+	EmailChangeToken(id string, e string, hash string, expires time.Time) error
+	ConfirmEmailChange(hash string) (string, error)
+	RequestDeletion(id string, purgeAfter time.Time) error
+	CancelDeletion(id string) (bool, error)
+	ExportAccount(id string) (model.AccountExport, error)
+	ActiveBan(id string) (model.Ban, error)
+	MagicLinkEnabled() (bool, error)
+	MagicLinkToken(id string, hash string, browser string, expires time.Time) error
+	UseMagicLink(hash string, browser string) (string, error)
+}
+
+// PasswordHasher - hashes and verifies passwords
+type PasswordHasher interface {
+	Hash(p string) (string, error)
+	Verify(hash string, p string) (bool, bool)
 }
 
 // Account - account store struct
 type Account struct {
-	store AccountStore
-	conn  AccountDatabase
+	store     AccountStore
+	conn      AccountDatabase
+	passwords PasswordHasher
+	login     *Logins
 }
 
 // NewAccountStore - creates new store
-func NewAccountStore(s AccountStore, c AccountDatabase) *Account {
-	return &Account{s, c}
+func NewAccountStore(s AccountStore, c AccountDatabase, h PasswordHasher) *Account {
+	return &Account{s, c, h, NewLoginApi(s, c)}
 }
 
 // SignUpHandler - signing in route - @POST - /account/signup
@@ -63,7 +100,7 @@ func (s *Account) SignUpHandler(w http.ResponseWriter, r *http.Request) {
 
 	// getting form value
 	n := r.FormValue("name")
-	e := r.FormValue("email")
+	e := strings.TrimSpace(r.FormValue("email"))
 	p := r.FormValue("password")
 
 	// if empty return error
@@ -72,6 +109,11 @@ func (s *Account) SignUpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !validEmail(e) {
+		helper.ASM(w, 403, "email is not valid")
+		return
+	}
+
 	// checking if already exists
 	check := s.conn.CheckingExists(e)
 	if check {
@@ -79,8 +121,8 @@ func (s *Account) SignUpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// bcrypt password - hashing
-	bs, err := bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost)
+	// password - hashing
+	hash, err := s.passwords.Hash(p)
 	if err != nil {
 		helper.ASM(w, 500, "")
 		return
@@ -97,10 +139,10 @@ func (s *Account) SignUpHandler(w http.ResponseWriter, r *http.Request) {
 	url := host + "account/confirm/" + token
 
 	// user model
-	u := model.User{n, e, unique, string(bs)}
+	u := model.User{n, e, unique, hash}
 
 	// inser user to database
-	err = s.conn.InsertUser(u, token)
+	err = s.conn.InsertUser(u, helper.HashToken(token))
 
 	if err != nil {
 		helper.ASM(w, 500, "")
@@ -140,9 +182,18 @@ func (s *Account) LogInHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// failed logins are counted per email and per ip
+	byEmail := attempt{loginEmail, emailKey(e)}
+	byIP := attempt{loginIP, helper.ClientIP(r)}
+
+	if s.locked(w, byEmail, byIP) {
+		return
+	}
+
 	u, err := s.conn.GetUserInLogin(e)
 	switch {
 	case err == pgx.ErrNoRows:
+		s.failed(byIP)
 		helper.ASM(w, 404, "email not found")
 		return
 	case err != nil:
@@ -156,21 +207,34 @@ func (s *Account) LogInHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Bcrypt Pasword - Decrypting
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(p))
-	if err != nil {
+	// Pasword - comparing with the hash
+	ok, rehash := s.passwords.Verify(u.Password, p)
+	if !ok {
+		s.failed(byEmail, byIP)
 		helper.ASM(w, 404, "Email and/or password do not match")
 		return
 	}
 
-	// Create New Session
-	err = s.store.SaveSession(w, r, u.ID)
-	if err != nil {
-		helper.ASM(w, 500, "")
-		return
+	s.succeeded(byEmail)
+
+	// hashes with an old algorithm or parameters are replaced
+	if rehash {
+		s.rehash(u.ID, u.Password, p)
+	}
+
+	s.login.finish(w, r, u.ID, "logged in successfully")
+}
+
+// rehash - replaces the old hash with the current one, the login goes on when it fails
+func (s *Account) rehash(id string, old string, p string) {
+	hash, err := s.passwords.Hash(p)
+	if err == nil {
+		err = s.conn.RehashPassword(id, old, hash)
 	}
 
-	helper.ASM(w, 200, "logged in successfully")
+	if err != nil {
+		log.Println("could not rehash password:", err)
+	}
 }
 
 // LogoutHandler - logs user out & removes cookie - @DELETE - /account/logout
@@ -228,6 +292,7 @@ func (s *Account) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		u.Avatar = avatarURL(u.AvatarKey, u.UnqiueName)
 		json.NewEncoder(w).Encode(u)
 		return
 	case "OPTIONS":
@@ -259,7 +324,7 @@ func (s *Account) ConfirmEmailHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		confirm, err := s.conn.ConfirmEmail(token)
+		confirm, err := s.conn.ConfirmEmail(helper.HashToken(token))
 
 		if err != nil {
 			helper.ASM(w, 403, err.Error())
@@ -303,6 +368,16 @@ func (s *Account) ForgotHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// every request counts, so emails can't be spammed at anyone
+	byEmail := attempt{forgotEmail, emailKey(e)}
+	byIP := attempt{forgotIP, helper.ClientIP(r)}
+
+	if s.locked(w, byEmail, byIP) {
+		return
+	}
+
+	s.failed(byEmail, byIP)
+
 	// check if user exist
 	c := s.conn.CheckingExists(e)
 	if !c {
@@ -318,7 +393,7 @@ func (s *Account) ForgotHandler(w http.ResponseWriter, r *http.Request) {
 	url := host + "account/confirm-pass/" + token
 
 	// inser user to database
-	err := s.conn.ForgotToken(e, token)
+	err := s.conn.ForgotToken(e, helper.HashToken(token))
 	if err != nil {
 		helper.ASM(w, 422, "an error occured")
 		return
@@ -347,8 +422,15 @@ func (s *Account) ConfirmPassHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		c, err := s.conn.ConfirmToken(t)
+		// guessing tokens is limited per ip
+		byIP := attempt{resetIP, helper.ClientIP(r)}
+		if s.locked(w, byIP) {
+			return
+		}
+
+		c, err := s.conn.ConfirmToken(helper.HashToken(t))
 		if err != nil {
+			s.failed(byIP)
 			helper.ASM(w, 422, err.Error())
 			return
 		}
@@ -377,6 +459,12 @@ func (s *Account) ResetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// guessing tokens is limited per ip
+	byIP := attempt{resetIP, helper.ClientIP(r)}
+	if (r.Method == "POST" || r.Method == "PUT") && s.locked(w, byIP) {
+		return
+	}
+
 	switch r.Method {
 	case "POST":
 		// Form value
@@ -387,8 +475,9 @@ func (s *Account) ResetHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		c, err := s.conn.ConfirmToken(t)
+		c, err := s.conn.ConfirmToken(helper.HashToken(t))
 		if err != nil {
+			s.failed(byIP)
 			helper.ASM(w, 422, err.Error())
 			return
 		}
@@ -415,8 +504,9 @@ func (s *Account) ResetHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		c, err := s.conn.ConfirmToken(t)
+		c, err := s.conn.ConfirmToken(helper.HashToken(t))
 		if err != nil {
+			s.failed(byIP)
 			helper.ASM(w, 422, err.Error())
 			return
 		}
@@ -426,13 +516,13 @@ func (s *Account) ResetHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		bs, errs := bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost)
+		hash, errs := s.passwords.Hash(p)
 		if errs != nil {
 			helper.ASM(w, 500, "")
 			return
 		}
 
-		err = s.conn.ResetPass(string(bs), t)
+		err = s.conn.ResetPass(hash, helper.HashToken(t))
 		if err != nil {
 			helper.ASM(w, 403, err.Error())
 			return
@@ -460,15 +550,17 @@ func (s *Account) EmailAgain(w http.ResponseWriter, r *http.Request) {
 	e := r.FormValue("email")
 	n := r.FormValue("name")
 
-	// get token from database
-	token, err := s.conn.GetToken(e)
+	// a new link, the ones sent before keep working
+	token := uniuri.NewLen(50)
+
+	err := s.conn.EmailToken(e, helper.HashToken(token))
 	if err != nil {
 		helper.ASM(w, 404, err.Error())
 		return
 	}
 
 	// email url
-	url := "http://localhost:9002/account/confirm/" + token
+	url := os.Getenv("URL") + "account/confirm/" + token
 
 	err = email.SignUpEmail(e, n, url)
 	if err != nil {
@@ -0,0 +1,465 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/email"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/dchest/uniuri"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// AdminDatabase - user management functions
+type AdminDatabase interface {
+	SearchUsers(q string, limit int, offset int) (model.UserPage, error)
+	GetAdminUser(id string) (model.AdminUser, error)
+	GetProfile(id string) (model.AdminProfile, error)
+	Ban(id string, b model.Ban, actor string) error
+	Unban(id string) error
+	ForceVerify(id string) error
+	ForceReset(id string, hash string, expires time.Time) error
+	DeleteUser(id string) error
+	GetSettings() (map[string]bool, error)
+	SetSetting(name string, value bool) error
+}
+
+// AuditLog - records and lists staff actions, and knows the role of the staff
+type AuditLog interface {
+	GetRole(id string) (string, error)
+	Audit(actor string, action string, targetType string, targetID string, detail string) error
+	GetAuditLog(limit int, offset int) ([]model.AuditEntry, error)
+}
+
+// Admin - admin struct
+type Admin struct {
+	store AccountStore
+	conn  AdminDatabase
+	log   AuditLog
+}
+
+// NewAdminApi - creates new admin api
+func NewAdminApi(s AccountStore, c AdminDatabase, l AuditLog) *Admin {
+	return &Admin{s, c, l}
+}
+
+// maxBanReason - longest reason of a suspension or ban
+const maxBanReason = 500
+
+// page - limit and offset query values, limit defaults to def and is capped at max
+func page(r *http.Request, def int, max int) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = def
+	}
+	if limit > max {
+		limit = max
+	}
+
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+// banned - tells the user why and until when they can't log in
+func banned(w http.ResponseWriter, b model.Ban) {
+	msg := "this account is banned"
+	if b.Kind == model.BanSuspend {
+		msg = "this account is suspended"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "403",
+		"message":   msg,
+		"reason":    b.Reason,
+		"expiresAt": b.ExpiresAt,
+	})
+}
+
+// target - user of the route, writes 404 when there is none
+func (a *Admin) target(w http.ResponseWriter, r *http.Request) (model.AdminUser, bool) {
+	u, err := a.conn.GetAdminUser(mux.Vars(r)["id"])
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "user not found")
+		return u, false
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return u, false
+	}
+
+	return u, true
+}
+
+// actor - staff member of the request, who can only act on users with a lower role
+func (a *Admin) actor(w http.ResponseWriter, r *http.Request, target model.AdminUser) (string, bool) {
+	id, err := a.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return "", false
+	}
+
+	if id == target.ID {
+		helper.ASM(w, 403, "you can't do this to your own account")
+		return "", false
+	}
+
+	role, err := a.log.GetRole(id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return "", false
+	}
+
+	if !model.Outranks(role, target.Role) {
+		helper.ASM(w, 403, "you can't do this to a user whose role isn't lower than yours")
+		return "", false
+	}
+
+	return id, true
+}
+
+// audit - records the action, the action already happened so errors are only logged
+func (a *Admin) audit(actor string, action string, target string, detail string) {
+	if err := a.log.Audit(actor, action, "account", target, detail); err != nil {
+		log.Println("could not write audit log:", err)
+	}
+}
+
+// UsersHandler - lists and searches users - @GET | @OPTIONS - /admin/users
+func (a *Admin) UsersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		limit, offset := page(r, 50, 200)
+
+		p, err := a.conn.SearchUsers(r.URL.Query().Get("q"), limit, offset)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		json.NewEncoder(w).Encode(p)
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// UserHandler - profile or hard delete of a user - @GET | @DELETE | @OPTIONS - /admin/users/:id
+func (a *Admin) UserHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		p, err := a.conn.GetProfile(mux.Vars(r)["id"])
+		switch {
+		case err == pgx.ErrNoRows:
+			helper.ASM(w, 404, "user not found")
+			return
+		case err != nil:
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		json.NewEncoder(w).Encode(p)
+	case "DELETE":
+		u, ok := a.target(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := a.actor(w, r, u)
+		if !ok {
+			return
+		}
+
+		err := a.conn.DeleteUser(u.ID)
+		switch {
+		case err == pgx.ErrNoRows:
+			helper.ASM(w, 404, "user not found")
+			return
+		case err != nil:
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		a.audit(id, "delete-user", u.ID, u.Email)
+
+		helper.ASM(w, 200, "user deleted")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// SuspendHandler - suspends user for a duration - @POST | @OPTIONS - /admin/users/:id/suspend
+func (a *Admin) SuspendHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		reason := r.FormValue("reason")
+		if len(reason) > maxBanReason {
+			helper.ASM(w, 422, "reason is too long")
+			return
+		}
+
+		d, err := time.ParseDuration(r.FormValue("duration"))
+		if err != nil || d <= 0 {
+			helper.ASM(w, 422, "duration is invalid")
+			return
+		}
+
+		exp := time.Now().Add(d)
+		a.ban(w, r, model.Ban{Kind: model.BanSuspend, Reason: reason, ExpiresAt: &exp})
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// BanHandler - bans or unbans user - @POST | @DELETE | @OPTIONS - /admin/users/:id/ban
+func (a *Admin) BanHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		reason := r.FormValue("reason")
+		if reason == "" {
+			helper.ASM(w, 422, "reason is empty")
+			return
+		}
+
+		if len(reason) > maxBanReason {
+			helper.ASM(w, 422, "reason is too long")
+			return
+		}
+
+		// without expiry the ban lasts until it's lifted
+		b := model.Ban{Kind: model.BanBan, Reason: reason}
+		if e := r.FormValue("expires"); e != "" {
+			exp, err := time.Parse(time.RFC3339, e)
+			if err != nil || !exp.After(time.Now()) {
+				helper.ASM(w, 422, "expires must be a future RFC3339 time")
+				return
+			}
+			b.ExpiresAt = &exp
+		}
+
+		a.ban(w, r, b)
+	case "DELETE":
+		u, ok := a.target(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := a.actor(w, r, u)
+		if !ok {
+			return
+		}
+
+		err := a.conn.Unban(u.ID)
+		switch {
+		case err == pgx.ErrNoRows:
+			helper.ASM(w, 404, "user isn't banned")
+			return
+		case err != nil:
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		a.audit(id, "unban", u.ID, "")
+
+		helper.ASM(w, 200, "user unbanned")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// ban - saves suspension or ban of the route user
+func (a *Admin) ban(w http.ResponseWriter, r *http.Request, b model.Ban) {
+	u, ok := a.target(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := a.actor(w, r, u)
+	if !ok {
+		return
+	}
+
+	err := a.conn.Ban(u.ID, b, id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	detail := b.Reason
+	if b.ExpiresAt != nil {
+		detail += " (until " + b.ExpiresAt.UTC().Format(time.RFC3339) + ")"
+	}
+	a.audit(id, b.Kind, u.ID, detail)
+
+	if b.Kind == model.BanSuspend {
+		helper.ASM(w, 200, "user suspended")
+		return
+	}
+
+	helper.ASM(w, 200, "user banned")
+}
+
+// VerifyEmailHandler - confirms email of user - @POST | @OPTIONS - /admin/users/:id/verify-email
+func (a *Admin) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		u, ok := a.target(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := a.store.GetUser(r)
+		if err != nil {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		if err := a.conn.ForceVerify(u.ID); err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		a.audit(id, "verify-email", u.ID, u.Email)
+
+		helper.ASM(w, 200, "email verified")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// ResetPasswordHandler - removes password of user and emails a reset link - @POST | @OPTIONS - /admin/users/:id/reset-password
+func (a *Admin) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		u, ok := a.target(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := a.actor(w, r, u)
+		if !ok {
+			return
+		}
+
+		// same link as ForgotHandler sends
+		token := uniuri.NewLen(50)
+		url := os.Getenv("URL") + "account/confirm-pass/" + token
+
+		err := a.conn.ForceReset(u.ID, helper.HashToken(token), time.Now().Add(6*time.Hour))
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		a.audit(id, "reset-password", u.ID, "")
+
+		err = email.ForgotEmail(u.Email, url)
+		if err != nil {
+			helper.ASM(w, 500, "an error occured while sending email")
+			return
+		}
+
+		helper.ASM(w, 200, "password removed, reset link sent")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// SettingsHandler - current settings - @GET | @OPTIONS - /admin/settings
+func (a *Admin) SettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		ss, err := a.conn.GetSettings()
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		json.NewEncoder(w).Encode(ss)
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// SettingHandler - turns a setting on or off - @PUT | @OPTIONS - /admin/settings/:name
+func (a *Admin) SettingHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "PUT":
+		name := mux.Vars(r)["name"]
+		if _, ok := model.Settings[name]; !ok {
+			helper.ASM(w, 404, "setting not found")
+			return
+		}
+
+		on, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			helper.ASM(w, 422, "enabled must be true or false")
+			return
+		}
+
+		id, err := a.store.GetUser(r)
+		if err != nil {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		if err := a.conn.SetSetting(name, on); err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		if err := a.log.Audit(id, "set-setting", "setting", name, strconv.FormatBool(on)); err != nil {
+			log.Println("could not write audit log:", err)
+		}
+
+		helper.ASM(w, 200, "setting saved")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// AuditLogHandler - staff actions, newest first - @GET | @OPTIONS - /admin/audit-log
+func (a *Admin) AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		limit, offset := page(r, 50, 200)
+
+		es, err := a.log.GetAuditLog(limit, offset)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		json.NewEncoder(w).Encode(es)
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/gorilla/mux"
+)
+
+func (f fakeRoles) GetAuditLog(limit int, offset int) ([]model.AuditEntry, error) {
+	return nil, nil
+}
+
+// fakeAdmins - AdminDatabase of users with fixed roles that records bans
+type fakeAdmins struct {
+	AdminDatabase
+	roles  fakeRoles
+	banned []string
+}
+
+func (f *fakeAdmins) GetAdminUser(id string) (model.AdminUser, error) {
+	role, err := f.roles.GetRole(id)
+	return model.AdminUser{ID: id, Role: role}, err
+}
+
+func (f *fakeAdmins) Ban(id string, b model.Ban, actor string) error {
+	f.banned = append(f.banned, id)
+	return nil
+}
+
+func TestBanRoles(t *testing.T) {
+	roles := fakeRoles{
+		"admin":  model.RoleAdmin,
+		"admin2": model.RoleAdmin,
+		"mod":    model.RoleModerator,
+		"mod2":   model.RoleModerator,
+		"user":   model.RoleUser,
+	}
+
+	tests := []struct {
+		name   string
+		actor  string
+		target string
+		code   int
+	}{
+		{"moderator bans user", "mod", "user", 200},
+		{"moderator bans moderator", "mod", "mod2", 403},
+		{"moderator bans admin", "mod", "admin", 403},
+		{"admin bans moderator", "admin", "mod", 200},
+		{"admin bans admin", "admin", "admin2", 403},
+		{"admin bans themselves", "admin", "admin", 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeAdmins{roles: roles}
+			a := NewAdminApi(&fakeStore{user: tt.actor}, db, roles)
+
+			r := httptest.NewRequest("POST", "/admin/users/"+tt.target+"/ban", nil)
+			r.Form = url.Values{"reason": {"spam"}}
+
+			w := httptest.NewRecorder()
+			a.BanHandler(w, mux.SetURLVars(r, map[string]string{"id": tt.target}))
+
+			if w.Code != tt.code {
+				t.Errorf("ban = %d %s, want %d", w.Code, w.Body, tt.code)
+			}
+			if got := len(db.banned) == 1; got != (tt.code == 200) {
+				t.Errorf("banned %v", db.banned)
+			}
+		})
+	}
+}
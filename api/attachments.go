@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/google/uuid"
+)
+
+// attachment urls work for a day, pages are expected to be fetched again by then
+const attachmentURLTTL = 24 * time.Hour
+
+// AttachmentLimits - largest upload, bytes every user may keep and attachments per post
+type AttachmentLimits struct {
+	MaxSize    int64
+	Quota      int64
+	MaxPerPost int
+}
+
+// attachmentTypes - content types sniffed from uploads that are accepted
+var attachmentTypes = map[string]bool{
+	"image/jpeg":                true,
+	"image/png":                 true,
+	"image/gif":                 true,
+	"image/webp":                true,
+	"application/pdf":           true,
+	"application/zip":           true,
+	"text/plain; charset=utf-8": true,
+}
+
+// sign - fills the urls of the attachments
+func (m *Media) sign(as []model.Attachment) {
+	for i := range as {
+		u, err := m.storage.SignedURL(as[i].BlobKey, attachmentURLTTL)
+		if err != nil {
+			log.Println("signing attachment url:", err)
+			continue
+		}
+		as[i].URL = u
+	}
+}
+
+// attachmentIDs - ids of the "attachments" form values, comma separated or repeated
+func (m *Media) attachmentIDs(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	r.FormValue("attachments")
+
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, v := range r.Form["attachments"] {
+		for _, id := range strings.Split(v, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > m.limits.MaxPerPost {
+		helper.ASM(w, 422, "too many attachments")
+		return nil, false
+	}
+
+	return ids, true
+}
+
+// UploadAttachmentHandler - uploads a file to attach to a question or answer - @POST | @OPTIONS - /api/attachments
+// the multipart "file" field is stored and its id is sent back, uploads never attached are deleted after a day
+func (m *Media) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		if !m.store.AlreadyLoggedIn(r) {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		id, err := m.store.GetUser(r)
+		if err != nil {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		used, err := m.conn.AttachmentUsage(id)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		max := m.limits.MaxSize
+		if room := m.limits.Quota - used; room < max {
+			max = room
+		}
+
+		if max <= 0 {
+			helper.ASM(w, 422, model.ErrQuota.Error())
+			return
+		}
+
+		// room for the multipart headers around the file
+		r.Body = http.MaxBytesReader(w, r.Body, max+1<<20)
+
+		mr, err := r.MultipartReader()
+		if err != nil {
+			helper.ASM(w, 422, "send the file as multipart form data")
+			return
+		}
+
+		// the file is streamed to storage instead of being held in memory
+		var part io.ReadCloser
+		var name string
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				helper.ASM(w, 422, "file is missing")
+				return
+			}
+
+			if p.FormName() == "file" {
+				part, name = p, p.FileName()
+				break
+			}
+		}
+		defer part.Close()
+
+		name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+		if name == "." || name == "/" || name == "" {
+			name = "attachment"
+		}
+		if len(name) > 255 {
+			name = name[len(name)-255:]
+		}
+
+		br := bufio.NewReader(part)
+		head, _ := br.Peek(512)
+		if len(head) == 0 {
+			helper.ASM(w, 422, "file is empty")
+			return
+		}
+
+		// the client's content type is ignored, it could be anything
+		ct := http.DetectContentType(head)
+		if !attachmentTypes[ct] {
+			helper.ASM(w, 422, "file type is not allowed")
+			return
+		}
+
+		up, err := storage.Spool(br, max)
+		switch {
+		case err == storage.ErrTooLarge && max < m.limits.MaxSize:
+			helper.ASM(w, 422, model.ErrQuota.Error())
+			return
+		case err == storage.ErrTooLarge:
+			helper.ASM(w, 422, "file is too large")
+			return
+		case err != nil:
+			log.Println("storing attachment:", err)
+			helper.ASM(w, 500, "")
+			return
+		}
+		defer up.Close()
+
+		a := model.Attachment{
+			ID:          uuid.New().String(),
+			Filename:    name,
+			ContentType: ct,
+			Size:        up.Size,
+			CreatedAt:   time.Now().UTC(),
+			Owner:       id,
+			BlobKey:     up.Key,
+		}
+
+		// the quota is checked again with the uploads of the user locked
+		err = m.conn.AddAttachment(a, m.limits.Quota, m.storage, func() error {
+			return up.Put(r.Context(), m.storage, ct)
+		})
+		switch {
+		case err == model.ErrQuota:
+			helper.ASM(w, 422, err.Error())
+			return
+		case err != nil:
+			log.Println("storing attachment:", err)
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		as := []model.Attachment{a}
+		m.sign(as)
+
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(as[0])
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// attachError - writes the error of posting with attachments
+func attachError(w http.ResponseWriter, err error) {
+	if err == model.ErrAttachment {
+		helper.ASM(w, 422, err.Error())
+		return
+	}
+
+	helper.ASM(w, 500, "")
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Hamaiz/go-rest-eg/email"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/jackc/pgx/v4"
+)
+
+// attempt scopes - failures are counted per scope and key
+const (
+	loginEmail  = "login-email"
+	loginIP     = "login-ip"
+	forgotEmail = "forgot-email"
+	forgotIP    = "forgot-ip"
+	resetIP     = "reset-ip"
+	magicEmail  = "magic-email"
+	magicIP     = "magic-ip"
+)
+
+// attempt - scope and key a failure is counted for
+type attempt struct {
+	scope string
+	key   string
+}
+
+// emailKey - same email in any case is the same key
+func emailKey(e string) string {
+	return strings.ToLower(strings.TrimSpace(e))
+}
+
+// locked - sends 429 with Retry-After when one of the attempts is locked
+func (s *Account) locked(w http.ResponseWriter, as ...attempt) bool {
+	for _, a := range as {
+		d, err := s.conn.Locked(a.scope, a.key)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return true
+		}
+
+		if d > 0 {
+			helper.TooMany(w, d)
+			return true
+		}
+	}
+
+	return false
+}
+
+// failed - counts a failure for every attempt
+// the owner of an email gets notified when logins to it get locked
+func (s *Account) failed(as ...attempt) {
+	for _, a := range as {
+		_, started, err := s.conn.Failed(a.scope, a.key)
+		if err != nil {
+			log.Println("could not count failed attempt:", err)
+			continue
+		}
+
+		if started && a.scope == loginEmail {
+			s.lockoutNotice(a.key)
+		}
+	}
+}
+
+// lockoutNotice - tells the owner of the email key, in whatever case they signed up with
+func (s *Account) lockoutNotice(key string) {
+	e, err := s.conn.LockoutAddress(key)
+	switch {
+	case err == pgx.ErrNoRows:
+		return
+	case err != nil:
+		log.Println("could not find locked out account:", err)
+		return
+	}
+
+	if err := email.LockoutEmail(e); err != nil {
+		log.Println("could not send lockout email:", err)
+	}
+}
+
+// succeeded - forgets the failures of the attempt
+func (s *Account) succeeded(a attempt) {
+	if err := s.conn.Succeeded(a.scope, a.key); err != nil {
+		log.Println("could not reset failed attempts:", err)
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Hamaiz/go-rest-eg/avatar"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/gorilla/mux"
+)
+
+// avatars never change under their url, the key changes with the content
+const avatarCache = "public, max-age=31536000, immutable"
+
+// avatarURL - link to the avatar the api sends with users, questions and answers
+func avatarURL(key string, uniqueName string) string {
+	return avatar.URL(key, uniqueName, avatar.DefaultSize)
+}
+
+// replaceAvatar - saves the new key and deletes the files of the replaced avatar
+func (p *Profiles) replaceAvatar(r *http.Request, id string, key string) error {
+	old, err := p.conn.SetAvatar(id, key)
+	if err != nil {
+		return err
+	}
+
+	if old != "" && old != key {
+		if err := avatar.Remove(r.Context(), p.storage, old); err != nil {
+			log.Println("removing avatar:", err)
+		}
+	}
+
+	return nil
+}
+
+// AvatarHandler - uploads or removes the avatar - @PUT | @DELETE | @OPTIONS - /account/avatar
+// uploads are a multipart "avatar" field holding a jpeg, png or webp image
+func (p *Profiles) AvatarHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "PUT":
+		if !p.store.AlreadyLoggedIn(r) {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		id, err := p.store.GetUser(r)
+		if err != nil {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		// room for the multipart headers around the image
+		r.Body = http.MaxBytesReader(w, r.Body, avatar.MaxUpload+1<<20)
+
+		f, _, err := r.FormFile("avatar")
+		if err != nil {
+			helper.ASM(w, 422, "avatar image is missing or too large")
+			return
+		}
+		defer f.Close()
+
+		b, err := ioutil.ReadAll(io.LimitReader(f, avatar.MaxUpload+1))
+		if err != nil {
+			helper.ASM(w, 422, "avatar image is missing or too large")
+			return
+		}
+
+		if len(b) > avatar.MaxUpload {
+			helper.ASM(w, 422, avatar.ErrTooLarge.Error())
+			return
+		}
+
+		thumbs, err := avatar.Process(b)
+		switch {
+		case err == avatar.ErrFormat || err == avatar.ErrTooLarge:
+			helper.ASM(w, 422, err.Error())
+			return
+		case err != nil:
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		key, err := avatar.Save(r.Context(), p.storage, id, thumbs)
+		if err != nil {
+			log.Println("saving avatar:", err)
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		if err = p.replaceAvatar(r, id, key); err != nil {
+			avatar.Remove(r.Context(), p.storage, key)
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		a := model.Avatar{Avatar: avatar.URL(key, "", avatar.DefaultSize), Sizes: make(map[int]string, len(avatar.Sizes))}
+		for _, s := range avatar.Sizes {
+			a.Sizes[s] = avatar.URL(key, "", s)
+		}
+
+		json.NewEncoder(w).Encode(a)
+	case "DELETE":
+		if !p.store.AlreadyLoggedIn(r) {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		id, err := p.store.GetUser(r)
+		if err != nil {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		if err = p.replaceAvatar(r, id, ""); err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		helper.ASM(w, 200, "avatar removed")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// avatarSize - size in the url, false when no thumbnails are made in it
+func avatarSize(r *http.Request) (int, bool) {
+	s, err := strconv.Atoi(mux.Vars(r)["size"])
+	return s, err == nil && avatar.ValidSize(s)
+}
+
+// AvatarFileHandler - uploaded avatar - @GET - /avatars/:key/:size.png
+func (p *Profiles) AvatarFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	s, ok := avatarSize(r)
+	if !ok {
+		helper.NotFound(w, r)
+		return
+	}
+
+	o, err := p.storage.Open(r.Context(), avatar.ObjectKey(mux.Vars(r)["key"], s))
+	switch {
+	case err == storage.ErrNotFound:
+		helper.NotFound(w, r)
+		return
+	case err != nil:
+		log.Println("opening avatar:", err)
+		helper.ASM(w, 500, "")
+		return
+	}
+	defer o.Close()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", avatarCache)
+	http.ServeContent(w, r, "", o.Info().ModTime, o)
+}
+
+// DefaultAvatarHandler - generated avatar of users without an upload - @GET - /avatars/default/:seed/:size.png
+func (p *Profiles) DefaultAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	s, ok := avatarSize(r)
+	if !ok {
+		helper.NotFound(w, r)
+		return
+	}
+
+	b, err := avatar.Default(mux.Vars(r)["seed"], s)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", avatarCache)
+	w.Write(b)
+}
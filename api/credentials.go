@@ -0,0 +1,243 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/email"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/dchest/uniuri"
+	"github.com/gorilla/mux"
+)
+
+// emailChangeTTL - how long the new address has to confirm
+const emailChangeTTL = 6 * time.Hour
+
+// passwordUser - wrong current passwords are counted per user
+const passwordUser = "password-user"
+
+// validEmail - tells if e is a bare address like a@b.c, names and <> are refused
+func validEmail(e string) bool {
+	if len(e) > 254 {
+		return false
+	}
+
+	a, err := mail.ParseAddress(e)
+
+	return err == nil && a.Name == "" && a.Address == e
+}
+
+// loggedIn - user and session of the request, access tokens have no session
+func loggedIn(store AccountStore, w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if !store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return "", "", false
+	}
+
+	id, err := store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return "", "", false
+	}
+
+	sid, err := store.SessionID(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return "", "", false
+	}
+
+	return id, sid, true
+}
+
+// currentPassword - checks the current password, counting failures
+func (s *Account) currentPassword(w http.ResponseWriter, id string, p string) bool {
+	byUser := attempt{passwordUser, id}
+	if s.locked(w, byUser) {
+		return false
+	}
+
+	if !s.checkPassword(id, p) {
+		s.failed(byUser)
+		helper.ASM(w, 403, "password is wrong")
+		return false
+	}
+
+	s.succeeded(byUser)
+	return true
+}
+
+// PasswordHandler - changes or sets password of logged in user - @PUT - /account/password
+func (s *Account) PasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	id, sid, ok := loggedIn(s.store, w, r)
+	if !ok {
+		return
+	}
+
+	// Form value
+	cur := r.FormValue("currentPass")
+	p := r.FormValue("pass")
+	cp := r.FormValue("confirmPass")
+
+	// accounts made through a provider set their first password without one
+	hash, err := s.conn.GetPassword(id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+	first := hash == model.NoPassword
+
+	if (cur == "" && !first) || p == "" || cp == "" {
+		helper.ASM(w, 403, "missing credentials")
+		return
+	}
+
+	if cp != p {
+		helper.ASM(w, 403, "password not match")
+		return
+	}
+
+	if !first && !s.currentPassword(w, id, cur) {
+		return
+	}
+
+	nh, err := s.passwords.Hash(p)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	err = s.conn.ChangePassword(id, nh)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	// everyone else has to log in with the new password
+	err = s.conn.RevokeOtherSessions(id, sid)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if u, err := s.conn.GetUser(id); err == nil {
+		if err := email.PasswordChangedEmail(u.Email); err != nil {
+			log.Println("could not send password changed email:", err)
+		}
+	}
+
+	helper.ASM(w, 200, "your password changed")
+}
+
+// ChangeEmailHandler - sends confirmation to the new email - @POST - /account/email
+func (s *Account) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	id, _, ok := loggedIn(s.store, w, r)
+	if !ok {
+		return
+	}
+
+	// Form value
+	e := strings.TrimSpace(r.FormValue("email"))
+	p := r.FormValue("password")
+
+	if e == "" || p == "" {
+		helper.ASM(w, 403, "missing credentials")
+		return
+	}
+
+	if !validEmail(e) {
+		helper.ASM(w, 403, "email is not valid")
+		return
+	}
+
+	if !s.currentPassword(w, id, p) {
+		return
+	}
+
+	u, err := s.conn.GetUser(id)
+	if err != nil {
+		helper.ASM(w, 404, "")
+		return
+	}
+
+	if strings.EqualFold(u.Email, e) {
+		helper.ASM(w, 403, "this is already your email")
+		return
+	}
+
+	if s.conn.CheckingExists(e) {
+		helper.ASM(w, 403, "email already exists")
+		return
+	}
+
+	token := uniuri.NewLen(50)
+	err = s.conn.EmailChangeToken(id, e, helper.HashToken(token), time.Now().Add(emailChangeTTL))
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	// email url
+	host := os.Getenv("URL")
+	url := host + "account/confirm-email/" + token
+
+	err = email.ChangeEmailEmail(e, url)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if err := email.EmailChangeNoticeEmail(u.Email, e); err != nil {
+		log.Println("could not send email change notice:", err)
+	}
+
+	helper.ASM(w, 200, "confirm the new email to finish")
+}
+
+// ConfirmEmailChangeHandler - swaps to the new email - @GET - /account/confirm-email/:token
+func (s *Account) ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	token := mux.Vars(r)["token"]
+	if token == "" {
+		helper.ASM(w, 404, "")
+		return
+	}
+
+	id, err := s.conn.ConfirmEmailChange(helper.HashToken(token))
+	if err != nil {
+		helper.ASM(w, 403, err.Error())
+		return
+	}
+
+	// the session confirming it stays, if it belongs to the user
+	keep := ""
+	if user, err := s.store.GetUser(r); err == nil && user == id {
+		keep, _ = s.store.SessionID(r)
+	}
+
+	err = s.conn.RevokeOtherSessions(id, keep)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	helper.ASM(w, 200, "your email changed")
+}
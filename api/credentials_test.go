@@ -0,0 +1,32 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		ok    bool
+	}{
+		{"a@b.c", true},
+		{"first.last+tag@example.com", true},
+		{"", false},
+		{"a", false},
+		{"a@", false},
+		{"@b.c", false},
+		{"Name <a@b.c>", false},
+		{"<a@b.c>", false},
+		{"a@b.c, d@e.f", false},
+		{"a@b.c\r\nBcc: d@e.f", false},
+		{" a@b.c", false},
+		{strings.Repeat("a", 251) + "@b.c", false},
+	}
+
+	for _, tt := range tests {
+		if got := validEmail(tt.email); got != tt.ok {
+			t.Errorf("validEmail(%q) = %v, want %v", tt.email, got, tt.ok)
+		}
+	}
+}
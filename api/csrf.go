@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// CSRFTokens - issues csrf tokens
+type CSRFTokens interface {
+	Token(w http.ResponseWriter, r *http.Request) (string, error)
+}
+
+// CSRF - csrf struct
+type CSRF struct {
+	tokens CSRFTokens
+}
+
+// NewCSRFApi - creates new csrf api
+func NewCSRFApi(t CSRFTokens) *CSRF {
+	return &CSRF{t}
+}
+
+// CSRFHandler - token to send in the X-CSRF-Token header - @GET | @OPTIONS - /account/csrf
+func (c *CSRF) CSRFHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		t, err := c.tokens.Token(w, r)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		json.NewEncoder(w).Encode(model.CSRFToken{Token: t})
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// deletionGrace - DELETE_GRACE, how long a deleted account can still be restored
+func deletionGrace() time.Duration {
+	g := 30 * 24 * time.Hour
+
+	if v := os.Getenv("DELETE_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Println("invalid DELETE_GRACE, using", g)
+			return g
+		}
+		g = d
+	}
+
+	return g
+}
+
+// DeleteAccountHandler - schedules deletion of the account - @DELETE - /account
+func (s *Account) DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	id, _, ok := loggedIn(s.store, w, r)
+	if !ok {
+		return
+	}
+
+	// accounts made through a provider or magic links have no password to confirm with
+	hash, err := s.conn.GetPassword(id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if hash == model.NoPassword {
+		helper.ASM(w, 403, "this account has no password, set one at /account/password to delete it")
+		return
+	}
+
+	if r.FormValue("password") == "" {
+		helper.ASM(w, 403, "missing credentials")
+		return
+	}
+
+	if !s.currentPassword(w, id, r.FormValue("password")) {
+		return
+	}
+
+	grace := deletionGrace()
+	err = s.conn.RequestDeletion(id, time.Now().Add(grace))
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	// every session is revoked already, only the cookie is left to clear
+	if err := s.store.CleanSession(w, r); err != nil {
+		log.Println("could not clear session:", err)
+	}
+
+	helper.ASM(w, 202, "account will be deleted on "+time.Now().Add(grace).Format("2006-01-02")+", log in before that to keep it")
+}
+
+// ExportHandler - data of the account as zip or json - @GET - /account/export
+func (s *Account) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	id, _, ok := loggedIn(s.store, w, r)
+	if !ok {
+		return
+	}
+
+	ex, err := s.conn.ExportAccount(id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	name := "files-export-" + ex.ExportedAt.Format("20060102")
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		json.NewEncoder(w).Encode(ex)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+
+	// one json file per kind of data
+	z := zip.NewWriter(w)
+	for _, f := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", ex.Profile},
+		{"questions.json", ex.Questions},
+		{"answers.json", ex.Answers},
+		{"votes.json", ex.Votes},
+		{"identities.json", ex.Identities},
+	} {
+		fw, err := z.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: ex.ExportedAt})
+		if err != nil {
+			log.Println("could not write export:", err)
+			return
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			log.Println("could not write export:", err)
+			return
+		}
+	}
+
+	if err := z.Close(); err != nil {
+		log.Println("could not write export:", err)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// createdAtFormat - how created_at of questions is stored, so it compares as text
+const createdAtFormat = "2006-01-02T15:04:05Z"
+
+// questionFilter - sort and filters of the feed from the query
+func questionFilter(r *http.Request) (model.QuestionFilter, error) {
+	v := r.URL.Query()
+	q := model.QuestionFilter{Sort: model.SortNewest, Author: v.Get("author")}
+
+	switch s := v.Get("sort"); s {
+	case "":
+	case model.SortNewest, model.SortOldest, model.SortLikes, model.SortActivity:
+		q.Sort = s
+	default:
+		return q, errors.New("sort must be newest, oldest, likes or activity")
+	}
+
+	if a := v.Get("answered"); a != "" {
+		b, err := strconv.ParseBool(a)
+		if err != nil {
+			return q, errors.New("answered must be true or false")
+		}
+		q.Answered = &b
+	}
+
+	if s := v.Get("since"); s != "" {
+		t, _, err := feedTime(s)
+		if err != nil {
+			return q, errors.New("since must be a date or an RFC3339 time")
+		}
+		q.Since = t.Format(createdAtFormat)
+	}
+
+	// a date until includes the whole day
+	if s := v.Get("until"); s != "" {
+		t, day, err := feedTime(s)
+		if err != nil {
+			return q, errors.New("until must be a date or an RFC3339 time")
+		}
+		if day {
+			t = t.AddDate(0, 0, 1)
+		}
+		q.Until = t.Format(createdAtFormat)
+	}
+
+	if s := v.Get("minLikes"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return q, errors.New("minLikes must be a non-negative number")
+		}
+		q.MinLikes = n
+	}
+
+	return q, nil
+}
+
+// feedTime - time of a 2006-01-02 date or an RFC3339 time in UTC, day tells which one it was
+func feedTime(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), false, err
+}
+
+// sortCursor - tells if the cursor was made for pages in the sort
+func sortCursor(c *model.Cursor, sort string) bool {
+	if c.Sort != sort {
+		return false
+	}
+
+	if sort == model.SortLikes {
+		_, err := strconv.ParseInt(c.Key, 10, 64)
+		return err == nil
+	}
+
+	return true
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+func TestQuestionFilter(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		query string
+		want  model.QuestionFilter
+		err   string
+	}{
+		{"", model.QuestionFilter{Sort: model.SortNewest}, ""},
+		{"sort=likes&author=ann", model.QuestionFilter{Sort: model.SortLikes, Author: "ann"}, ""},
+		{"sort=oldest", model.QuestionFilter{Sort: model.SortOldest}, ""},
+		{"sort=activity", model.QuestionFilter{Sort: model.SortActivity}, ""},
+		{"answered=true", model.QuestionFilter{Sort: model.SortNewest, Answered: &yes}, ""},
+		{"answered=0", model.QuestionFilter{Sort: model.SortNewest, Answered: &no}, ""},
+		{"since=2021-03-04", model.QuestionFilter{Sort: model.SortNewest, Since: "2021-03-04T00:00:00Z"}, ""},
+		{"until=2021-03-04", model.QuestionFilter{Sort: model.SortNewest, Until: "2021-03-05T00:00:00Z"}, ""},
+		{"since=2021-03-04T10:00:00%2B02:00", model.QuestionFilter{Sort: model.SortNewest, Since: "2021-03-04T08:00:00Z"}, ""},
+		{"until=2021-03-04T10:00:00Z", model.QuestionFilter{Sort: model.SortNewest, Until: "2021-03-04T10:00:00Z"}, ""},
+		{"minLikes=0", model.QuestionFilter{Sort: model.SortNewest}, ""},
+		{"minLikes=5", model.QuestionFilter{Sort: model.SortNewest, MinLikes: 5}, ""},
+		{"sort=best", model.QuestionFilter{}, "sort must be newest, oldest, likes or activity"},
+		{"answered=maybe", model.QuestionFilter{}, "answered must be true or false"},
+		{"since=yesterday", model.QuestionFilter{}, "since must be a date or an RFC3339 time"},
+		{"until=2021-13-01", model.QuestionFilter{}, "until must be a date or an RFC3339 time"},
+		{"minLikes=-1", model.QuestionFilter{}, "minLikes must be a non-negative number"},
+		{"minLikes=many", model.QuestionFilter{}, "minLikes must be a non-negative number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := questionFilter(httptest.NewRequest("GET", "/api/questions?"+tt.query, nil))
+
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if q.Sort != tt.want.Sort || q.Author != tt.want.Author || q.Since != tt.want.Since || q.Until != tt.want.Until || q.MinLikes != tt.want.MinLikes {
+				t.Errorf("filter = %+v, want %+v", q, tt.want)
+			}
+			if (q.Answered == nil) != (tt.want.Answered == nil) || (q.Answered != nil && *q.Answered != *tt.want.Answered) {
+				t.Errorf("answered = %v, want %v", q.Answered, tt.want.Answered)
+			}
+		})
+	}
+}
+
+func TestSortedCursor(t *testing.T) {
+	newest := model.Cursor{Key: "2021-03-04T00:00:00Z", ID: "q1", Sort: model.SortNewest}
+	likes := model.Cursor{Key: "12", ID: "q1", Sort: model.SortLikes}
+	answer := model.Cursor{Key: "2021-03-04T00:00:00Z", ID: "user-1"}
+
+	tests := []struct {
+		name string
+		c    model.Cursor
+		sort string
+		ok   bool
+	}{
+		{"feed in its sort", likes, model.SortLikes, true},
+		{"feed in another sort", newest, model.SortLikes, false},
+		{"likes that aren't a number", model.Cursor{Key: "many", ID: "q1", Sort: model.SortLikes}, model.SortLikes, false},
+		{"search", newest, model.SortNewest, true},
+		{"search with a likes cursor", likes, model.SortNewest, false},
+		{"search with an answers cursor", answer, model.SortNewest, false},
+		{"answers", answer, "", true},
+		{"answers with a feed cursor", newest, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?cursor="+encodeCursor(tt.c), nil)
+
+			c, err := sortedCursor(r, tt.sort)
+			if tt.ok && (err != nil || c == nil || *c != tt.c) {
+				t.Errorf("sortedCursor = %+v, %v", c, err)
+			}
+			if !tt.ok && err != errCursor {
+				t.Errorf("cursor of another listing accepted: %+v, %v", c, err)
+			}
+		})
+	}
+
+	if c, err := sortedCursor(httptest.NewRequest("GET", "/", nil), ""); c != nil || err != nil {
+		t.Errorf("no cursor = %+v, %v", c, err)
+	}
+}
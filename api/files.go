@@ -2,11 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/Hamaiz/go-rest-eg/helper"
 	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/storage"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4"
@@ -14,36 +16,78 @@ import (
 
 // FilesDatabase - holds all the function - interface
 type FilesDatabase interface {
-	GetSearchedQuestions(l string) ([]model.GetQuestions, error)
-	GetQuestions() ([]model.GetQuestions, error)
-	PostQuestion(p model.FilesQuestion) error
+	GetSearchedQuestions(l string, c *model.Cursor, limit int) ([]model.GetQuestions, model.Span, error)
+	GetQuestions(q model.QuestionFilter, c *model.Cursor, limit int) ([]model.GetQuestions, model.Span, error)
+	PostQuestion(p model.FilesQuestion, attachments []string) error
 	GetQuest(s string) (model.FilesSend, error)
 	GetQuestion(s string) (model.FilesQuestion, error)
 	EditQuestion(s string, nq string, slug string) error
-	AddAnswer(a model.FilesComment) error
+	AddAnswer(a model.FilesComment, attachments []string) error
 	GetAnswer(s string, c string) (model.FilesComment, error)
 	GetOneAnswer(s string) (string, error)
-	EditAnswer(s string, na string) error
-	GetAnswers(s string) ([]model.GetAnswers, error)
+	EditAnswer(s string, c string, na string) error
+	DeleteQuestion(s string) error
+	DeleteAnswer(s string, c string) error
+	GetAnswers(s string, c *model.Cursor, limit int) ([]model.GetAnswers, model.Span, error)
 	Like(id string, u string) error
 	Dislike(id string, u string) error
 	GetLikes(id string) (int, error)
+	AddAttachment(a model.Attachment, quota int64, st storage.Storage, put func() error) error
+	AttachmentUsage(owner string) (int64, error)
+}
+
+// RoleDatabase - roles of users and the audit log
+type RoleDatabase interface {
+	GetRole(id string) (string, error)
+	Audit(actor string, action string, targetType string, targetID string, detail string) error
 }
 
 // Account - account store struct
 type Media struct {
-	store AccountStore
-	conn  FilesDatabase
+	store   AccountStore
+	conn    FilesDatabase
+	roles   RoleDatabase
+	storage storage.Storage
+	limits  AttachmentLimits
 }
 
 // NewAccountStore - creates new store
-func NewFilesApi(s AccountStore, c FilesDatabase) *Media {
-	return &Media{s, c}
+func NewFilesApi(s AccountStore, c FilesDatabase, r RoleDatabase, st storage.Storage, l AttachmentLimits) *Media {
+	return &Media{s, c, r, st, l}
+}
+
+// allow - lets the owner and users whose role has the permission through
+// everyone else gets 403 like the Require middleware gives
+func (m *Media) allow(w http.ResponseWriter, id string, owner string, perm string) bool {
+	if id == owner {
+		return true
+	}
+
+	role, err := m.roles.GetRole(id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return false
+	}
+
+	if !model.Can(role, perm) {
+		helper.ASM(w, 403, "missing permission "+perm)
+		return false
+	}
+
+	return true
 }
 
-// SearchQuestionHandler - search questions - @POST - /api/search
+// audit - records staff actions on posts of other users
+func (m *Media) audit(actor string, action string, targetType string, targetID string) {
+	if err := m.roles.Audit(actor, action, targetType, targetID, ""); err != nil {
+		log.Println("could not write audit log:", err)
+	}
+}
+
+// SearchQuestionHandler - search questions - @GET | @POST - /api/search?cursor=&limit=
+// GET is there so the Link header of the next page can be followed
 func (m *Media) SearchQuestionHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
+	if r.Method != "POST" && r.Method != "GET" {
 		helper.ASM(w, 405, "")
 		return
 	}
@@ -51,21 +95,28 @@ func (m *Media) SearchQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	// FormValue
 	s := r.FormValue("search")
 
+	c, err := sortedCursor(r, model.SortNewest)
+	if err != nil {
+		helper.ASM(w, 422, err.Error())
+		return
+	}
+	limit, _ := page(r, 20, 100)
+
 	// extract all the important words
 	l := helper.KeyExtract(s)
 
 	// get question
-	fqs, err := m.conn.GetSearchedQuestions(l)
+	fqs, sp, err := m.conn.GetSearchedQuestions(l, c, limit)
 	if err != nil {
 		helper.ASM(w, 403, err.Error())
 		return
-	} else {
-		json.NewEncoder(w).Encode(fqs)
-		return
 	}
+
+	json.NewEncoder(w).Encode(model.QuestionsPage{Questions: fqs, Page: paginate(w, r, c, sp, limit)})
 }
 
-// GetQuestionsHandler - get all questions - @GET | @OPTIONS - /api/question
+// GetQuestionsHandler - get a page of the question feed - @GET | @OPTIONS - /api/question?cursor=&limit=
+// sorted with sort=newest|oldest|likes|activity and filtered with answered, author, since, until and minLikes
 func (m *Media) GetQuestionsHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -76,13 +127,26 @@ func (m *Media) GetQuestionsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		fqs, err := m.conn.GetQuestions()
+		q, err := questionFilter(r)
+		if err != nil {
+			helper.ASM(w, 422, err.Error())
+			return
+		}
+
+		c, err := sortedCursor(r, q.Sort)
+		if err != nil {
+			helper.ASM(w, 422, err.Error())
+			return
+		}
+		limit, _ := page(r, 20, 100)
+
+		fqs, sp, err := m.conn.GetQuestions(q, c, limit)
 		if err != nil {
 			helper.ASM(w, 403, err.Error())
 			return
 		}
 
-		json.NewEncoder(w).Encode(fqs)
+		json.NewEncoder(w).Encode(model.QuestionsPage{Questions: fqs, Page: paginate(w, r, c, sp, limit)})
 		return
 	case "OPTIONS":
 		helper.ASM(w, 204, "")
@@ -116,6 +180,9 @@ func (m *Media) SendQuestionHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		q.Avatar = avatarURL(q.AvatarKey, q.Unique_Name)
+		m.sign(q.Attachments)
+
 		json.NewEncoder(w).Encode(q)
 
 	case "OPTIONS":
@@ -151,6 +218,11 @@ func (m *Media) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 	// Form Value
 	q := r.FormValue("question")
 	t := time.Now().UTC().Format(time.RFC3339)
+
+	attachments, ok := m.attachmentIDs(w, r)
+	if !ok {
+		return
+	}
 	qs := helper.UniqueQuestion(q)
 	qi := uuid.New().String()
 
@@ -158,9 +230,9 @@ func (m *Media) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 	fq := model.FilesQuestion{qi, q, id, qs, t, t}
 
 	// add item to database
-	err = m.conn.PostQuestion(fq)
+	err = m.conn.PostQuestion(fq, attachments)
 	if err != nil {
-		helper.ASM(w, 500, "")
+		attachError(w, err)
 		return
 	}
 
@@ -212,9 +284,8 @@ func (m *Media) EditQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// if id and question poster return unauthorized
-	if id != fq.Poster {
-		helper.ASM(w, 401, "")
+	// only the poster and staff that can edit any post
+	if !m.allow(w, id, fq.Poster, model.PermEditAnyPost) {
 		return
 	}
 
@@ -225,6 +296,10 @@ func (m *Media) EditQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if id != fq.Poster {
+		m.audit(id, "edit-post", "question", q)
+	}
+
 	helper.ASM(w, 201, "question edited")
 }
 
@@ -244,14 +319,26 @@ func (m *Media) SendAnswersHandler(w http.ResponseWriter, r *http.Request) {
 		param := mux.Vars(r)
 		slug := param["slug"]
 
+		c, err := sortedCursor(r, "")
+		if err != nil {
+			helper.ASM(w, 422, err.Error())
+			return
+		}
+		limit, _ := page(r, 20, 100)
+
 		// get answers with all slug
-		q, err := m.conn.GetAnswers(slug)
+		q, sp, err := m.conn.GetAnswers(slug, c, limit)
 		if err != nil {
 			helper.ASM(w, 404, err.Error())
 			return
 		}
 
-		json.NewEncoder(w).Encode(q)
+		for i := range q {
+			q[i].Avatar = avatarURL(q[i].AvatarKey, q[i].Unique_Name)
+			m.sign(q[i].Attachments)
+		}
+
+		json.NewEncoder(w).Encode(model.AnswersPage{Answers: q, Page: paginate(w, r, c, sp, limit)})
 		return
 	case "OPTIONS":
 		helper.ASM(w, 204, "")
@@ -319,6 +406,11 @@ func (m *Media) CreateAnswerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attachments, ok := m.attachmentIDs(w, r)
+	if !ok {
+		return
+	}
+
 	// get param ans
 	param := mux.Vars(r)
 	ans := param["ans"]
@@ -343,9 +435,9 @@ func (m *Media) CreateAnswerHandler(w http.ResponseWriter, r *http.Request) {
 	_, err = m.conn.GetAnswer(ans, id)
 	switch {
 	case err == pgx.ErrNoRows:
-		err = m.conn.AddAnswer(c)
+		err = m.conn.AddAnswer(c, attachments)
 		if err != nil {
-			helper.ASM(w, 500, "")
+			attachError(w, err)
 			return
 		}
 		helper.ASM(w, 201, "answer made")
@@ -395,9 +487,18 @@ func (m *Media) EditAnswerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// staff may edit the answer of another commenter
+	c := r.FormValue("commenter")
+	if c == "" {
+		c = id
+	}
+
+	if !m.allow(w, id, c, model.PermEditAnyPost) {
+		return
+	}
+
 	// get question from database
-	var fc model.FilesComment
-	fc, err = m.conn.GetAnswer(ans, id)
+	_, err = m.conn.GetAnswer(ans, c)
 	switch {
 	case err == pgx.ErrNoRows:
 		helper.ASM(w, 404, "no answer found")
@@ -407,18 +508,108 @@ func (m *Media) EditAnswerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// if id and question poster return unauthorized
-	if id != fc.Commenter {
+	// edit question database
+	err = m.conn.EditAnswer(ans, c, a)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if c != id {
+		m.audit(id, "edit-post", "answer", ans+"/"+c)
+	}
+
+	helper.ASM(w, 201, "post edited")
+}
+
+// DeleteQuestionHandler - deletes question with its answers - @DELETE - /api/delete-question/:q
+func (m *Media) DeleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if !m.store.AlreadyLoggedIn(r) {
 		helper.ASM(w, 401, "")
 		return
 	}
 
-	// edit question database
-	err = m.conn.EditAnswer(ans, a)
+	q := mux.Vars(r)["q"]
+
+	// get question from database
+	fq, err := m.conn.GetQuestion(q)
+	if err != nil {
+		helper.ASM(w, 404, err.Error())
+		return
+	}
+
+	id, err := m.store.GetUser(r)
 	if err != nil {
 		helper.ASM(w, 500, "")
 		return
 	}
 
-	helper.ASM(w, 201, "post edited")
+	// only the poster and staff that can delete any post
+	if !m.allow(w, id, fq.Poster, model.PermDeleteAnyPost) {
+		return
+	}
+
+	err = m.conn.DeleteQuestion(q)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if id != fq.Poster {
+		m.audit(id, "delete-post", "question", q)
+	}
+
+	helper.ASM(w, 200, "question deleted")
+}
+
+// DeleteAnswerHandler - deletes answer - @DELETE - /api/delete-answer/:ans
+func (m *Media) DeleteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if !m.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	ans := mux.Vars(r)["ans"]
+
+	id, err := m.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	// staff may delete the answer of another commenter
+	c := r.FormValue("commenter")
+	if c == "" {
+		c = id
+	}
+
+	if !m.allow(w, id, c, model.PermDeleteAnyPost) {
+		return
+	}
+
+	err = m.conn.DeleteAnswer(ans, c)
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "no answer found")
+		return
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if c != id {
+		m.audit(id, "delete-post", "answer", ans+"/"+c)
+	}
+
+	helper.ASM(w, 200, "answer deleted")
 }
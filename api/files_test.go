@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// fakeRoles - RoleDatabase with fixed roles
+type fakeRoles map[string]string
+
+func (f fakeRoles) GetRole(id string) (string, error) {
+	role, ok := f[id]
+	if !ok {
+		return "", errors.New("no rows in result set")
+	}
+	return role, nil
+}
+
+func (f fakeRoles) Audit(actor string, action string, targetType string, targetID string, detail string) error {
+	return nil
+}
+
+func TestMediaAllow(t *testing.T) {
+	m := &Media{roles: fakeRoles{"owner": model.RoleUser, "user": model.RoleUser, "mod": model.RoleModerator}}
+
+	tests := []struct {
+		name   string
+		id     string
+		perm   string
+		status int
+	}{
+		{"owner", "owner", model.PermDeleteAnyPost, 200},
+		{"other user", "user", model.PermEditAnyPost, 403},
+		{"other user deleting", "user", model.PermDeleteAnyPost, 403},
+		{"moderator", "mod", model.PermEditAnyPost, 200},
+		{"moderator deleting", "mod", model.PermDeleteAnyPost, 200},
+		{"no role", "gone", model.PermEditAnyPost, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ok := m.allow(w, tt.id, "owner", tt.perm)
+
+			if ok != (tt.status == 200) || w.Code != tt.status {
+				t.Errorf("allow = %v with %d, want %d", ok, w.Code, tt.status)
+			}
+		})
+	}
+}
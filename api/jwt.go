@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// JWTStore - account store that issues jwt access and refresh tokens
+type JWTStore interface {
+	AccountStore
+	Refresh(w http.ResponseWriter, r *http.Request) (model.TokenPair, error)
+	Revoke(w http.ResponseWriter, r *http.Request) error
+	JWKS() model.JWKS
+}
+
+// JWT - token endpoints of the jwt store
+type JWT struct {
+	store JWTStore
+}
+
+// NewJWTApi - creates new jwt api
+func NewJWTApi(s JWTStore) *JWT {
+	return &JWT{s}
+}
+
+// RefreshHandler - rotates refresh token - @POST - /account/token/refresh
+func (j *JWT) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	tp, err := j.store.Refresh(w, r)
+	if err != nil {
+		helper.ASM(w, 401, err.Error())
+		return
+	}
+
+	json.NewEncoder(w).Encode(tp)
+}
+
+// RevokeHandler - revokes refresh token and its session - @POST - /account/token/revoke
+func (j *JWT) RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	err := j.store.Revoke(w, r)
+	if err != nil {
+		helper.ASM(w, 403, err.Error())
+		return
+	}
+
+	helper.ASM(w, 200, "token revoked")
+}
+
+// JWKSHandler - public signing keys - @GET - /.well-known/jwks.json
+func (j *JWT) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(j.store.JWKS())
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/dchest/uniuri"
+	"github.com/jackc/pgx/v4"
+)
+
+// LoginDatabase - what every login checks, however the user proved who they are
+type LoginDatabase interface {
+	ActiveBan(id string) (model.Ban, error)
+	CancelDeletion(id string) (bool, error)
+	GetTOTP(id string) (model.TOTP, error)
+	CreateChallenge(id string, hash string, expires time.Time) error
+}
+
+// Logins - finishes password, magic link and provider logins the same way
+type Logins struct {
+	store AccountStore
+	conn  LoginDatabase
+}
+
+// NewLoginApi - returns Logins
+func NewLoginApi(s AccountStore, c LoginDatabase) *Logins {
+	return &Logins{s, c}
+}
+
+// finish - logs in the user whose credentials were checked, msg is sent with the session
+// banned users are rejected and two factor users get a challenge instead of a session
+func (l *Logins) finish(w http.ResponseWriter, r *http.Request, id string, msg string) {
+	// suspended and banned users can't log in
+	b, err := l.conn.ActiveBan(id)
+	switch {
+	case err == nil:
+		banned(w, b)
+		return
+	case err != pgx.ErrNoRows:
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	// logging in during the grace period keeps the account
+	if _, err := l.conn.CancelDeletion(id); err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	// two factor users get a challenge instead of a session
+	t, err := l.conn.GetTOTP(id)
+	switch {
+	case err == nil && t.Confirmed:
+		l.startChallenge(w, id)
+		return
+	case err != nil && err != pgx.ErrNoRows:
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	// Create New Session
+	err = l.store.SaveSession(w, r, id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	helper.ASM(w, 200, msg)
+}
+
+// startChallenge - holds the login until the second factor is sent
+func (l *Logins) startChallenge(w http.ResponseWriter, id string) {
+	token := uniuri.NewLen(50)
+
+	err := l.conn.CreateChallenge(id, helper.HashToken(token), time.Now().Add(challengeTTL))
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     challengeCookie,
+		Value:    token,
+		Path:     "/account/login",
+		MaxAge:   int(challengeTTL.Seconds()),
+		HttpOnly: true,
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(model.MFAChallenge{Status: "202", Message: "two-factor code required", Challenge: token})
+}
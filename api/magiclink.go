@@ -0,0 +1,156 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/email"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/dchest/uniuri"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// magic links are short lived and only work in the browser holding the cookie
+const (
+	magicLinkTTL    = 15 * time.Minute
+	magicLinkCookie = "magic_link"
+)
+
+// magicLinkEnabled - writes 404 when administrators turned magic links off
+func (s *Account) magicLinkEnabled(w http.ResponseWriter) bool {
+	on, err := s.conn.MagicLinkEnabled()
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return false
+	}
+
+	if !on {
+		helper.ASM(w, 404, "magic links are turned off")
+		return false
+	}
+
+	return true
+}
+
+// MagicLinkHandler - emails a sign-in link - @POST - /account/magic-link
+func (s *Account) MagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if s.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	if !s.magicLinkEnabled(w) {
+		return
+	}
+
+	e := r.FormValue("email")
+	if e == "" {
+		helper.ASM(w, 403, "email is empty")
+		return
+	}
+
+	// every request counts, so emails can't be spammed at anyone
+	byEmail := attempt{magicEmail, emailKey(e)}
+	byIP := attempt{magicIP, helper.ClientIP(r)}
+
+	if s.locked(w, byEmail, byIP) {
+		return
+	}
+
+	s.failed(byEmail, byIP)
+
+	u, err := s.conn.GetUserInLogin(e)
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "user not found with the email")
+		return
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if !s.conn.LoginConfirm(e) {
+		helper.ASM(w, 403, "confirm your email to continue")
+		return
+	}
+
+	// the emailed token and the browser cookie are both needed to sign in
+	token := uniuri.NewLen(50)
+	browser := uniuri.NewLen(32)
+
+	err = s.conn.MagicLinkToken(u.ID, helper.HashToken(token), helper.HashToken(browser), time.Now().Add(magicLinkTTL))
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookie,
+		Value:    browser,
+		Path:     "/account/magic-link",
+		MaxAge:   int(magicLinkTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	url := os.Getenv("URL") + "account/magic-link/" + token
+
+	err = email.MagicLinkEmail(e, url)
+	if err != nil {
+		helper.ASM(w, 500, "an error occured while sending email")
+		return
+	}
+
+	helper.ASM(w, 200, "sign-in link has been sent to your email")
+}
+
+// MagicLinkLoginHandler - signs in with the emailed link - @GET - /account/magic-link/:token
+func (s *Account) MagicLinkLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if s.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	if !s.magicLinkEnabled(w) {
+		return
+	}
+
+	// guessing tokens is limited per ip
+	byIP := attempt{magicIP, helper.ClientIP(r)}
+	if s.locked(w, byIP) {
+		return
+	}
+
+	c, err := r.Cookie(magicLinkCookie)
+	if err != nil || c.Value == "" {
+		helper.ASM(w, 403, "open the link in the browser that asked for it")
+		return
+	}
+
+	id, err := s.conn.UseMagicLink(helper.HashToken(mux.Vars(r)["token"]), helper.HashToken(c.Value))
+	switch {
+	case err == pgx.ErrNoRows:
+		s.failed(byIP)
+		helper.ASM(w, 403, "link is invalid, used or expired")
+		return
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: magicLinkCookie, Path: "/account/magic-link", MaxAge: -1})
+
+	s.login.finish(w, r, id, "logged in successfully")
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/gorilla/mux"
+)
+
+// Blobs - uploaded media struct
+type Blobs struct {
+	storage storage.Storage
+	signer  *storage.Signer
+}
+
+// NewBlobsApi - creates new media api
+func NewBlobsApi(s storage.Storage, sg *storage.Signer) *Blobs {
+	return &Blobs{s, sg}
+}
+
+// MediaHandler - stored object behind a signed url - @GET | @HEAD - /media/:key?expires=&signature=
+// range requests are answered and objects are cached until the url expires, keys never change content
+func (b *Blobs) MediaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	q := r.URL.Query()
+
+	exp, ok := b.signer.Verify(key, q.Get("expires"), q.Get("signature"))
+	if !ok {
+		helper.ASM(w, 403, "link is invalid or expired")
+		return
+	}
+
+	o, err := b.storage.Open(r.Context(), key)
+	switch {
+	case err == storage.ErrNotFound:
+		helper.NotFound(w, r)
+		return
+	case err != nil:
+		log.Println("opening media:", err)
+		helper.ASM(w, 500, "")
+		return
+	}
+	defer o.Close()
+
+	info := o.Info()
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+
+	// uploads are never trusted to run anything in the api's origin
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; media-src 'self'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(time.Until(exp).Seconds())))
+	w.Header().Set("ETag", `"`+key+`"`)
+
+	http.ServeContent(w, r, "", info.ModTime, o)
+}
@@ -2,52 +2,118 @@ package api
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"crypto/subtle"
 	"encoding/json"
-	"io/ioutil"
+	"log"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/Hamaiz/go-rest-eg/helper"
 	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/oauth"
+	"github.com/dchest/uniuri"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+)
+
+// login with the provider and linking to an existing account
+const (
+	stateTTL     = 10 * time.Minute
+	linkCookie   = "oauth_link"
+	linkTTL      = 10 * time.Minute
+	linkAttempts = 5
+)
+
+// state modes - what the callback does with the identity
+const (
+	modeLogin = "login"
+	modeLink  = "link"
 )
 
 // OauthDatabase - hold all the functions of oauth database
 type OauthDatabase interface {
-	InsertGoogle(u model.GoogleData) (string, error)
-	CheckEmail(e string) (bool, string)
-	CheckGoogle(e string) bool
+	LoginIdentity(u model.Identity) (string, bool, error)
+	LinkIdentity(account string, u model.Identity) error
+	UnlinkIdentity(account string, provider string, subject string) error
+	GetIdentities(account string) ([]model.LinkedIdentity, error)
+	HasPassword(account string) (bool, error)
+	GetPassword(account string) (string, error)
+	PendingLink(account string, u model.Identity, hash string, expires time.Time) error
+	UsePendingLink(hash string, maxAttempts int) (string, model.Identity, error)
+	DeletePendingLink(hash string) error
+}
+
+// ProviderTokens - keeps provider tokens for calls on the user's behalf
+type ProviderTokens interface {
+	Save(provider string, subject string, t *oauth2.Token) error
 }
 
 // Oauth - struct holds all the functions
 type Oauth struct {
-	store AccountStore
-	conn  OauthDatabase
+	store     AccountStore
+	conn      OauthDatabase
+	providers *oauth.Registry
+	tokens    ProviderTokens
+	passwords PasswordHasher
+	login     *Logins
+}
+
+// NewOauthApi - creates new oauthapi
+func NewOauthApi(s AccountStore, c OauthDatabase, p *oauth.Registry, t ProviderTokens, h PasswordHasher, l *Logins) *Oauth {
+	return &Oauth{s, c, p, t, h, l}
 }
 
-// googleOauthConfig - return google config
-func googleOauthConfig() *oauth2.Config {
-	return &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.profile", "https://www.googleapis.com/auth/userinfo.email"},
-		RedirectURL:  os.Getenv("URL") + "account/google/callback",
-		Endpoint:     google.Endpoint,
+// saveToken - a token that can't be saved doesn't stop the login
+func (o *Oauth) saveToken(id model.Identity, t *oauth2.Token) {
+	if err := o.tokens.Save(id.Provider, id.Subject, t); err != nil {
+		log.Println("could not save", id.Provider, "token:", err)
+	}
+}
+
+// provider - provider of the route, 404 when it isn't configured
+func (o *Oauth) provider(w http.ResponseWriter, r *http.Request) (*oauth.Provider, bool) {
+	p, ok := o.providers.Provider(mux.Vars(r)["provider"])
+	if !ok {
+		helper.ASM(w, 404, "")
+		return nil, false
 	}
 
+	return p, true
 }
 
-// NewOauthApi - creates new oauthapi
-func NewOauthApi(s AccountStore, c OauthDatabase) *Oauth {
-	return &Oauth{s, c}
+// stateCookie - cookie holding state and pkce verifier of the provider
+func stateCookie(p *oauth.Provider, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     "oauthstate",
+		Value:    value,
+		Path:     "/account/" + p.Name,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+	}
 }
 
-// GoogleLoginHandler - google login handler - /account/google/login
-func (o *Oauth) GoogleLoginHandler(w http.ResponseWriter, r *http.Request) {
+// redirect - sends the user to the provider, the mode comes back in the state cookie
+func (o *Oauth) redirect(w http.ResponseWriter, r *http.Request, p *oauth.Provider, mode string) {
+	// random value for CSRF attacks and the pkce verifier
+	state := uniuri.NewLen(32)
+	verifier := oauth.Verifier()
+
+	u, err := p.AuthCodeURL(state, verifier)
+	if err != nil {
+		helper.ASM(w, 502, "")
+		return
+	}
+
+	http.SetCookie(w, stateCookie(p, state+"."+verifier+"."+mode, int(stateTTL.Seconds())))
+
+	// redirect to url
+	http.Redirect(w, r, u, http.StatusTemporaryRedirect)
+}
+
+// LoginHandler - provider login handler - @GET - /account/:provider/login
+func (o *Oauth) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Method check
 	if r.Method != "GET" {
 		helper.ASM(w, 405, "")
@@ -60,102 +126,298 @@ func (o *Oauth) GoogleLoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// make random value for CSRF attacks
-	b := make([]byte, 16)
-	rand.Read(b)
-	state := base64.URLEncoding.EncodeToString(b)
+	p, ok := o.provider(w, r)
+	if !ok {
+		return
+	}
+
+	o.redirect(w, r, p, modeLogin)
+}
+
+// LinkHandler - links provider to the logged in user - @GET - /account/:provider/link
+func (o *Oauth) LinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if _, ok := o.sessionUser(w, r); !ok {
+		return
+	}
 
-	// make cookie
-	expiration := time.Now().Add(365 * 24 * time.Hour)
-	cookie := http.Cookie{Name: "oauthstate", Value: state, Expires: expiration}
+	p, ok := o.provider(w, r)
+	if !ok {
+		return
+	}
 
-	// set it as a cookie
-	http.SetCookie(w, &cookie)
+	o.redirect(w, r, p, modeLink)
+}
 
-	//conf := googleOauthConfig.AuthCodeURL(state)
-	u := googleOauthConfig().AuthCodeURL(state)
+// sessionUser - user of a logged in session, access tokens can't link
+func (o *Oauth) sessionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !o.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return "", false
+	}
 
-	// redirect to url
-	http.Redirect(w, r, u, http.StatusTemporaryRedirect)
+	id, err := o.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return "", false
+	}
+
+	if _, err := o.store.SessionID(r); err != nil {
+		helper.ASM(w, 401, "")
+		return "", false
+	}
+
+	return id, true
 }
 
-// GoogleCallbackHandler - google callback - /account/google/callback
-func (o *Oauth) GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
+// CallbackHandler - provider callback - @GET - /account/:provider/callback
+func (o *Oauth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		helper.ASM(w, 405, "")
+		return
 	}
 
-	// check if logged in
-	if o.store.AlreadyLoggedIn(r) {
-		helper.ASM(w, 401, "")
+	p, ok := o.provider(w, r)
+	if !ok {
 		return
 	}
 
 	// get oauthstate cookie
-	oauthstate, _ := r.Cookie("oauthstate")
+	c, err := r.Cookie("oauthstate")
+	if err != nil {
+		helper.ASM(w, 403, "")
+		return
+	}
+
+	// remove oauthstate cookie
+	http.SetCookie(w, stateCookie(p, "", -1))
+
+	parts := strings.SplitN(c.Value, ".", 3)
+	if len(parts) != 3 {
+		helper.ASM(w, 403, "")
+		return
+	}
+	state, verifier, mode := parts[0], parts[1], parts[2]
 
 	// check if it matches
-	if r.FormValue("state") != oauthstate.Value {
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("state")), []byte(state)) != 1 {
 		helper.ASM(w, 403, "")
 		return
 	}
 
+	// linking needs the session that started it, logging in needs none
+	var user string
+	switch mode {
+	case modeLink:
+		if user, ok = o.sessionUser(w, r); !ok {
+			return
+		}
+	case modeLogin:
+		if o.store.AlreadyLoggedIn(r) {
+			helper.ASM(w, 401, "")
+			return
+		}
+	default:
+		helper.ASM(w, 403, "")
+		return
+	}
+
+	if r.FormValue("error") != "" {
+		helper.ASM(w, 403, p.Name+" login was denied")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
 	// exchange for the access token
-	token, err := googleOauthConfig().Exchange(context.Background(), r.FormValue("code"))
+	token, err := p.Exchange(ctx, r.FormValue("code"), verifier)
 	if err != nil {
 		helper.ASM(w, 403, "")
 		return
 	}
 
-	// get response data with token
-	oauthGoogleURLAPI := "https://www.googleapis.com/oauth2/v2/userinfo?access_token="
-	response, err := http.Get(oauthGoogleURLAPI + token.AccessToken)
+	id, err := p.Identity(ctx, token)
 	if err != nil {
-		helper.ASM(w, 403, "")
+		helper.ASM(w, 502, "")
 		return
 	}
 
-	defer response.Body.Close()
+	if mode == modeLink {
+		err = o.conn.LinkIdentity(user, id)
+		if err != nil {
+			helper.ASM(w, 409, err.Error())
+			return
+		}
 
-	// read all the response body
-	contents, err := ioutil.ReadAll(response.Body)
+		o.saveToken(id, token)
+		helper.ASM(w, 200, p.Name+" linked")
+		return
+	}
+
+	// add to the database
+	user, ok, err = o.conn.LoginIdentity(id)
 	if err != nil {
-		helper.ASM(w, 403, "")
+		helper.ASM(w, 403, err.Error())
 		return
 	}
 
-	// convert byte to a struct
-	var data = model.GoogleData{}
-	data.Token = token.AccessToken
+	// the email is taken, its password has to confirm the link
+	if !ok {
+		o.startLink(w, user, id)
+		return
+	}
 
-	err = json.Unmarshal(contents, &data)
+	o.saveToken(id, token)
+
+	o.login.finish(w, r, user, "logged in with "+p.Name)
+}
+
+// startLink - holds the identity until the password of the account is sent
+func (o *Oauth) startLink(w http.ResponseWriter, account string, id model.Identity) {
+	// accounts without a password can't confirm, they link from a logged in session instead
+	hash, err := o.conn.GetPassword(account)
 	if err != nil {
-		helper.ASM(w, 403, "")
+		helper.ASM(w, 500, "")
 		return
 	}
 
-	// remove oauthstate cookie
-	c := &http.Cookie{
-		Name:   "oauthstate",
-		Value:  "",
-		MaxAge: -1,
+	if hash == model.NoPassword {
+		helper.ASM(w, 409, "an account with this email exists, log in with the provider you signed up with and link "+id.Provider+" at /account/"+id.Provider+"/link")
+		return
 	}
-	http.SetCookie(w, c)
 
-	// add to the database
-	var id string
-	id, err = o.conn.InsertGoogle(data)
+	token := uniuri.NewLen(50)
+
+	err = o.conn.PendingLink(account, id, helper.HashToken(token), time.Now().Add(linkTTL))
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkCookie,
+		Value:    token,
+		Path:     "/account/identities/confirm",
+		MaxAge:   int(linkTTL.Seconds()),
+		HttpOnly: true,
+	})
+
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(model.LinkChallenge{
+		Status:    "409",
+		Message:   "an account with this email exists, send its password to link " + id.Provider,
+		LinkToken: token,
+	})
+}
+
+// ConfirmLinkHandler - links the identity with the account password and logs in - @POST - /account/identities/confirm
+func (o *Oauth) ConfirmLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if o.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	// link token from form value or cookie
+	token := r.FormValue("linkToken")
+	if c, err := r.Cookie(linkCookie); token == "" && err == nil {
+		token = c.Value
+	}
+
+	if token == "" {
+		helper.ASM(w, 403, "log in with the provider first")
+		return
+	}
+
+	hash := helper.HashToken(token)
+	account, id, err := o.conn.UsePendingLink(hash, linkAttempts)
 	if err != nil {
 		helper.ASM(w, 403, err.Error())
 		return
 	}
 
-	// create new session
-	err = o.store.SaveSession(w, r, id)
+	p, err := o.conn.GetPassword(account)
 	if err != nil {
 		helper.ASM(w, 500, "")
 		return
 	}
 
-	helper.ASM(w, 200, "logged into google")
+	if !passwordMatches(o.passwords, p, r.FormValue("password")) {
+		helper.ASM(w, 403, "password is wrong")
+		return
+	}
+
+	err = o.conn.LinkIdentity(account, id)
+	if err != nil {
+		helper.ASM(w, 409, err.Error())
+		return
+	}
+
+	o.conn.DeletePendingLink(hash)
+	http.SetCookie(w, &http.Cookie{Name: linkCookie, Path: "/account/identities/confirm", MaxAge: -1})
+
+	o.login.finish(w, r, account, id.Provider+" linked, logged in")
+}
+
+// IdentitiesHandler - list and unlink identities - @GET | @DELETE | @OPTIONS - /account/identities
+func (o *Oauth) IdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		user, ok := o.sessionUser(w, r)
+		if !ok {
+			return
+		}
+
+		ids, err := o.conn.GetIdentities(user)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		hp, err := o.conn.HasPassword(user)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		json.NewEncoder(w).Encode(model.Identities{Identities: ids, HasPassword: hp})
+	case "DELETE":
+		user, ok := o.sessionUser(w, r)
+		if !ok {
+			return
+		}
+
+		provider := r.FormValue("provider")
+		subject := r.FormValue("subject")
+
+		if provider == "" || subject == "" {
+			helper.ASM(w, 403, "provider and subject are needed")
+			return
+		}
+
+		err := o.conn.UnlinkIdentity(user, provider, subject)
+		switch {
+		case err == pgx.ErrNoRows:
+			helper.ASM(w, 404, "identity not found")
+			return
+		case err != nil:
+			helper.ASM(w, 409, err.Error())
+			return
+		}
+
+		helper.ASM(w, 200, provider+" unlinked")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
 }
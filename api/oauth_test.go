@@ -0,0 +1,264 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/oauth"
+	"github.com/Hamaiz/go-rest-eg/oauth/oauthtest"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/oauth2"
+)
+
+// fakeOauthDatabase - OauthDatabase that records the identity of the login
+type fakeOauthDatabase struct {
+	OauthDatabase
+	identity model.Identity
+	account  string
+	linked   bool
+	password string
+	pending  int
+}
+
+func (f *fakeOauthDatabase) LoginIdentity(u model.Identity) (string, bool, error) {
+	f.identity = u
+	if !u.EmailVerified {
+		return "", false, errors.New("unverified")
+	}
+	return f.account, f.linked, nil
+}
+
+func (f *fakeOauthDatabase) PendingLink(account string, u model.Identity, hash string, expires time.Time) error {
+	f.pending++
+	return nil
+}
+
+// fakeLogins - LoginDatabase of one user
+type fakeLogins struct {
+	ban        *model.Ban
+	totp       bool
+	challenges int
+}
+
+func (f *fakeLogins) ActiveBan(id string) (model.Ban, error) {
+	if f.ban == nil {
+		return model.Ban{}, pgx.ErrNoRows
+	}
+	return *f.ban, nil
+}
+
+func (f *fakeLogins) CancelDeletion(id string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeLogins) GetTOTP(id string) (model.TOTP, error) {
+	if !f.totp {
+		return model.TOTP{}, pgx.ErrNoRows
+	}
+	return model.TOTP{Confirmed: true}, nil
+}
+
+func (f *fakeLogins) CreateChallenge(id string, hash string, expires time.Time) error {
+	f.challenges++
+	return nil
+}
+
+type noTokens struct{}
+
+func (noTokens) Save(provider string, subject string, t *oauth2.Token) error {
+	return nil
+}
+
+// providerLogin - runs login and callback of the fake provider, returns the callback response
+func providerLogin(t *testing.T, o *Oauth, f *oauthtest.Server, state func(string) string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	vars := map[string]string{"provider": "fake"}
+
+	w := httptest.NewRecorder()
+	o.LoginHandler(w, mux.SetURLVars(httptest.NewRequest("GET", "/account/fake/login", nil), vars))
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("login = %d", w.Code)
+	}
+
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	code := f.Authorize(t, loc.String())
+
+	q := url.Values{"code": {code}, "state": {state(loc.Query().Get("state"))}}
+	r := httptest.NewRequest("GET", "/account/fake/callback?"+q.Encode(), nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	w = httptest.NewRecorder()
+	o.CallbackHandler(w, mux.SetURLVars(r, vars))
+
+	return w
+}
+
+func TestCallbackHandler(t *testing.T) {
+	same := func(s string) string { return s }
+
+	tests := []struct {
+		name     string
+		userinfo map[string]interface{}
+		state    func(string) string
+		linked   bool
+		logins   *fakeLogins
+		code     int
+		session  bool
+	}{
+		{"login", nil, same, true, &fakeLogins{}, 200, true},
+		{"state mismatch", nil, func(string) string { return "forged" }, true, &fakeLogins{}, 403, false},
+		{"unverified email", map[string]interface{}{"sub": oauthtest.Subject, "email": "ann@example.com", "email_verified": false}, same, true, &fakeLogins{}, 403, false},
+		{"email of an account", nil, same, false, &fakeLogins{}, 409, false},
+		{"banned", nil, same, true, &fakeLogins{ban: &model.Ban{Kind: model.BanSuspend}}, 403, false},
+		{"two factor", nil, same, true, &fakeLogins{totp: true}, 202, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := oauthtest.NewServer(t)
+			if tt.userinfo != nil {
+				f.SetUserInfo(tt.userinfo)
+			}
+
+			p := &oauth.Provider{
+				Name:   "fake",
+				Issuer: f.URL,
+				PKCE:   true,
+				Claims: oauth.Claims{Subject: "sub", Email: "email", EmailVerified: "email_verified", Name: "name"},
+				Config: oauth2.Config{
+					ClientID:     oauthtest.ClientID,
+					ClientSecret: oauthtest.ClientSecret,
+					Scopes:       []string{"openid", "email", "profile"},
+				},
+			}
+
+			store := &fakeStore{}
+			db := &fakeOauthDatabase{account: "u1", linked: tt.linked}
+			o := NewOauthApi(store, db, oauth.NewRegistry(p), noTokens{}, nil, NewLoginApi(store, tt.logins))
+
+			w := providerLogin(t, o, f, tt.state)
+			if w.Code != tt.code {
+				t.Errorf("callback = %d %s, want %d", w.Code, w.Body, tt.code)
+			}
+
+			if got := len(store.saved) == 1; got != tt.session {
+				t.Errorf("session saved %v, want %v", got, tt.session)
+			}
+
+			if tt.logins.totp && tt.logins.challenges != 1 {
+				t.Errorf("%d challenges, want 1", tt.logins.challenges)
+			}
+		})
+	}
+}
+
+func (f *fakeOauthDatabase) UsePendingLink(hash string, maxAttempts int) (string, model.Identity, error) {
+	return f.account, model.Identity{Provider: "fake", Subject: oauthtest.Subject}, nil
+}
+
+func (f *fakeOauthDatabase) GetPassword(account string) (string, error) {
+	if f.password != "" {
+		return f.password, nil
+	}
+	return "hash-of-secret", nil
+}
+
+func (f *fakeOauthDatabase) LinkIdentity(account string, u model.Identity) error {
+	f.linked = true
+	return nil
+}
+
+func (f *fakeOauthDatabase) DeletePendingLink(hash string) error {
+	return nil
+}
+
+// fakeHasher - "hash-of-" and the password
+type fakeHasher struct{}
+
+func (fakeHasher) Hash(p string) (string, error) {
+	return "hash-of-" + p, nil
+}
+
+func (fakeHasher) Verify(hash string, p string) (bool, bool) {
+	return hash == "hash-of-"+p, false
+}
+
+func TestCallbackPasswordlessAccount(t *testing.T) {
+	f := oauthtest.NewServer(t)
+
+	p := &oauth.Provider{
+		Name:   "fake",
+		Issuer: f.URL,
+		PKCE:   true,
+		Claims: oauth.Claims{Subject: "sub", Email: "email", EmailVerified: "email_verified", Name: "name"},
+		Config: oauth2.Config{
+			ClientID:     oauthtest.ClientID,
+			ClientSecret: oauthtest.ClientSecret,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+	}
+
+	// the account with the email was made through another provider
+	store := &fakeStore{}
+	db := &fakeOauthDatabase{account: "u1", password: model.NoPassword}
+	o := NewOauthApi(store, db, oauth.NewRegistry(p), noTokens{}, fakeHasher{}, NewLoginApi(store, &fakeLogins{}))
+
+	w := providerLogin(t, o, f, func(s string) string { return s })
+	if w.Code != 409 || !strings.Contains(w.Body.String(), "/account/fake/link") {
+		t.Errorf("callback = %d %s, want 409 pointing to /account/fake/link", w.Code, w.Body)
+	}
+	if db.pending != 0 {
+		t.Errorf("%d pending links nobody can confirm", db.pending)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == linkCookie {
+			t.Error("link cookie set")
+		}
+	}
+}
+
+func TestConfirmLinkHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		logins   *fakeLogins
+		code     int
+		session  bool
+	}{
+		{"linked", "secret", &fakeLogins{}, 200, true},
+		{"wrong password", "guess", &fakeLogins{}, 403, false},
+		{"banned", "secret", &fakeLogins{ban: &model.Ban{Kind: model.BanSuspend}}, 403, false},
+		{"two factor", "secret", &fakeLogins{totp: true}, 202, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			db := &fakeOauthDatabase{account: "u1"}
+			o := NewOauthApi(store, db, oauth.NewRegistry(), noTokens{}, fakeHasher{}, NewLoginApi(store, tt.logins))
+
+			r := httptest.NewRequest("POST", "/account/identities/confirm", nil)
+			r.Form = url.Values{"linkToken": {"token"}, "password": {tt.password}}
+
+			w := httptest.NewRecorder()
+			o.ConfirmLinkHandler(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("confirm = %d %s, want %d", w.Code, w.Body, tt.code)
+			}
+			if got := len(store.saved) == 1; got != tt.session {
+				t.Errorf("session saved %v, want %v", got, tt.session)
+			}
+		})
+	}
+}
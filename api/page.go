@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// errCursor - the cursor parameter wasn't made by the api
+var errCursor = errors.New("invalid cursor")
+
+// cursor - position given by the cursor parameter, nil for the first page
+func cursor(r *http.Request) (*model.Cursor, error) {
+	s := r.FormValue("cursor")
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errCursor
+	}
+
+	c := &model.Cursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.Key == "" || c.ID == "" {
+		return nil, errCursor
+	}
+
+	return c, nil
+}
+
+// sortedCursor - cursor of the request, errCursor when it was made for a listing
+// in another sort, answers are listed without one
+func sortedCursor(r *http.Request, sort string) (*model.Cursor, error) {
+	c, err := cursor(r)
+	if err == nil && c != nil && !sortCursor(c, sort) {
+		return nil, errCursor
+	}
+
+	return c, err
+}
+
+// encodeCursor - opaque form of the cursor
+func encodeCursor(c model.Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// paginate - cursors of the pages around the span read at c, also sent as a Link header
+func paginate(w http.ResponseWriter, r *http.Request, c *model.Cursor, sp model.Span, limit int) model.Page {
+	p := model.Page{Limit: limit}
+
+	var next, prev *model.Cursor
+	switch {
+	case sp.Count == 0 && c != nil:
+		// past either end, the way back is the cursor itself
+		back := *c
+		back.Before = !c.Before
+		if c.Before {
+			next = &back
+		} else {
+			prev = &back
+		}
+	case sp.Count == 0:
+	case c != nil && c.Before:
+		next = &sp.Last
+		if sp.More {
+			prev = &sp.First
+		}
+	default:
+		if sp.More {
+			next = &sp.Last
+		}
+		if c != nil {
+			prev = &sp.First
+		}
+	}
+
+	if next != nil {
+		next.Before = false
+	}
+	if prev != nil {
+		prev.Before = true
+	}
+
+	var links []string
+	if next != nil {
+		p.Next = encodeCursor(*next)
+		links = append(links, pageLink(r, p.Next, limit, "next"))
+	}
+	if prev != nil {
+		p.Prev = encodeCursor(*prev)
+		links = append(links, pageLink(r, p.Prev, limit, "prev"))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	return p
+}
+
+// pageLink - Link header entry of the page, form values are moved into the query
+func pageLink(r *http.Request, cur string, limit int, rel string) string {
+	q := url.Values{}
+	for k, v := range r.Form {
+		q[k] = v
+	}
+	q.Del("csrf_token")
+	q.Set("cursor", cur)
+	q.Set("limit", strconv.Itoa(limit))
+
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+
+	return "<" + u.String() + `>; rel="` + rel + `"`
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, c := range []model.Cursor{
+		{Key: "2021-01-02T03:04:05Z", ID: "q1", Sort: model.SortNewest},
+		{Key: "42", ID: "q2", Sort: model.SortLikes, Before: true},
+		{Key: "2021-01-02T03:04:05Z", ID: "user-1"},
+	} {
+		s := encodeCursor(c)
+		if strings.ContainsAny(s, "+/=&") {
+			t.Errorf("cursor %q isn't url safe", s)
+		}
+
+		got, err := cursor(httptest.NewRequest("GET", "/api/questions?cursor="+s, nil))
+		if err != nil || got == nil || *got != c {
+			t.Errorf("cursor(%q) = %+v, %v, want %+v", s, got, err, c)
+		}
+	}
+
+	if c, err := cursor(httptest.NewRequest("GET", "/api/questions", nil)); c != nil || err != nil {
+		t.Errorf("no cursor = %+v, %v", c, err)
+	}
+}
+
+func TestCursorInvalid(t *testing.T) {
+	enc := base64.RawURLEncoding.EncodeToString
+
+	for name, s := range map[string]string{
+		"not base64": "%%%",
+		"padded":     base64.URLEncoding.EncodeToString([]byte(`{"k":"a","i":"b"}`)),
+		"not json":   enc([]byte("hello")),
+		"no key":     enc([]byte(`{"i":"b"}`)),
+		"no id":      enc([]byte(`{"k":"a"}`)),
+		"wrong type": enc([]byte(`{"k":1,"i":"b"}`)),
+	} {
+		r := httptest.NewRequest("GET", "/api/questions?cursor="+url.QueryEscape(s), nil)
+		if c, err := cursor(r); err != errCursor {
+			t.Errorf("%s: cursor = %+v, %v", name, c, err)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	first := model.Cursor{Key: "a", ID: "1"}
+	last := model.Cursor{Key: "c", ID: "3"}
+	at := &model.Cursor{Key: "b", ID: "2"}
+	back := &model.Cursor{Key: "b", ID: "2", Before: true}
+
+	tests := []struct {
+		name       string
+		c          *model.Cursor
+		sp         model.Span
+		next, prev *model.Cursor
+	}{
+		{"only page", nil, model.Span{First: first, Last: last, Count: 3}, nil, nil},
+		{"first page", nil, model.Span{First: first, Last: last, Count: 3, More: true}, &last, nil},
+		{"middle page", at, model.Span{First: first, Last: last, Count: 3, More: true}, &last, &first},
+		{"last page", at, model.Span{First: first, Last: last, Count: 3}, nil, &first},
+		{"before with more", back, model.Span{First: first, Last: last, Count: 3, More: true}, &last, &first},
+		{"before at the start", back, model.Span{First: first, Last: last, Count: 3}, &last, nil},
+		{"empty", nil, model.Span{}, nil, nil},
+		{"past the end", at, model.Span{}, nil, at},
+		{"before the start", back, model.Span{}, at, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/questions?sort=likes&limit=3", nil)
+			r.ParseForm()
+
+			p := paginate(w, r, tt.c, tt.sp, 3)
+
+			check := func(rel string, got string, want *model.Cursor, before bool) {
+				if want == nil {
+					if got != "" {
+						t.Errorf("%s = %q, want none", rel, got)
+					}
+					return
+				}
+
+				c, err := cursor(httptest.NewRequest("GET", "/?cursor="+got, nil))
+				if err != nil || c.Key != want.Key || c.ID != want.ID || c.Before != before {
+					t.Errorf("%s = %+v, %v, want %+v before %v", rel, c, err, want, before)
+				}
+
+				link := w.Header().Get("Link")
+				if !strings.Contains(link, "cursor="+got) || !strings.Contains(link, `rel="`+rel+`"`) || !strings.Contains(link, "sort=likes") {
+					t.Errorf("link %q", link)
+				}
+			}
+
+			check("next", p.Next, tt.next, false)
+			check("prev", p.Prev, tt.prev, true)
+
+			if tt.next == nil && tt.prev == nil && w.Header().Get("Link") != "" {
+				t.Errorf("link without pages %q", w.Header().Get("Link"))
+			}
+			if p.Limit != 3 {
+				t.Errorf("limit %d", p.Limit)
+			}
+		})
+	}
+}
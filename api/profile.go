@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// ProfileDatabase - public profile functions
+type ProfileDatabase interface {
+	GetProfile(un string) (model.Profile, error)
+	GetProfileQuestions(un string, limit int, offset int) (model.ProfileQuestions, error)
+	GetProfileAnswers(un string, limit int, offset int) (model.ProfileAnswers, error)
+	UpdateProfile(id string, name string, bio string) error
+	SetAvatar(id string, key string) (string, error)
+}
+
+// Profiles - profile struct, avatars are kept in the storage
+type Profiles struct {
+	store   AccountStore
+	conn    ProfileDatabase
+	storage storage.Storage
+}
+
+// NewProfileApi - creates new profile api
+func NewProfileApi(s AccountStore, c ProfileDatabase, st storage.Storage) *Profiles {
+	return &Profiles{s, c, st}
+}
+
+// longest display name and bio in characters
+const (
+	maxNameLength = 50
+	maxBioLength  = 500
+)
+
+// send - encodes the profile resource, 404 when there is no profile
+func send(w http.ResponseWriter, v interface{}, err error) {
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "user not found")
+		return
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	json.NewEncoder(w).Encode(v)
+}
+
+// UserHandler - public profile - @GET | @OPTIONS - /users/:uniqueName
+func (p *Profiles) UserHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		pr, err := p.conn.GetProfile(mux.Vars(r)["uniqueName"])
+		pr.Avatar = avatarURL(pr.AvatarKey, pr.UniqueName)
+		send(w, pr, err)
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// UserQuestionsHandler - questions of the profile, newest first - @GET | @OPTIONS - /users/:uniqueName/questions
+func (p *Profiles) UserQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		limit, offset := page(r, 20, 100)
+
+		pq, err := p.conn.GetProfileQuestions(mux.Vars(r)["uniqueName"], limit, offset)
+		send(w, pq, err)
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// UserAnswersHandler - answers of the profile, newest first - @GET | @OPTIONS - /users/:uniqueName/answers
+func (p *Profiles) UserAnswersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		limit, offset := page(r, 20, 100)
+
+		pa, err := p.conn.GetProfileAnswers(mux.Vars(r)["uniqueName"], limit, offset)
+		send(w, pa, err)
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// ProfileHandler - edits display name and bio - @PUT | @OPTIONS - /account/profile
+func (p *Profiles) ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "PUT":
+		if !p.store.AlreadyLoggedIn(r) {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		id, err := p.store.GetUser(r)
+		if err != nil {
+			helper.ASM(w, 401, "")
+			return
+		}
+
+		n := strings.TrimSpace(r.FormValue("name"))
+		bio := strings.TrimSpace(r.FormValue("bio"))
+
+		if n == "" {
+			helper.ASM(w, 422, "name is empty")
+			return
+		}
+
+		if utf8.RuneCountInString(n) > maxNameLength {
+			helper.ASM(w, 422, "name is too long")
+			return
+		}
+
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			helper.ASM(w, 422, "bio is too long")
+			return
+		}
+
+		err = p.conn.UpdateProfile(id, n, bio)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		helper.ASM(w, 200, "profile updated")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// SessionDatabase - session listing and revoking functions
+type SessionDatabase interface {
+	GetSessions(user string) ([]model.Session, error)
+	RevokeUserSession(user string, sid string) error
+	RevokeOtherSessions(user string, keep string) error
+}
+
+// Sessions - session struct
+type Sessions struct {
+	store AccountStore
+	conn  SessionDatabase
+}
+
+// NewSessionApi - creates new session api
+func NewSessionApi(s AccountStore, c SessionDatabase) *Sessions {
+	return &Sessions{s, c}
+}
+
+// SessionsHandler - list sessions or log out everywhere else - @GET | @DELETE | @OPTIONS - /account/sessions
+func (s *Sessions) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		id, sid, ok := loggedIn(s.store, w, r)
+		if !ok {
+			return
+		}
+
+		ss, err := s.conn.GetSessions(id)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		for i := range ss {
+			ss[i].Current = ss[i].ID == sid
+		}
+
+		json.NewEncoder(w).Encode(ss)
+	case "DELETE":
+		id, sid, ok := loggedIn(s.store, w, r)
+		if !ok {
+			return
+		}
+
+		err := s.conn.RevokeOtherSessions(id, sid)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		helper.ASM(w, 200, "logged out everywhere else")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// SessionHandler - revokes one session - @DELETE | @OPTIONS - /account/sessions/:id
+func (s *Sessions) SessionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "DELETE":
+		id, sid, ok := loggedIn(s.store, w, r)
+		if !ok {
+			return
+		}
+
+		target := mux.Vars(r)["id"]
+		if target == sid {
+			helper.ASM(w, 403, "log out to end the current session")
+			return
+		}
+
+		err := s.conn.RevokeUserSession(id, target)
+		switch {
+		case err == pgx.ErrNoRows:
+			helper.ASM(w, 404, "session not found")
+			return
+		case err != nil:
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		helper.ASM(w, 200, "session revoked")
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
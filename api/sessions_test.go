@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// fakeStore - AccountStore of one logged in session, or none when user is empty
+type fakeStore struct {
+	user  string
+	sid   string
+	saved []string
+}
+
+func (f *fakeStore) GetUser(r *http.Request) (string, error) {
+	if f.user == "" {
+		return "", errors.New("not logged in")
+	}
+	return f.user, nil
+}
+
+func (f *fakeStore) AlreadyLoggedIn(r *http.Request) bool {
+	return f.user != ""
+}
+
+func (f *fakeStore) CleanSession(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (f *fakeStore) SaveSession(w http.ResponseWriter, r *http.Request, id string) error {
+	f.saved = append(f.saved, id)
+	return nil
+}
+
+func (f *fakeStore) SessionID(r *http.Request) (string, error) {
+	return f.sid, nil
+}
+
+// fakeSessions - SessionDatabase of one user
+type fakeSessions struct {
+	sessions []model.Session
+	revoked  []string
+}
+
+func (f *fakeSessions) GetSessions(user string) ([]model.Session, error) {
+	return f.sessions, nil
+}
+
+func (f *fakeSessions) RevokeUserSession(user string, sid string) error {
+	for _, s := range f.sessions {
+		if s.ID == sid {
+			f.revoked = append(f.revoked, sid)
+			return nil
+		}
+	}
+	return pgx.ErrNoRows
+}
+
+func (f *fakeSessions) RevokeOtherSessions(user string, keep string) error {
+	for _, s := range f.sessions {
+		if s.ID != keep {
+			f.revoked = append(f.revoked, s.ID)
+		}
+	}
+	return nil
+}
+
+func TestSessionsHandler(t *testing.T) {
+	db := &fakeSessions{sessions: []model.Session{{ID: "a"}, {ID: "b"}}}
+	s := NewSessionApi(&fakeStore{user: "u1", sid: "b"}, db)
+
+	w := httptest.NewRecorder()
+	s.SessionsHandler(w, httptest.NewRequest("GET", "/account/sessions", nil))
+
+	var ss []model.Session
+	if err := json.NewDecoder(w.Body).Decode(&ss); err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 2 || ss[0].Current || !ss[1].Current {
+		t.Errorf("sessions = %+v", ss)
+	}
+
+	w = httptest.NewRecorder()
+	s.SessionsHandler(w, httptest.NewRequest("DELETE", "/account/sessions", nil))
+	if w.Code != 200 || len(db.revoked) != 1 || db.revoked[0] != "a" {
+		t.Errorf("DELETE = %d, revoked %v", w.Code, db.revoked)
+	}
+
+	w = httptest.NewRecorder()
+	NewSessionApi(&fakeStore{}, db).SessionsHandler(w, httptest.NewRequest("GET", "/account/sessions", nil))
+	if w.Code != 401 {
+		t.Errorf("logged out GET = %d, want 401", w.Code)
+	}
+}
+
+func TestSessionHandler(t *testing.T) {
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"a", 200},
+		{"b", 403},
+		{"c", 404},
+	}
+
+	for _, tt := range tests {
+		s := NewSessionApi(&fakeStore{user: "u1", sid: "b"}, &fakeSessions{sessions: []model.Session{{ID: "a"}, {ID: "b"}}})
+
+		r := mux.SetURLVars(httptest.NewRequest("DELETE", "/account/sessions/"+tt.target, nil), map[string]string{"id": tt.target})
+		w := httptest.NewRecorder()
+		s.SessionHandler(w, r)
+
+		if w.Code != tt.code {
+			t.Errorf("DELETE %s = %d, want %d", tt.target, w.Code, tt.code)
+		}
+	}
+}
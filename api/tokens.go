@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/dchest/uniuri"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// TokenDatabase - personal access token functions
+type TokenDatabase interface {
+	InsertToken(user string, name string, scope string, hash string) (model.AccessToken, error)
+	GetTokens(user string) ([]model.AccessToken, error)
+	RenameToken(user string, id string, name string) error
+	RevokeToken(user string, id string) error
+}
+
+// Token - personal access token struct
+type Token struct {
+	store AccountStore
+	conn  TokenDatabase
+}
+
+// NewTokenApi - creates new token api
+func NewTokenApi(s AccountStore, c TokenDatabase) *Token {
+	return &Token{s, c}
+}
+
+// sessionUser - user of the cookie session, tokens can't manage tokens
+func (t *Token) sessionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if helper.BearerToken(r) != "" || !t.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return "", false
+	}
+
+	id, err := t.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return "", false
+	}
+
+	return id, true
+}
+
+// TokensHandler - list and create tokens - @GET | @POST | @OPTIONS - /account/tokens
+func (t *Token) TokensHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		id, ok := t.sessionUser(w, r)
+		if !ok {
+			return
+		}
+
+		ats, err := t.conn.GetTokens(id)
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		json.NewEncoder(w).Encode(ats)
+	case "POST":
+		id, ok := t.sessionUser(w, r)
+		if !ok {
+			return
+		}
+
+		// form values
+		n := r.FormValue("name")
+		sc := r.FormValue("scope")
+
+		if n == "" {
+			helper.ASM(w, 403, "name is empty")
+			return
+		}
+
+		if sc == "" {
+			sc = model.ScopeRead
+		}
+
+		if sc != model.ScopeRead && sc != model.ScopeWrite {
+			helper.ASM(w, 403, "scope must be read or write")
+			return
+		}
+
+		// only the hash is saved, the token is shown once
+		token := model.TokenPrefix + uniuri.NewLen(40)
+
+		at, err := t.conn.InsertToken(id, n, sc, helper.HashToken(token))
+		if err != nil {
+			helper.ASM(w, 500, "")
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(model.NewAccessToken{AccessToken: at, Token: token})
+	case "OPTIONS":
+		helper.ASM(w, 204, "")
+	default:
+		helper.ASM(w, 405, "")
+	}
+}
+
+// TokenHandler - rename and revoke token - @PUT | @DELETE | @OPTIONS - /account/tokens/:id
+func (t *Token) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		helper.ASM(w, 204, "")
+		return
+	}
+
+	if r.Method != "PUT" && r.Method != "DELETE" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	id, ok := t.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	tid := mux.Vars(r)["id"]
+
+	var err error
+	if r.Method == "PUT" {
+		n := r.FormValue("name")
+		if n == "" {
+			helper.ASM(w, 403, "name is empty")
+			return
+		}
+
+		err = t.conn.RenameToken(id, tid, n)
+	} else {
+		err = t.conn.RevokeToken(id, tid)
+	}
+
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "token not found")
+	case err != nil:
+		helper.ASM(w, 500, "")
+	case r.Method == "PUT":
+		helper.ASM(w, 200, "token renamed")
+	default:
+		helper.ASM(w, 200, "token revoked")
+	}
+}
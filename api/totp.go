@@ -0,0 +1,322 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/dchest/uniuri"
+	"github.com/jackc/pgx/v4"
+)
+
+// pending two factor logins
+const (
+	challengeCookie   = "mfa_challenge"
+	challengeTTL      = 5 * time.Minute
+	challengeAttempts = 5
+)
+
+// recoveryChars - no characters that look alike
+var recoveryChars = []byte("abcdefghjkmnpqrstuvwxyz23456789")
+
+// passwordMatches - compares password with the hash, accounts without a password never match
+func passwordMatches(h PasswordHasher, hash string, p string) bool {
+	if hash == model.NoPassword {
+		return false
+	}
+
+	ok, _ := h.Verify(hash, p)
+	return ok
+}
+
+// checkPassword - compares password with the hash of the user
+func (s *Account) checkPassword(id string, p string) bool {
+	hash, err := s.conn.GetPassword(id)
+	if err != nil {
+		return false
+	}
+
+	return passwordMatches(s.passwords, hash, p)
+}
+
+// newRecoveryCodes - ten recovery codes and their hashes
+func newRecoveryCodes() ([]string, []string) {
+	codes := make([]string, 10)
+	hashes := make([]string, 10)
+
+	for i := range codes {
+		c := uniuri.NewLenChars(10, recoveryChars)
+		codes[i] = c[:5] + "-" + c[5:]
+		hashes[i] = helper.HashToken(c)
+	}
+
+	return codes, hashes
+}
+
+// recoveryHash - hash of the recovery code the way it was saved
+func recoveryHash(c string) string {
+	c = strings.ToLower(c)
+	c = strings.ReplaceAll(c, "-", "")
+	c = strings.ReplaceAll(c, " ", "")
+	return helper.HashToken(c)
+}
+
+// secondFactor - checks "code" or "recoveryCode" form value of the user
+func (s *Account) secondFactor(r *http.Request, id string) (bool, error) {
+	if rc := r.FormValue("recoveryCode"); rc != "" {
+		return s.conn.UseRecoveryCode(id, recoveryHash(rc))
+	}
+
+	t, err := s.conn.GetTOTP(id)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := helper.ValidateTOTP(t.Secret, r.FormValue("code"), time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	// every code works only once
+	return s.conn.UseTOTPStep(id, step)
+}
+
+// LoginTOTPHandler - finishes two factor login - @POST - /account/login/totp
+func (s *Account) LoginTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if s.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	// challenge from form value or cookie
+	token := r.FormValue("challenge")
+	if c, err := r.Cookie(challengeCookie); token == "" && err == nil {
+		token = c.Value
+	}
+
+	if token == "" {
+		helper.ASM(w, 403, "log in with your password first")
+		return
+	}
+
+	byIP := attempt{loginIP, helper.ClientIP(r)}
+	if s.locked(w, byIP) {
+		return
+	}
+
+	id, err := s.conn.UseChallenge(helper.HashToken(token), challengeAttempts)
+	if err != nil {
+		helper.ASM(w, 403, err.Error())
+		return
+	}
+
+	ok, err := s.secondFactor(r, id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	if !ok {
+		s.failed(byIP)
+		helper.ASM(w, 403, "invalid two-factor code")
+		return
+	}
+
+	s.conn.DeleteChallenge(helper.HashToken(token))
+	http.SetCookie(w, &http.Cookie{Name: challengeCookie, Path: "/account/login", MaxAge: -1})
+
+	// Create New Session
+	err = s.store.SaveSession(w, r, id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	helper.ASM(w, 200, "logged in successfully")
+}
+
+// TOTPEnrollHandler - new secret for authenticator app - @POST - /account/totp/enroll
+func (s *Account) TOTPEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if !s.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	id, err := s.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	u, err := s.conn.GetUser(id)
+	if err != nil {
+		helper.ASM(w, 404, "")
+		return
+	}
+
+	secret := helper.NewTOTPSecret()
+	err = s.conn.SaveTOTPSecret(id, secret)
+	if err != nil {
+		helper.ASM(w, 409, err.Error())
+		return
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Files"
+	}
+
+	json.NewEncoder(w).Encode(model.TOTPEnroll{Secret: secret, URI: helper.TOTPURI(issuer, u.Email, secret)})
+}
+
+// TOTPConfirmHandler - enables two factor with the first code - @POST - /account/totp/confirm
+func (s *Account) TOTPConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if !s.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	id, err := s.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	t, err := s.conn.GetTOTP(id)
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "enroll first")
+		return
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return
+	case t.Confirmed:
+		helper.ASM(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+
+	step, ok := helper.ValidateTOTP(t.Secret, r.FormValue("code"), time.Now())
+	if !ok {
+		helper.ASM(w, 403, "invalid two-factor code")
+		return
+	}
+
+	if _, err = s.conn.UseTOTPStep(id, step); err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	codes, hashes := newRecoveryCodes()
+	err = s.conn.ConfirmTOTP(id, hashes)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	json.NewEncoder(w).Encode(model.RecoveryCodes{Codes: codes})
+}
+
+// RecoveryCodesHandler - replaces recovery codes - @POST - /account/totp/recovery-codes
+func (s *Account) RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if !s.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	id, err := s.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	ok, err := s.secondFactor(r, id)
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "two-factor authentication is not enabled")
+		return
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return
+	case !ok:
+		helper.ASM(w, 403, "invalid two-factor code")
+		return
+	}
+
+	codes, hashes := newRecoveryCodes()
+	err = s.conn.SaveRecoveryCodes(id, hashes)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	json.NewEncoder(w).Encode(model.RecoveryCodes{Codes: codes})
+}
+
+// TOTPHandler - disables two factor - @DELETE - /account/totp
+func (s *Account) TOTPHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		helper.ASM(w, 405, "")
+		return
+	}
+
+	if !s.store.AlreadyLoggedIn(r) {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	id, err := s.store.GetUser(r)
+	if err != nil {
+		helper.ASM(w, 401, "")
+		return
+	}
+
+	if !s.checkPassword(id, r.FormValue("password")) {
+		helper.ASM(w, 403, "password is wrong")
+		return
+	}
+
+	ok, err := s.secondFactor(r, id)
+	switch {
+	case err == pgx.ErrNoRows:
+		helper.ASM(w, 404, "two-factor authentication is not enabled")
+		return
+	case err != nil:
+		helper.ASM(w, 500, "")
+		return
+	case !ok:
+		helper.ASM(w, 403, "invalid two-factor code")
+		return
+	}
+
+	err = s.conn.DeleteTOTP(id)
+	if err != nil {
+		helper.ASM(w, 500, "")
+		return
+	}
+
+	helper.ASM(w, 200, "two-factor authentication disabled")
+}
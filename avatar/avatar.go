@@ -0,0 +1,227 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Sizes - square thumbnails made of every upload, DefaultSize is the one the api links to
+var Sizes = []int{32, 64, 128, 256}
+
+// DefaultSize - size of the avatar urls sent with users, questions and answers
+const DefaultSize = 128
+
+// upload limits, pixels are checked before the image is decoded
+const (
+	MaxUpload = 5 << 20
+	maxPixels = 25000000
+)
+
+// errors shown to the uploader
+var (
+	ErrFormat   = errors.New("avatar must be a jpeg, png or webp image")
+	ErrTooLarge = errors.New("image is too large")
+)
+
+// ValidSize - tells if thumbnails are made in the size
+func ValidSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// URL - link to the avatar of the size, the generated one when key is empty
+func URL(key string, uniqueName string, size int) string {
+	if key == "" {
+		return fmt.Sprintf("%savatars/default/%s/%d.png", os.Getenv("URL"), Seed(uniqueName), size)
+	}
+
+	return fmt.Sprintf("%savatars/%s/%d.png", os.Getenv("URL"), key, size)
+}
+
+// ObjectKey - storage key of the thumbnail of the avatar in the size
+func ObjectKey(key string, size int) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("avatar:%s:%d", key, size)))
+	return hex.EncodeToString(h[:])
+}
+
+// Seed - default avatar seed of the user, so unique names don't end up in urls
+func Seed(uniqueName string) string {
+	h := sha256.Sum256([]byte(uniqueName))
+	return hex.EncodeToString(h[:8])
+}
+
+// format - image format told by the magic bytes, whatever the file claims to be
+func format(b []byte) string {
+	switch {
+	case len(b) >= 3 && b[0] == 0xff && b[1] == 0xd8 && b[2] == 0xff:
+		return "jpeg"
+	case len(b) >= 8 && string(b[:8]) == "\x89PNG\r\n\x1a\n":
+		return "png"
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return "webp"
+	}
+
+	return ""
+}
+
+// decode - decodes the upload, refusing unknown formats and huge dimensions
+func decode(b []byte) (image.Image, error) {
+	var config func([]byte) (image.Config, error)
+	var dec func([]byte) (image.Image, error)
+
+	switch format(b) {
+	case "jpeg":
+		config = func(b []byte) (image.Config, error) { return jpeg.DecodeConfig(bytes.NewReader(b)) }
+		dec = func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) }
+	case "png":
+		config = func(b []byte) (image.Config, error) { return png.DecodeConfig(bytes.NewReader(b)) }
+		dec = func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }
+	case "webp":
+		config = func(b []byte) (image.Config, error) { return webp.DecodeConfig(bytes.NewReader(b)) }
+		dec = func(b []byte) (image.Image, error) { return webp.Decode(bytes.NewReader(b)) }
+	default:
+		return nil, ErrFormat
+	}
+
+	c, err := config(b)
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	if c.Width <= 0 || c.Height <= 0 || c.Width*c.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, err := dec(b)
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	return img, nil
+}
+
+// Process - square png thumbnails of the upload in every size
+// only pixels are re-encoded, so exif and other metadata never leave the server,
+// the exif orientation is read first so photos of phones aren't turned on their side
+func Process(b []byte) (map[int][]byte, error) {
+	o := orientation(b)
+
+	img, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// center crop to a square
+	r := img.Bounds()
+	side := r.Dx()
+	if r.Dy() < side {
+		side = r.Dy()
+	}
+
+	x := r.Min.X + (r.Dx()-side)/2
+	y := r.Min.Y + (r.Dy()-side)/2
+	crop := image.Rect(x, y, x+side, y+side)
+
+	// the crop is centered however the upload is turned, so turning the small
+	// thumbnails gives the same pixels as turning the whole upload first
+	thumbs := make(map[int][]byte, len(Sizes))
+	for _, s := range Sizes {
+		dst := image.NewRGBA(image.Rect(0, 0, s, s))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, orient(dst, o)); err != nil {
+			return nil, err
+		}
+
+		thumbs[s] = buf.Bytes()
+	}
+
+	return thumbs, nil
+}
+
+// Save - puts the thumbnails of the user in the storage and returns their key
+// keys are taken from the content, so a new upload never shows a cached old one
+// the key is only saved once every size is stored, so no one sees half an avatar
+func Save(ctx context.Context, st storage.Storage, id string, thumbs map[int][]byte) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(id))
+	h.Write(thumbs[Sizes[len(Sizes)-1]])
+	key := hex.EncodeToString(h.Sum(nil))[:32]
+
+	for s, b := range thumbs {
+		if err := st.Put(ctx, ObjectKey(key, s), bytes.NewReader(b), int64(len(b)), "image/png"); err != nil {
+			return "", err
+		}
+	}
+
+	return key, nil
+}
+
+// Remove - deletes every thumbnail of the key
+func Remove(ctx context.Context, st storage.Storage, key string) error {
+	if key == "" {
+		return nil
+	}
+
+	for _, s := range Sizes {
+		if err := st.Delete(ctx, ObjectKey(key, s)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Default - generated avatar of the seed, a mirrored 5x5 pattern in a color taken from it
+func Default(seed string, size int) ([]byte, error) {
+	h := sha256.Sum256([]byte(seed))
+
+	bg := color.RGBA{240, 240, 240, 255}
+	fg := color.RGBA{h[0]/2 + 64, h[1]/2 + 64, h[2]/2 + 64, 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
+
+	cell := size / 6
+	margin := (size - 5*cell) / 2
+
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 3; col++ {
+			if h[3+row*3+col]&1 == 0 {
+				continue
+			}
+
+			for _, c := range []int{col, 4 - col} {
+				x := margin + c*cell
+				y := margin + row*cell
+				draw.Draw(img, image.Rect(x, y, x+cell, y+cell), &image.Uniform{fg}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,159 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/storage"
+)
+
+func TestSaveRemove(t *testing.T) {
+	ctx := context.Background()
+	st, err := storage.NewLocal(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Default("seed", 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbs, err := Process(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := Save(ctx, st, "u1", thumbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 32 {
+		t.Errorf("key %q", key)
+	}
+
+	for _, s := range Sizes {
+		o, err := st.Open(ctx, ObjectKey(key, s))
+		if err != nil {
+			t.Fatalf("size %d: %v", s, err)
+		}
+		if info := o.Info(); info.ContentType != "image/png" || info.Size != int64(len(thumbs[s])) {
+			t.Errorf("size %d: %+v", s, info)
+		}
+		o.Close()
+	}
+
+	// the same upload of another user gets another key
+	if other, _ := Save(ctx, st, "u2", thumbs); other == key {
+		t.Error("users share an avatar key")
+	}
+
+	if err := Remove(ctx, st, key); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range Sizes {
+		if _, err := st.Open(ctx, ObjectKey(key, s)); err != storage.ErrNotFound {
+			t.Errorf("size %d left after remove: %v", s, err)
+		}
+	}
+
+	if err := Remove(ctx, st, ""); err != nil {
+		t.Errorf("removing the generated avatar: %v", err)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	if _, err := Process([]byte("GIF89a")); err != ErrFormat {
+		t.Errorf("gif = %v", err)
+	}
+
+	img, _ := Default("seed", 64)
+	thumbs, err := Process(img)
+	if err != nil || len(thumbs) != len(Sizes) {
+		t.Fatalf("Process = %d thumbs, %v", len(thumbs), err)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 3x2 pixels numbered row by row
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i)
+	}
+
+	tests := []struct {
+		o        int
+		w, h     int
+		topLeft  uint8
+		topRight uint8
+	}{
+		{1, 3, 2, 0, 2},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 5, 3},
+		{4, 3, 2, 3, 5},
+		{5, 2, 3, 0, 3},
+		{6, 2, 3, 3, 0},
+		{7, 2, 3, 5, 2},
+		{8, 2, 3, 2, 5},
+	}
+
+	for _, tt := range tests {
+		img := orient(src, tt.o)
+		b := img.Bounds()
+		if b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orientation %d: %dx%d, want %dx%d", tt.o, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+
+		tl := color.GrayModel.Convert(img.At(b.Min.X, b.Min.Y)).(color.Gray).Y
+		tr := color.GrayModel.Convert(img.At(b.Max.X-1, b.Min.Y)).(color.Gray).Y
+		if tl != tt.topLeft || tr != tt.topRight {
+			t.Errorf("orientation %d: corners %d %d, want %d %d", tt.o, tl, tr, tt.topLeft, tt.topRight)
+		}
+	}
+}
+
+func TestProcessOrientation(t *testing.T) {
+	// 60x40, red on top and blue below, taken with the phone turned: orientation 6
+	b, err := os.ReadFile("testdata/orientation6.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o := orientation(b); o != 6 {
+		t.Fatalf("orientation = %d, want 6", o)
+	}
+
+	thumbs, err := Process(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(thumbs[32]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// turned clockwise the red top ends up on the right
+	red := func(c color.Color) bool {
+		r, _, b, _ := c.RGBA()
+		return r > 2*b
+	}
+	if red(img.At(4, 16)) || !red(img.At(28, 16)) {
+		t.Errorf("thumbnail isn't turned: left %v right %v", img.At(4, 16), img.At(28, 16))
+	}
+
+	// pngs and jpegs without exif stay as they are
+	plain, _ := Default("seed", 64)
+	if o := orientation(plain); o != 1 {
+		t.Errorf("png orientation = %d", o)
+	}
+	if o := orientation(b[:2]); o != 1 {
+		t.Errorf("truncated jpeg orientation = %d", o)
+	}
+}
@@ -0,0 +1,135 @@
+package avatar
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// orientationTag - exif tag telling how the camera was held
+const orientationTag = 0x0112
+
+// orientation - exif orientation of a jpeg from 1 to 8, 1 when there is none
+func orientation(b []byte) int {
+	if format(b) != "jpeg" {
+		return 1
+	}
+
+	// segments up to the image data, exif is in APP1
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			return 1
+		}
+
+		marker := b[i+1]
+		switch {
+		case marker == 0xff:
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd8):
+			i += 2
+			continue
+		case marker == 0xd9 || marker == 0xda:
+			return 1
+		}
+
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) {
+			return 1
+		}
+
+		seg := b[i+4 : i+2+n]
+		if marker == 0xe1 && len(seg) >= 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+
+		i += 2 + n
+	}
+
+	return 1
+}
+
+// tiffOrientation - orientation entry of the first ifd of the exif tiff
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+
+	var bo binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+
+	if bo.Uint16(t[2:]) != 42 {
+		return 1
+	}
+
+	ifd := int64(bo.Uint32(t[4:]))
+	if ifd < 8 || ifd+2 > int64(len(t)) {
+		return 1
+	}
+
+	n := int64(bo.Uint16(t[ifd:]))
+	for k := int64(0); k < n; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > int64(len(t)) {
+			return 1
+		}
+
+		// a SHORT, which fits in the value field
+		if bo.Uint16(t[e:]) == orientationTag && bo.Uint16(t[e+2:]) == 3 {
+			if o := int(bo.Uint16(t[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+
+	return 1
+}
+
+// orient - turns and mirrors the image the way the exif orientation says
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+
+	r := img.Bounds()
+	w, h := r.Dx(), r.Dy()
+
+	// 5 to 8 swap width and height
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(r.Min.X+sx, r.Min.Y+sy))
+		}
+	}
+
+	return dst
+}
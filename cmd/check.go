@@ -3,6 +3,7 @@ package cmd
 import (
 	"log"
 
+	"github.com/Hamaiz/go-rest-eg/avatar"
 	"github.com/Hamaiz/go-rest-eg/database"
 	"github.com/spf13/cobra"
 )
@@ -17,7 +18,7 @@ var checkCmd = &cobra.Command{
 		`,
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Println("check called")
-		database.DeleteAccount()
+		database.DeleteAccount(avatar.Remove)
 	},
 }
 
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Hamaiz/go-rest-eg/session"
+	"github.com/spf13/cobra"
+)
+
+// jwtKeyCmd represents the jwt-key command
+var jwtKeyCmd = &cobra.Command{
+	Use:   "jwt-key",
+	Short: "jwt-key prints a new signing key for JWT_KEYS",
+	Long: `jwt-key prints a new ed25519 signing key as kid:seed.
+		To rotate keys put the new key first in JWT_KEYS and keep the old one
+		after it until every access token signed with it has expired.
+		`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(session.NewJWTKey())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jwtKeyCmd)
+}
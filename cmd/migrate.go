@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/Hamaiz/go-rest-eg/database"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "migrate manages the database schema",
+	Long: `migrate applies the SQL migrations embedded in the binary.
+		Applied versions are recorded in the schema_migrations table and
+		a postgres advisory lock makes sure only one instance migrates at a time.
+		`,
+}
+
+// migrateUpCmd represents the migrate up command
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "apply all pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := newMigrator()
+
+		if err := m.Up(); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("database is up to date")
+	},
+}
+
+// migrateDownCmd represents the migrate down command
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [N]",
+	Short: "roll back the last N migrations (default 1)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		n := 1
+		if len(args) == 1 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil {
+				log.Fatalf("invalid number of migrations %q", args[0])
+			}
+		}
+
+		m := newMigrator()
+
+		if err := m.Down(n); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("rolled back", n, "migration(s)")
+	},
+}
+
+// migrateToCmd represents the migrate to command
+var migrateToCmd = &cobra.Command{
+	Use:   "to VERSION",
+	Short: "migrate up or down to the given version",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			log.Fatalf("invalid version %q", args[0])
+		}
+
+		m := newMigrator()
+
+		if err := m.To(v); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("database is at version", v)
+	},
+}
+
+// migrateStatusCmd represents the migrate status command
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "list migrations and whether they are applied",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := newMigrator()
+
+		sts, err := m.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, s := range sts {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Printf("%04d  %-30s  %s\n", s.Version, s.Name, applied)
+		}
+	},
+}
+
+// newMigrator - connects to postgres and returns the migrator
+func newMigrator() *database.Migrator {
+	conn, err := database.DBConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m, err := database.NewMigrator(conn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return m
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateToCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
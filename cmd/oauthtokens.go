@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Hamaiz/go-rest-eg/database"
+	"github.com/Hamaiz/go-rest-eg/oauth"
+	"github.com/spf13/cobra"
+)
+
+// oauthTokensCmd represents the oauth-tokens command
+var oauthTokensCmd = &cobra.Command{
+	Use:   "oauth-tokens",
+	Short: "oauth-tokens manages the keys of stored provider tokens",
+	Long: `oauth-tokens manages the OAUTH_TOKEN_KEYS that encrypt provider tokens.
+		To rotate keys put a new key first in OAUTH_TOKEN_KEYS, run oauth-tokens rotate
+		and remove the old key once it is done.
+		`,
+}
+
+// oauthTokensKeyCmd represents the oauth-tokens key command
+var oauthTokensKeyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "print a new key for OAUTH_TOKEN_KEYS",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(oauth.NewTokenKey())
+	},
+}
+
+// oauthTokensRotateCmd represents the oauth-tokens rotate command
+var oauthTokensRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "re-encrypt stored provider tokens with the first key",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keyring, err := oauth.LoadKeyring()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if !keyring.Enabled() {
+			log.Fatal("OAUTH_TOKEN_KEYS is empty")
+		}
+
+		providers, err := oauth.LoadRegistry()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		conn, err := database.DBConn()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+
+		db := database.NewOauthDatabase(conn)
+		tokens := oauth.NewTokens(providers, keyring, db)
+
+		ts, err := db.GetTokens()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		n := 0
+		for _, t := range ts {
+			ok, err := tokens.Rotate(t)
+			if err != nil {
+				log.Println("could not rotate", t.Provider, "token of", t.Subject+":", err)
+				continue
+			}
+			if ok {
+				n++
+			}
+		}
+
+		log.Println("re-encrypted", n, "of", len(ts), "token(s)")
+	},
+}
+
+func init() {
+	oauthTokensCmd.AddCommand(oauthTokensKeyCmd)
+	oauthTokensCmd.AddCommand(oauthTokensRotateCmd)
+	rootCmd.AddCommand(oauthTokensCmd)
+}
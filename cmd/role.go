@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"log"
+	"strings"
+
+	"github.com/Hamaiz/go-rest-eg/database"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/spf13/cobra"
+)
+
+// roleCmd represents the role command
+var roleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "role grants and revokes moderator and admin roles",
+}
+
+// roleGrantCmd represents the role grant command
+var roleGrantCmd = &cobra.Command{
+	Use:   "grant USER ROLE",
+	Short: "give the user (id or email) a role",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !model.ValidRole(args[1]) {
+			log.Fatalf("unknown role %q", args[1])
+		}
+
+		setRole(args[0], func(string) string { return args[1] })
+	},
+}
+
+// roleRevokeCmd represents the role revoke command
+var roleRevokeCmd = &cobra.Command{
+	Use:   "revoke USER ROLE",
+	Short: "take a role from the user (id or email)",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setRole(args[0], func(current string) string {
+			if current != args[1] {
+				log.Fatalf("user does not have role %q", args[1])
+			}
+			return model.RoleUser
+		})
+	},
+}
+
+// setRole - sets the role of the user to next(current role) and audits it
+func setRole(user string, next func(string) string) {
+	conn, err := database.DBConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	id := userID(conn, user)
+
+	roles := database.NewRoleDatabase(conn)
+	current, err := roles.GetRole(id)
+	if err != nil {
+		log.Fatalf("no user with id %q", id)
+	}
+
+	role := next(current)
+	if err := roles.SetRole(id, role); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := roles.Audit("", "set-role", "account", id, current+" -> "+role); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("role of", id, "is now", role)
+}
+
+// userID - id of the user given by id or email
+func userID(conn *pgxpool.Pool, user string) string {
+	if !strings.Contains(user, "@") {
+		return user
+	}
+
+	u, err := database.NewAccountDatabase(conn).GetUserInLogin(user)
+	if err != nil {
+		log.Fatalf("no user with email %q", user)
+	}
+
+	return u.ID
+}
+
+func init() {
+	roleCmd.AddCommand(roleGrantCmd)
+	roleCmd.AddCommand(roleRevokeCmd)
+	rootCmd.AddCommand(roleCmd)
+}
@@ -7,12 +7,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// skipSchemaCheck - start even if the database schema doesn't match
+var skipSchemaCheck bool
+
 // serveCmd represents the serve command
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "start http server with configured api",
 	Run: func(cmd *cobra.Command, args []string) {
-		server, err := serve.NewServer()
+		server, err := serve.NewServer(!skipSchemaCheck)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -21,5 +24,6 @@ var serveCmd = &cobra.Command{
 }
 
 func init() {
+	serveCmd.Flags().BoolVar(&skipSchemaCheck, "skip-schema-check", false, "start without verifying the database schema")
 	rootCmd.AddCommand(serveCmd)
 }
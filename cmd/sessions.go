@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/Hamaiz/go-rest-eg/database"
+	"github.com/spf13/cobra"
+)
+
+// sessionsCmd represents the sessions command
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "sessions manages logged in sessions of users",
+}
+
+// sessionsRevokeCmd represents the sessions revoke command
+var sessionsRevokeCmd = &cobra.Command{
+	Use:   "revoke USER",
+	Short: "log the user (id or email) out everywhere",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, err := database.DBConn()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+
+		id := userID(conn, args[0])
+
+		n, err := database.NewSessionDatabase(conn).RevokeAllSessions(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("revoked", n, "session(s) of", id)
+	},
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsRevokeCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
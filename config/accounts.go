@@ -4,22 +4,26 @@ import (
 	"github.com/Hamaiz/go-rest-eg/api"
 	"github.com/Hamaiz/go-rest-eg/database"
 	"github.com/Hamaiz/go-rest-eg/helper"
-	"github.com/Hamaiz/go-rest-eg/session"
-	"github.com/globalsign/mgo"
+	"github.com/Hamaiz/go-rest-eg/storage"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // NewAccountSubRouter - accounts subrouter
-func NewAccountSubRouter(s *mux.Router, dbsess *mgo.Session, conn *pgxpool.Pool) {
-	// getting store
-	store := session.StoreConn(dbsess)
+func NewAccountSubRouter(s *mux.Router, store api.AccountStore, conn *pgxpool.Pool, passwords api.PasswordHasher, media storage.Storage) {
 	newAccount := database.NewAccountDatabase(conn)
+	newTokens := database.NewTokenDatabase(conn)
+	newSessions := database.NewSessionDatabase(conn)
 
 	// newaccountstore sending store
-	a := api.NewAccountStore(store, newAccount)
+	a := api.NewAccountStore(store, newAccount, passwords)
+	t := api.NewTokenApi(store, newTokens)
+	ss := api.NewSessionApi(store, newSessions)
+	p := api.NewProfileApi(store, database.NewProfileDatabase(conn), media)
 
 	// Routes - /accounts
+	s.HandleFunc("", helper.JH(a.DeleteAccountHandler))
+	s.HandleFunc("/export", helper.JH(a.ExportHandler))
 	s.HandleFunc("/getUser", helper.JH(a.GetUserHandler))
 	s.HandleFunc("/login", helper.JH(a.LogInHandler))
 	s.HandleFunc("/signup", helper.JH(a.SignUpHandler))
@@ -29,4 +33,26 @@ func NewAccountSubRouter(s *mux.Router, dbsess *mgo.Session, conn *pgxpool.Pool)
 	s.HandleFunc("/confirm-pass/{token}", helper.JH(a.ConfirmPassHandler))
 	s.HandleFunc("/confirm/{token}", helper.JH(a.ConfirmEmailHandler))
 	s.HandleFunc("/againemail", helper.JH(a.EmailAgain))
+	s.HandleFunc("/password", helper.JH(a.PasswordHandler))
+	s.HandleFunc("/email", helper.JH(a.ChangeEmailHandler))
+	s.HandleFunc("/confirm-email/{token}", helper.JH(a.ConfirmEmailChangeHandler))
+	s.HandleFunc("/magic-link", helper.JH(a.MagicLinkHandler))
+	s.HandleFunc("/magic-link/{token}", helper.JH(a.MagicLinkLoginHandler))
+	s.HandleFunc("/profile", helper.JH(p.ProfileHandler))
+	s.HandleFunc("/avatar", helper.JH(p.AvatarHandler))
+
+	// Routes - /accounts/totp
+	s.HandleFunc("/login/totp", helper.JH(a.LoginTOTPHandler))
+	s.HandleFunc("/totp", helper.JH(a.TOTPHandler))
+	s.HandleFunc("/totp/enroll", helper.JH(a.TOTPEnrollHandler))
+	s.HandleFunc("/totp/confirm", helper.JH(a.TOTPConfirmHandler))
+	s.HandleFunc("/totp/recovery-codes", helper.JH(a.RecoveryCodesHandler))
+
+	// Routes - /accounts/sessions
+	s.HandleFunc("/sessions", helper.JH(ss.SessionsHandler))
+	s.HandleFunc("/sessions/{id}", helper.JH(ss.SessionHandler))
+
+	// Routes - /accounts/tokens
+	s.HandleFunc("/tokens", helper.JH(t.TokensHandler))
+	s.HandleFunc("/tokens/{id}", helper.JH(t.TokenHandler))
 }
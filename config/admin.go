@@ -0,0 +1,34 @@
+package serve
+
+import (
+	"github.com/Hamaiz/go-rest-eg/api"
+	"github.com/Hamaiz/go-rest-eg/database"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/middleware"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// NewAdminSubRouter - admin subrouter, every route checks the role of the user
+func NewAdminSubRouter(s *mux.Router, store api.AccountStore, conn *pgxpool.Pool) {
+	roles := database.NewRoleDatabase(conn)
+	perm := middleware.NewPermissions(store, roles)
+
+	a := api.NewAdminApi(store, database.NewAdminDatabase(conn), roles)
+
+	// only administrators manage users, moderators may suspend and ban plain users
+	users := perm.Require(model.PermManageUsers)
+	bans := perm.Require(model.PermBanUser)
+
+	// Routes - /admin
+	s.Handle("/users", users(helper.JH(a.UsersHandler)))
+	s.Handle("/users/{id}", users(helper.JH(a.UserHandler)))
+	s.Handle("/users/{id}/suspend", bans(helper.JH(a.SuspendHandler)))
+	s.Handle("/users/{id}/ban", bans(helper.JH(a.BanHandler)))
+	s.Handle("/users/{id}/verify-email", users(helper.JH(a.VerifyEmailHandler)))
+	s.Handle("/users/{id}/reset-password", users(helper.JH(a.ResetPasswordHandler)))
+	s.Handle("/settings", perm.Require(model.PermManageSettings)(helper.JH(a.SettingsHandler)))
+	s.Handle("/settings/{name}", perm.Require(model.PermManageSettings)(helper.JH(a.SettingHandler)))
+	s.Handle("/audit-log", perm.Require(model.PermViewAuditLog)(helper.JH(a.AuditLogHandler)))
+}
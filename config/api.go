@@ -1,25 +1,79 @@
 package serve
 
 import (
+	"log"
 	"net/http"
+	"os"
 
+	"github.com/Hamaiz/go-rest-eg/api"
 	"github.com/Hamaiz/go-rest-eg/database"
 	"github.com/Hamaiz/go-rest-eg/helper"
 	"github.com/Hamaiz/go-rest-eg/middleware"
+	"github.com/Hamaiz/go-rest-eg/oauth"
+	"github.com/Hamaiz/go-rest-eg/password"
 	"github.com/Hamaiz/go-rest-eg/session"
+	"github.com/Hamaiz/go-rest-eg/storage"
 	"github.com/gorilla/mux"
 )
 
 // New - starts the api
-func New() (*mux.Router, error) {
-	// mongodb connection session
-	dbsess, err := session.DBConn()
+func New(checkSchema bool) (*mux.Router, error) {
+	// postgresql connection
+	conn, err := database.DBConn()
 	if err != nil {
 		return nil, err
 	}
 
-	// postgresql connection
-	conn, err := database.DBConn()
+	// refuse to start on a schema the repositories can't read
+	if checkSchema {
+		log.Println("verifying database schema...")
+		if err := database.VerifySchema(conn); err != nil {
+			return nil, err
+		}
+	}
+
+	// session store shared by every router
+	base, err := NewStore(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// personal access tokens work with every store
+	store := session.NewTokenStore(base, database.NewTokenDatabase(conn))
+
+	// oauth providers from OAUTH_PROVIDERS
+	providers, err := oauth.LoadRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	// provider tokens are encrypted with OAUTH_TOKEN_KEYS
+	keyring, err := oauth.LoadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	// password hashing from PASSWORD_HASH, ARGON2_* and BCRYPT_COST
+	passwords, err := password.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// uploaded media and avatars from STORAGE, /media urls are signed with the session key
+	signer := storage.NewSigner([]byte(os.Getenv("SESSION_KEY")), os.Getenv("URL"))
+	media, err := storage.Load(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	// attachment sizes from ATTACHMENT_*
+	limits, err := LoadAttachmentLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	// csrf tokens are signed with the session key
+	csrf, err := middleware.NewCSRF([]byte(os.Getenv("SESSION_KEY")), os.Getenv("FRONTEND"), os.Getenv("URL"))
 	if err != nil {
 		return nil, err
 	}
@@ -30,15 +84,31 @@ func New() (*mux.Router, error) {
 	// middlewares
 	r.Use(middleware.LoggingMiddleware)
 	r.Use(middleware.UsefulHeaders)
+	r.Use(middleware.DeleteForm)
+	r.Use(csrf.Protect)
 
 	// subrouter - apiAccounts
 	apiAccounts := r.PathPrefix("/account").Subrouter()
 	apiFiles := r.PathPrefix("/api").Subrouter()
+	apiAdmin := r.PathPrefix("/admin").Subrouter()
+	apiUsers := r.PathPrefix("/users").Subrouter()
+	apiAvatars := r.PathPrefix("/avatars").Subrouter()
+	apiMedia := r.PathPrefix("/media").Subrouter()
 
 	// account router - /account
-	NewAccountSubRouter(apiAccounts, dbsess, conn)
-	NewOauthSubRouter(apiAccounts, dbsess, conn)
-	NewFilesSubRouter(apiFiles, dbsess, conn)
+	NewAccountSubRouter(apiAccounts, store, conn, passwords, media)
+	apiAccounts.HandleFunc("/csrf", helper.JH(api.NewCSRFApi(csrf).CSRFHandler))
+	NewOauthSubRouter(apiAccounts, store, conn, providers, keyring, passwords)
+	NewFilesSubRouter(apiFiles, store, conn, media, limits)
+	NewAdminSubRouter(apiAdmin, store, conn)
+	NewUsersSubRouter(apiUsers, store, conn, media)
+	NewAvatarSubRouter(apiAvatars, store, conn, media)
+	NewMediaSubRouter(apiMedia, media, signer)
+
+	// token routes - only with the jwt store
+	if js, ok := base.(api.JWTStore); ok {
+		NewJWTSubRouter(r, apiAccounts, js)
+	}
 
 	// static files
 	helper.AllStaticFiles(r)
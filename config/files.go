@@ -1,23 +1,56 @@
 package serve
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+
 	"github.com/Hamaiz/go-rest-eg/api"
 	"github.com/Hamaiz/go-rest-eg/database"
 	"github.com/Hamaiz/go-rest-eg/helper"
-	"github.com/Hamaiz/go-rest-eg/session"
-	"github.com/globalsign/mgo"
+	"github.com/Hamaiz/go-rest-eg/storage"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// LoadAttachmentLimits - limits from ATTACHMENT_MAX_MB, ATTACHMENT_QUOTA_MB and ATTACHMENT_MAX_PER_POST
+func LoadAttachmentLimits() (api.AttachmentLimits, error) {
+	l := api.AttachmentLimits{MaxSize: 10 << 20, Quota: 100 << 20, MaxPerPost: 10}
+
+	for _, e := range []struct {
+		name  string
+		dest  *int64
+		scale int64
+	}{
+		{"ATTACHMENT_MAX_MB", &l.MaxSize, 1 << 20},
+		{"ATTACHMENT_QUOTA_MB", &l.Quota, 1 << 20},
+	} {
+		if s := os.Getenv(e.name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 32)
+			if err != nil || n < 1 {
+				return l, fmt.Errorf("%s must be a positive number", e.name)
+			}
+			*e.dest = n * e.scale
+		}
+	}
+
+	if s := os.Getenv("ATTACHMENT_MAX_PER_POST"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return l, fmt.Errorf("ATTACHMENT_MAX_PER_POST must be a number")
+		}
+		l.MaxPerPost = n
+	}
+
+	return l, nil
+}
+
 // NewAccountSubRouter - accounts subrouter
-func NewFilesSubRouter(s *mux.Router, dbsess *mgo.Session, conn *pgxpool.Pool) {
-	// getting store
-	store := session.StoreConn(dbsess)
+func NewFilesSubRouter(s *mux.Router, store api.AccountStore, conn *pgxpool.Pool, media storage.Storage, limits api.AttachmentLimits) {
 	newFiles := database.NewFilesDatabase(conn)
 
 	// newaccountstore sending store
-	f := api.NewFilesApi(store, newFiles)
+	f := api.NewFilesApi(store, newFiles, database.NewRoleDatabase(conn), media, limits)
 
 	// Routes - /accounts
 	s.HandleFunc("/search", helper.JH(f.SearchQuestionHandler))
@@ -29,7 +62,10 @@ func NewFilesSubRouter(s *mux.Router, dbsess *mgo.Session, conn *pgxpool.Pool) {
 	s.HandleFunc("/answer/{slug}", helper.JH(f.SendAnswerHandler))
 	s.HandleFunc("/add-answer/{ans}", helper.JH(f.CreateAnswerHandler))
 	s.HandleFunc("/edit-answer/{ans}", helper.JH(f.EditAnswerHandler))
+	s.HandleFunc("/delete-question/{q}", helper.JH(f.DeleteQuestionHandler))
+	s.HandleFunc("/delete-answer/{ans}", helper.JH(f.DeleteAnswerHandler))
 	s.HandleFunc("/like", helper.JH(f.LikesHandler))
 	s.HandleFunc("/dislike", helper.JH(f.DislikesHandler))
 	s.HandleFunc("/get-likes", helper.JH(f.GetLikesHandler))
+	s.HandleFunc("/attachments", helper.JH(f.UploadAttachmentHandler))
 }
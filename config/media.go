@@ -0,0 +1,15 @@
+package serve
+
+import (
+	"github.com/Hamaiz/go-rest-eg/api"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/gorilla/mux"
+)
+
+// NewMediaSubRouter - uploaded media subrouter
+func NewMediaSubRouter(s *mux.Router, st storage.Storage, sg *storage.Signer) {
+	b := api.NewBlobsApi(st, sg)
+
+	// Routes - /media
+	s.HandleFunc("/{key:[0-9a-f]{64}}", b.MediaHandler)
+}
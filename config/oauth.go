@@ -1,25 +1,36 @@
 package serve
 
 import (
+	"log"
+
 	"github.com/Hamaiz/go-rest-eg/api"
 	"github.com/Hamaiz/go-rest-eg/database"
 	"github.com/Hamaiz/go-rest-eg/helper"
-	"github.com/Hamaiz/go-rest-eg/session"
-	"github.com/globalsign/mgo"
+	"github.com/Hamaiz/go-rest-eg/oauth"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // NewOauthSubRouter - oauth accounts subrouter
-func NewOauthSubRouter(s *mux.Router, dbsess *mgo.Session, conn *pgxpool.Pool) {
-	// getting store
-	store := session.StoreConn(dbsess)
+func NewOauthSubRouter(s *mux.Router, store api.AccountStore, conn *pgxpool.Pool, providers *oauth.Registry, keyring *oauth.Keyring, passwords api.PasswordHasher) {
 	newoauth := database.NewOauthDatabase(conn)
+	tokens := oauth.NewTokens(providers, keyring, newoauth)
 
 	// newaccountstore sending store
-	o := api.NewOauthApi(store, newoauth)
+	login := api.NewLoginApi(store, database.NewAccountDatabase(conn))
+	o := api.NewOauthApi(store, newoauth, providers, tokens, passwords, login)
+
+	log.Println("oauth providers:", providers.Names())
+	if !keyring.Enabled() {
+		log.Println("OAUTH_TOKEN_KEYS is empty, provider tokens aren't stored")
+	}
+
+	// routes - /accounts/identities
+	s.HandleFunc("/identities", helper.JH(o.IdentitiesHandler))
+	s.HandleFunc("/identities/confirm", helper.JH(o.ConfirmLinkHandler))
 
 	// routes - /accounts
-	s.HandleFunc("/google/login", helper.JH(o.GoogleLoginHandler))
-	s.HandleFunc("/google/callback", helper.JH(o.GoogleCallbackHandler))
+	s.HandleFunc("/{provider}/login", helper.JH(o.LoginHandler))
+	s.HandleFunc("/{provider}/link", helper.JH(o.LinkHandler))
+	s.HandleFunc("/{provider}/callback", helper.JH(o.CallbackHandler))
 }
@@ -36,7 +36,8 @@ func init() {
 }
 
 // NewServer - Starts the http server
-func NewServer() (*Server, error) {
+// checkSchema compares the database schema with the repositories before serving
+func NewServer(checkSchema bool) (*Server, error) {
 	log.Println("configuring server...")
 
 	// getting port from env variables
@@ -45,7 +46,7 @@ func NewServer() (*Server, error) {
 	var addr string
 
 	// get the handler - ./api.go
-	api, err := New()
+	api, err := New(checkSchema)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +82,7 @@ func (srv *Server) Start() {
 	log.Printf("Listening on %v...", srv.Addr)
 
 	// logic for graceful shutdown
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	sig := <-quit
 
@@ -0,0 +1,73 @@
+package serve
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/Hamaiz/go-rest-eg/api"
+	"github.com/Hamaiz/go-rest-eg/database"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/session"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// NewStore - account store picked with AUTH_BACKEND (session | jwt)
+func NewStore(conn *pgxpool.Pool) (session.Store, error) {
+	switch os.Getenv("AUTH_BACKEND") {
+	case "jwt":
+		log.Println("setting up jwt store...")
+		return session.NewJWTStore(database.NewSessionDatabase(conn))
+	default:
+		cookies, err := newCookieStore(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		return session.StoreConn(cookies, database.NewSessionDatabase(conn))
+	}
+}
+
+// newCookieStore - cookie session store picked with SESSION_STORE (mongo | postgres | redis | memory)
+func newCookieStore(conn *pgxpool.Pool) (sessions.Store, error) {
+	switch os.Getenv("SESSION_STORE") {
+	case session.BackendPostgres:
+		log.Println("keeping sessions in postgres")
+		return session.NewBackendStore(database.NewHTTPSessionDatabase(conn)), nil
+	case session.BackendRedis:
+		log.Println("connecting to redis...")
+		b, err := session.NewRedisBackend(os.Getenv("REDIS_URL"))
+		if err != nil {
+			return nil, err
+		}
+
+		return session.NewBackendStore(b), nil
+	case session.BackendMemory:
+		log.Println("keeping sessions in memory, they are lost on restart")
+		return session.NewBackendStore(session.NewMemoryBackend()), nil
+	case "", session.BackendMongo:
+		// mongodb connection session
+		dbsess, err := session.DBConn()
+		if err != nil {
+			return nil, err
+		}
+
+		return session.NewMongoStore(dbsess), nil
+	default:
+		return nil, errors.New("SESSION_STORE must be mongo, postgres, redis or memory")
+	}
+}
+
+// NewJWTSubRouter - token routes of the jwt store
+func NewJWTSubRouter(r *mux.Router, s *mux.Router, store api.JWTStore) {
+	j := api.NewJWTApi(store)
+
+	// routes - /account/token
+	s.HandleFunc("/token/refresh", helper.JH(j.RefreshHandler))
+	s.HandleFunc("/token/revoke", helper.JH(j.RevokeHandler))
+
+	// public keys
+	r.HandleFunc("/.well-known/jwks.json", helper.JH(j.JWKSHandler))
+}
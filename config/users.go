@@ -0,0 +1,29 @@
+package serve
+
+import (
+	"github.com/Hamaiz/go-rest-eg/api"
+	"github.com/Hamaiz/go-rest-eg/database"
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// NewUsersSubRouter - public profiles subrouter
+func NewUsersSubRouter(s *mux.Router, store api.AccountStore, conn *pgxpool.Pool, media storage.Storage) {
+	p := api.NewProfileApi(store, database.NewProfileDatabase(conn), media)
+
+	// Routes - /users
+	s.HandleFunc("/{uniqueName}", helper.JH(p.UserHandler))
+	s.HandleFunc("/{uniqueName}/questions", helper.JH(p.UserQuestionsHandler))
+	s.HandleFunc("/{uniqueName}/answers", helper.JH(p.UserAnswersHandler))
+}
+
+// NewAvatarSubRouter - avatar images subrouter
+func NewAvatarSubRouter(s *mux.Router, store api.AccountStore, conn *pgxpool.Pool, media storage.Storage) {
+	p := api.NewProfileApi(store, database.NewProfileDatabase(conn), media)
+
+	// Routes - /avatars
+	s.HandleFunc("/default/{seed:[0-9a-f]{16}}/{size:[0-9]+}.png", p.DefaultAvatarHandler)
+	s.HandleFunc("/{key:[0-9a-f]{32}}/{size:[0-9]+}.png", p.AvatarFileHandler)
+}
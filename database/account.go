@@ -13,12 +13,13 @@ import (
 
 // AccountDatabase - struct that holds functions for putting users in database
 type AccountDatabase struct {
-	conn *pgxpool.Pool
+	conn    *pgxpool.Pool
+	lockout Lockout
 }
 
 // NewAccountDatabase - returns AccountDatabase
 func NewAccountDatabase(conn *pgxpool.Pool) *AccountDatabase {
-	return &AccountDatabase{conn}
+	return &AccountDatabase{conn, lockoutConfig()}
 }
 
 // CheckingExists - checks if email provided already exists
@@ -33,23 +34,38 @@ func (a *AccountDatabase) CheckingExists(e string) bool {
 	}
 }
 
-// InsertUser - inserts user into database and return error
-func (a *AccountDatabase) InsertUser(u model.User, t string) error {
-	id := uuid.New()
-	expires := time.Now().Local().Add(time.Hour * time.Duration(6))
+// emailTokenTTL - how long email confirmation and password reset links work
+const emailTokenTTL = 6 * time.Hour
 
-	_, err := a.conn.Exec(context.Background(), "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, $2, $3, $4, $5)", id, u.Name, u.Email, u.Password, u.UniqueName)
+// InsertUser - inserts user with the hashed email confirmation token
+func (a *AccountDatabase) InsertUser(u model.User, hash string) error {
+	ctx := context.Background()
+	id := uuid.New().String()
 
-	_, err = a.conn.Exec(context.Background(), "INSERT INTO addition (expires, token, account_id) VALUES ($1, $2, $3)", expires, t, id)
+	tx, err := a.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, $2, $3, $4, $5)", id, u.Name, u.Email, u.Password, u.UniqueName)
+	if err != nil {
+		return err
+	}
 
-	return err
+	err = issueToken(tx, id, model.PurposeVerifyEmail, hash, "", time.Now().Add(emailTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 // GetUser - gets user from databasae
 func (a *AccountDatabase) GetUser(id string) (model.UserSend, error) {
 	u := model.UserSend{}
-	row := a.conn.QueryRow(context.Background(), "SELECT username, email, unique_name FROM account WHERE id=$1", id)
-	err := row.Scan(&u.Name, &u.Email, &u.UnqiueName)
+	row := a.conn.QueryRow(context.Background(), "SELECT username, email, unique_name, coalesce(avatar, '') FROM account WHERE id=$1", id)
+	err := row.Scan(&u.Name, &u.Email, &u.UnqiueName, &u.AvatarKey)
 	return u, err
 }
 
@@ -61,180 +77,140 @@ func (a *AccountDatabase) GetUserInLogin(email string) (model.UserGet, error) {
 	return u, err
 }
 
-// LoginConfirm - checks if login confirms
-func (a *AccountDatabase) LoginConfirm(e string) bool {
-	var id string
+// GetPassword - gets password hash of the user
+func (a *AccountDatabase) GetPassword(id string) (string, error) {
+	var p string
+	err := a.conn.QueryRow(context.Background(), "SELECT password FROM account WHERE id=$1", id).Scan(&p)
+	return p, err
+}
 
-	// with email get id
-	row := a.conn.QueryRow(context.Background(), "SELECT id FROM account WHERE email=$1", e)
-	err := row.Scan(&id)
+// LoginConfirm - checks if the email is confirmed
+func (a *AccountDatabase) LoginConfirm(e string) bool {
+	var confirmed bool
+	err := a.conn.QueryRow(context.Background(), "SELECT email_confirmed FROM account WHERE email=$1", e).Scan(&confirmed)
 
-	// if email not found return false
-	if err != nil {
-		return false
-	}
+	return err == nil && confirmed
+}
 
-	// with id get additional data from database
-	var confirm bool
-	token := a.conn.QueryRow(context.Background(), "SELECT confirmed FROM addition WHERE account_id=$1", id)
-	err = token.Scan(&confirm)
+// ConfirmEmail - confirms the email with the hashed token
+func (a *AccountDatabase) ConfirmEmail(hash string) (bool, error) {
+	ctx := context.Background()
 
+	tx, err := a.conn.Begin(ctx)
 	if err != nil {
-		return false
+		return false, err
 	}
+	defer tx.Rollback(ctx)
 
-	return confirm
-}
-
-// ConfirmEmail - confirms the token from email
-func (a *AccountDatabase) ConfirmEmail(t string) (bool, error) {
-	et := model.EmailToken{}
-
-	// check in the database if token exists
-	row := a.conn.QueryRow(context.Background(), "SELECT * FROM addition WHERE token=$1", t)
-	err := row.Scan(&et.Confirmed, &et.Expires, &et.Token, &et.Account_id)
-
-	// if no rows return false and with error
-	if err == pgx.ErrNoRows {
-		err = errors.New("no user found with token")
+	id, _, err := consumeToken(tx, hash, model.PurposeVerifyEmail)
+	switch {
+	case err == ErrTokenNotFound:
+		return false, errors.New("no user found with token")
+	case err == ErrTokenUsed:
+		return true, errors.New("email already confirmed")
+	case err == ErrTokenExpired:
 		return false, err
+	case err != nil:
+		return false, errors.New("problem occured")
 	}
 
-	if et.Confirmed {
-		err = errors.New("email already confirmed")
-		return true, err
+	_, err = tx.Exec(ctx, "UPDATE account SET email_confirmed=true WHERE id=$1", id)
+	if err != nil {
+		return false, errors.New("problem occured")
 	}
 
-	// if no error - check the expiry time (which is 6hrs)
-	e, _ := time.Parse("2006-01-02 15:04:05", et.Expires)
-	c := time.Now().Local().After(e)
-
-	if !c {
-		err = errors.New("token expired")
-		return false, err
+	// links sent again stop working too
+	if err = consumeTokens(tx, id, model.PurposeVerifyEmail); err != nil {
+		return false, errors.New("problem occured")
 	}
 
-	// if token not expired, change token to nil and confirmed to false
-	_, err = a.conn.Exec(context.Background(), "UPDATE addition SET token=$1, confirmed=$2 WHERE account_id=$3", "", true, et.Account_id)
-
-	if err != nil {
-		err = errors.New("problem occured")
-		return false, err
+	if err = tx.Commit(ctx); err != nil {
+		return false, errors.New("problem occured")
 	}
 
 	return true, nil
 }
 
-// GetToken - gets the token again for email
-func (a *AccountDatabase) GetToken(e string) (string, error) {
+// EmailToken - saves another hashed email confirmation token, earlier ones keep working
+func (a *AccountDatabase) EmailToken(e string, hash string) error {
 	var id string
-	row := a.conn.QueryRow(context.Background(), "SELECT id FROM account WHERE email=$1", e)
-	err := row.Scan(&id)
-
-	if err != nil {
-		err = errors.New("not found")
-		return "", err
-	}
-
-	var token string
-	var expires time.Time
 	var confirmed bool
-	row = a.conn.QueryRow(context.Background(), "SELECT token, expires, confirmed FROM addition WHERE account_id=$1", id)
-	err = row.Scan(&token, &expires, &confirmed)
+	err := a.conn.QueryRow(context.Background(), "SELECT id, email_confirmed FROM account WHERE email=$1", e).Scan(&id, &confirmed)
 
-	if err != nil {
-		err = errors.New("token not found")
-		return "", err
-	}
-
-	if confirmed {
-		err = errors.New("email already confirmed")
-		return "", err
-	}
-
-	exp, _ := time.Parse("2006-01-02 15:04:05", expires.String())
-	c := time.Now().Local().After(exp)
-
-	if !c {
-		err = errors.New("token expired")
-		return "", err
+	switch {
+	case err == pgx.ErrNoRows:
+		return errors.New("not found")
+	case err != nil:
+		return err
+	case confirmed:
+		return errors.New("email already confirmed")
 	}
 
-	return token, nil
+	return issueToken(a.conn, id, model.PurposeVerifyEmail, hash, "", time.Now().Add(emailTokenTTL))
 }
 
 // == pass reset ==//
 
-// ForgotToken - add forgot token
-func (a *AccountDatabase) ForgotToken(e string, token string) error {
+// ForgotToken - saves hashed password reset token
+func (a *AccountDatabase) ForgotToken(e string, hash string) error {
 	// get id from email
 	var id string
-	row := a.conn.QueryRow(context.Background(), "SELECT id FROM account WHERE email=$1", e)
-	err := row.Scan(&id)
-
-	if err != nil {
-		return err
-	}
-
-	t := time.Now().UTC().Add(time.Hour * time.Duration(6))
-	_, err = a.conn.Exec(context.Background(), "UPDATE addition SET token=$1, expires=$2 WHERE account_id=$3", token, t, id)
-
+	err := a.conn.QueryRow(context.Background(), "SELECT id FROM account WHERE email=$1", e).Scan(&id)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return issueToken(a.conn, id, model.PurposeResetPassword, hash, "", time.Now().Add(emailTokenTTL))
 }
 
-// ConfirmToken - confirm passwrod token
-func (a *AccountDatabase) ConfirmToken(token string) (bool, error) {
-	var expires time.Time
-	row := a.conn.QueryRow(context.Background(), "SELECT expires FROM addition WHERE token=$1", token)
-	err := row.Scan(&expires)
-
+// ConfirmToken - tells if the hashed password reset token can be used
+func (a *AccountDatabase) ConfirmToken(hash string) (bool, error) {
+	_, err := checkToken(a.conn, hash, model.PurposeResetPassword)
 	switch {
-	case err == pgx.ErrNoRows:
-		err = errors.New("token not found")
+	case err == ErrTokenNotFound || err == ErrTokenUsed || err == ErrTokenExpired:
 		return false, err
 	case err != nil:
-		err = errors.New("an error occured")
-		return false, err
-	}
-
-	// check if token expired
-	e, _ := time.Parse("2006-01-02 15:04:05", expires.String())
-	c := time.Now().UTC().After(e)
-
-	if !c {
-		err = errors.New("token expired")
-		return false, err
+		return false, errors.New("an error occured")
 	}
 
 	return true, nil
 }
 
-// ResetPass - reset password
-func (a *AccountDatabase) ResetPass(p string, t string) error {
-	var id string
-	row := a.conn.QueryRow(context.Background(), "SELECT account_id FROM addition WHERE token=$1", t)
-	err := row.Scan(&id)
+// ResetPass - uses the hashed token to reset password, other reset links stop working
+func (a *AccountDatabase) ResetPass(p string, hash string) error {
+	ctx := context.Background()
 
+	tx, err := a.conn.Begin(ctx)
 	if err != nil {
-		err = errors.New("an error occured")
-		return err
+		return errors.New("an error occured")
 	}
+	defer tx.Rollback(ctx)
 
-	_, err = a.conn.Exec(context.Background(), "UPDATE account SET password=$1 WHERE id=$1", p, id)
-	if err != nil {
-		err = errors.New("error occured changing password")
+	id, _, err := consumeToken(tx, hash, model.PurposeResetPassword)
+	switch {
+	case err == ErrTokenNotFound || err == ErrTokenUsed || err == ErrTokenExpired:
 		return err
+	case err != nil:
+		return errors.New("an error occured")
 	}
 
-	_, err = a.conn.Exec(context.Background(), "UPDATE addition SET token=$1, expires=$2 WHERE account_id=$3", "", nil, id)
+	_, err = tx.Exec(ctx, "UPDATE account SET password=$1 WHERE id=$2", p, id)
 	if err != nil {
-		err = errors.New("an error occured")
-		return err
+		return errors.New("error occured changing password")
+	}
+
+	if err = consumeTokens(tx, id, model.PurposeResetPassword); err != nil {
+		return errors.New("an error occured")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.New("an error occured")
 	}
 
 	return nil
 }
+
+// ActiveBan - ban of the user that hasn't expired, pgx.ErrNoRows if there is none
+func (a *AccountDatabase) ActiveBan(id string) (model.Ban, error) {
+	return NewAdminDatabase(a.conn).ActiveBan(id)
+}
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// AdminDatabase - user management for administrators
+type AdminDatabase struct {
+	conn *pgxpool.Pool
+}
+
+// NewAdminDatabase - returns AdminDatabase
+func NewAdminDatabase(conn *pgxpool.Pool) *AdminDatabase {
+	return &AdminDatabase{conn}
+}
+
+// loginHistory - how many logins the profile shows
+const loginHistory = 50
+
+// activeBan - sql condition for bans that haven't expired
+const activeBan = "(account_ban.expires_at IS NULL OR account_ban.expires_at>now())"
+
+// adminUserColumns - columns scanned by scanAdminUser
+const adminUserColumns = "account.id, account.username, account.email, account.unique_name, account.role, account.email_confirmed, account_ban.kind, account_ban.reason, account_ban.expires_at, account_ban.created_at FROM account LEFT JOIN account_ban ON account_ban.account_id=account.id AND " + activeBan
+
+// likeEscaper - search text is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// scanAdminUser - scans adminUserColumns, extra are scanned after them
+func scanAdminUser(row pgx.Row, extra ...interface{}) (model.AdminUser, error) {
+	u := model.AdminUser{}
+
+	var kind, reason *string
+	var expires, created *time.Time
+	dest := append([]interface{}{&u.ID, &u.Username, &u.Email, &u.UniqueName, &u.Role, &u.Confirmed, &kind, &reason, &expires, &created}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return u, err
+	}
+
+	if kind != nil {
+		u.Ban = &model.Ban{Kind: *kind, Reason: *reason, ExpiresAt: expires, CreatedAt: *created}
+	}
+
+	return u, nil
+}
+
+// SearchUsers - users whose id, name or email contain q, all users when q is empty
+func (d *AdminDatabase) SearchUsers(q string, limit int, offset int) (model.UserPage, error) {
+	p := model.UserPage{Users: make([]model.AdminUser, 0), Limit: limit, Offset: offset}
+
+	rows, err := d.conn.Query(context.Background(), "SELECT "+adminUserColumns+", count(*) OVER () WHERE $1='' OR account.id=$1 OR account.username ILIKE '%' || $2 || '%' OR account.email ILIKE '%' || $2 || '%' OR account.unique_name ILIKE '%' || $2 || '%' ORDER BY account.username, account.id LIMIT $3 OFFSET $4", q, likeEscaper.Replace(q), limit, offset)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanAdminUser(rows, &p.Total)
+		if err != nil {
+			return p, err
+		}
+		p.Users = append(p.Users, u)
+	}
+
+	return p, rows.Err()
+}
+
+// GetAdminUser - user by id, pgx.ErrNoRows if there is none
+func (d *AdminDatabase) GetAdminUser(id string) (model.AdminUser, error) {
+	return scanAdminUser(d.conn.QueryRow(context.Background(), "SELECT "+adminUserColumns+" WHERE account.id=$1", id))
+}
+
+// GetProfile - user with content counts, login history and identities
+func (d *AdminDatabase) GetProfile(id string) (model.AdminProfile, error) {
+	ctx := context.Background()
+
+	u, err := d.GetAdminUser(id)
+	if err != nil {
+		return model.AdminProfile{}, err
+	}
+
+	p := model.AdminProfile{AdminUser: u, Logins: make([]model.Login, 0)}
+
+	row := d.conn.QueryRow(ctx, "SELECT (SELECT count(*) FROM question WHERE poster=$1), (SELECT count(*) FROM answer WHERE commenter=$1), (SELECT count(*) FROM vote WHERE user_id=$1 AND (likes OR dislike))", id)
+	if err := row.Scan(&p.Counts.Questions, &p.Counts.Answers, &p.Counts.Votes); err != nil {
+		return p, err
+	}
+
+	rows, err := d.conn.Query(ctx, "SELECT id, created_at, last_seen_at, revoked_at, ip, user_agent FROM user_session WHERE account_id=$1 ORDER BY created_at DESC LIMIT $2", id, loginHistory)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := model.Login{}
+		if err := rows.Scan(&l.SessionID, &l.CreatedAt, &l.LastSeenAt, &l.RevokedAt, &l.IP, &l.UserAgent); err != nil {
+			return p, err
+		}
+		p.Logins = append(p.Logins, l)
+	}
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	p.Identities, err = NewOauthDatabase(d.conn).GetIdentities(id)
+
+	return p, err
+}
+
+// ActiveBan - ban of the user that hasn't expired, pgx.ErrNoRows if there is none
+func (d *AdminDatabase) ActiveBan(id string) (model.Ban, error) {
+	b := model.Ban{}
+	row := d.conn.QueryRow(context.Background(), "SELECT kind, reason, expires_at, created_at FROM account_ban WHERE account_id=$1 AND "+activeBan, id)
+	err := row.Scan(&b.Kind, &b.Reason, &b.ExpiresAt, &b.CreatedAt)
+
+	return b, err
+}
+
+// Ban - suspends or bans the user and logs them out everywhere,
+// access tokens and jwt refresh tokens stop working with the sessions
+// a new ban replaces the one before it
+func (d *AdminDatabase) Ban(id string, b model.Ban, actor string) error {
+	ctx := context.Background()
+
+	tx, err := d.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "INSERT INTO account_ban (account_id, kind, reason, expires_at, created_by) VALUES ($1, $2, $3, $4, NULLIF($5, '')) ON CONFLICT (account_id) DO UPDATE SET kind=excluded.kind, reason=excluded.reason, expires_at=excluded.expires_at, created_by=excluded.created_by, created_at=now()", id, b.Kind, b.Reason, b.ExpiresAt, actor)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE user_session SET revoked_at=now() WHERE account_id=$1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM refresh_token WHERE session_id IN (SELECT id FROM user_session WHERE account_id=$1)", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE access_token SET revoked_at=now() WHERE account_id=$1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Unban - lifts the ban of the user, pgx.ErrNoRows if there is none
+func (d *AdminDatabase) Unban(id string) error {
+	ct, err := d.conn.Exec(context.Background(), "DELETE FROM account_ban WHERE account_id=$1 AND "+activeBan, id)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// ForceVerify - marks the email of the user as confirmed
+func (d *AdminDatabase) ForceVerify(id string) error {
+	_, err := d.conn.Exec(context.Background(), "UPDATE account SET email_confirmed=true WHERE id=$1", id)
+
+	return err
+}
+
+// ForceReset - removes the password of the user, logs them out everywhere
+// and saves a hashed reset token, so only the emailed link can set a new one
+func (d *AdminDatabase) ForceReset(id string, hash string, expires time.Time) error {
+	ctx := context.Background()
+
+	tx, err := d.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "UPDATE account SET password=$1 WHERE id=$2", model.NoPassword, id)
+	if err != nil {
+		return err
+	}
+
+	if err = issueToken(tx, id, model.PurposeResetPassword, hash, "", expires); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE user_session SET revoked_at=now() WHERE account_id=$1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE access_token SET revoked_at=now() WHERE account_id=$1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteUser - deletes the account and everything of it, pgx.ErrNoRows if there is none
+func (d *AdminDatabase) DeleteUser(id string) error {
+	ct, err := d.conn.Exec(context.Background(), "DELETE FROM account WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/google/uuid"
+)
+
+func TestBanRevokesTokens(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	if _, err := conn.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, 'Ann', $2, 'x', $1)", id, id+"@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Exec(ctx, "DELETE FROM account WHERE id=$1", id) })
+
+	sessions := NewSessionDatabase(conn)
+	sid, err := sessions.CreateSession(id, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.InsertRefresh(sid, "refresh-"+id, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := NewTokenDatabase(conn)
+	if _, err := tokens.InsertToken(id, "ci", "read", "pat-"+id); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := NewAdminDatabase(conn)
+	until := time.Now().Add(time.Hour)
+	if err := admin.Ban(id, model.Ban{Kind: model.BanSuspend, Reason: "spam", ExpiresAt: &until}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// lifting the ban doesn't bring the tokens back
+	if err := admin.Unban(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := tokens.LookupToken("pat-" + id); err == nil {
+		t.Error("access token works after the ban")
+	}
+	if _, _, err := sessions.RotateRefresh("refresh-"+id, "next-"+id, time.Now().Add(time.Hour)); err == nil {
+		t.Error("refresh token works after the ban")
+	}
+
+	var n int
+	conn.QueryRow(ctx, "SELECT count(*) FROM refresh_token WHERE session_id=$1", sid).Scan(&n)
+	if n != 0 {
+		t.Errorf("%d refresh tokens left", n)
+	}
+}
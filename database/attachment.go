@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"log"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// == attachments ==//
+
+// attachmentColumns - columns every attachment query reads
+const attachmentColumns = "id, coalesce(owner_id, ''), blob_key, filename, content_type, size, created_at"
+
+// advisory lock classes, the second key is the hashtext of the owner or blob key
+const (
+	quotaLock = 7240120
+	blobLock  = 7240121
+)
+
+// AddAttachment - saves an upload that isn't attached yet, model.ErrQuota when the
+// uploads of the owner would take more than quota bytes
+// put stores the blob before the transaction, so no connection waits for the storage,
+// the quota is checked and the row inserted with the owner and the blob key locked,
+// so concurrent uploads can't both fit the quota; a blob nobody uses is purged again
+func (f *FilesDatabase) AddAttachment(a model.Attachment, quota int64, st storage.Storage, put func() error) error {
+	if err := put(); err != nil {
+		return err
+	}
+
+	err := f.insertAttachment(a, quota)
+	if err != nil {
+		if perr := purgeBlob(f.conn, st, a.BlobKey); perr != nil {
+			log.Println("could not delete blob:", perr)
+		}
+	}
+
+	return err
+}
+
+// insertAttachment - inserts the attachment if it fits the quota of the owner
+func (f *FilesDatabase) insertAttachment(a model.Attachment, quota int64) error {
+	ctx := context.Background()
+
+	tx, err := f.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// always the owner first, purges only take the blob lock
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", quotaLock, a.Owner); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", blobLock, a.BlobKey); err != nil {
+		return err
+	}
+
+	var used int64
+	if err = tx.QueryRow(ctx, "SELECT coalesce(sum(size), 0) FROM attachment WHERE owner_id=$1", a.Owner).Scan(&used); err != nil {
+		return err
+	}
+
+	if used+a.Size > quota {
+		return model.ErrQuota
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO attachment (id, owner_id, blob_key, filename, content_type, size) VALUES ($1, $2, $3, $4, $5, $6)",
+		a.ID, a.Owner, a.BlobKey, a.Filename, a.ContentType, a.Size)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// AttachmentUsage - bytes of every upload of the user, attached or not
+func (f *FilesDatabase) AttachmentUsage(owner string) (int64, error) {
+	var n int64
+	err := f.conn.QueryRow(context.Background(), "SELECT coalesce(sum(size), 0) FROM attachment WHERE owner_id=$1", owner).Scan(&n)
+
+	return n, err
+}
+
+// attach - links unattached uploads of the owner with set, ErrAttachment unless all of them are
+func attach(tx pgx.Tx, ids []string, owner string, set string, args ...interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ct, err := tx.Exec(context.Background(), "UPDATE attachment SET "+set+" WHERE id=ANY($1) AND owner_id=$2 AND question_id IS NULL AND answer_question_id IS NULL",
+		append([]interface{}{ids, owner}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() != int64(len(ids)) {
+		return model.ErrAttachment
+	}
+
+	return nil
+}
+
+// questionAttachments - attachments of the question, oldest first
+func (f *FilesDatabase) questionAttachments(id string) ([]model.Attachment, error) {
+	as := make([]model.Attachment, 0)
+
+	rows, err := f.conn.Query(context.Background(), "SELECT "+attachmentColumns+" FROM attachment WHERE question_id=$1 ORDER BY created_at, id", id)
+	if err != nil {
+		return as, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := model.Attachment{}
+		if err := rows.Scan(&a.ID, &a.Owner, &a.BlobKey, &a.Filename, &a.ContentType, &a.Size, &a.CreatedAt); err != nil {
+			return as, err
+		}
+		as = append(as, a)
+	}
+
+	return as, rows.Err()
+}
+
+// answerAttachments - attachments of the answers of the commenters to the question by commenter, oldest first
+func (f *FilesDatabase) answerAttachments(id string, commenters []string) (map[string][]model.Attachment, error) {
+	as := make(map[string][]model.Attachment)
+
+	rows, err := f.conn.Query(context.Background(), "SELECT answer_commenter, "+attachmentColumns+" FROM attachment WHERE answer_question_id=$1 AND answer_commenter=ANY($2) ORDER BY created_at, id", id, commenters)
+	if err != nil {
+		return as, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c string
+		a := model.Attachment{}
+		if err := rows.Scan(&c, &a.ID, &a.Owner, &a.BlobKey, &a.Filename, &a.ContentType, &a.Size, &a.CreatedAt); err != nil {
+			return as, err
+		}
+		as[c] = append(as[c], a)
+	}
+
+	return as, rows.Err()
+}
+
+// PurgeAttachments - deletes uploads attached to nothing for a day, and blobs no attachment uses anymore
+// uploads of deleted questions and answers end up unattached too
+func PurgeAttachments(conn *pgxpool.Pool, st storage.Storage) {
+	ctx := context.Background()
+
+	rows, err := conn.Query(ctx, "DELETE FROM attachment WHERE question_id IS NULL AND answer_question_id IS NULL AND created_at < now() - interval '1 day' RETURNING blob_key")
+	if err != nil {
+		log.Println("could not purge attachments:", err)
+		return
+	}
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			rows.Close()
+			log.Println("could not purge attachments:", err)
+			return
+		}
+		keys[k] = true
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Println("could not purge attachments:", err)
+		return
+	}
+
+	// keys are the content, so other uploads of the same file share the blob
+	for k := range keys {
+		if err := purgeBlob(conn, st, k); err != nil {
+			log.Println("could not delete blob:", err)
+		}
+	}
+}
+
+// purgeBlob - deletes the blob unless an attachment uses it, the key stays
+// locked until it's gone so an upload of the same file waits for it
+func purgeBlob(conn *pgxpool.Pool, st storage.Storage, key string) error {
+	ctx := context.Background()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", blobLock, key); err != nil {
+		return err
+	}
+
+	var used bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM attachment WHERE blob_key=$1)", key).Scan(&used)
+	if err != nil || used {
+		return err
+	}
+
+	if err = st.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/storage"
+	"github.com/google/uuid"
+)
+
+// testAccount - account deleted with everything it owns when the test ends
+func testAccount(t *testing.T, f *FilesDatabase) string {
+	t.Helper()
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	if _, err := f.conn.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, 'Ann', $2, 'x', $1)", id, id+"@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.conn.Exec(ctx, "DELETE FROM attachment WHERE owner_id=$1", id)
+		f.conn.Exec(ctx, "DELETE FROM account WHERE id=$1", id)
+	})
+
+	return id
+}
+
+func TestAddAttachmentQuota(t *testing.T) {
+	f := NewFilesDatabase(testDB(t))
+	owner := testAccount(t, f)
+	ctx := context.Background()
+
+	st, err := storage.NewLocal(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ten uploads at once, only three fit
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	stored, refused := 0, 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			a := model.Attachment{ID: uuid.New().String(), Owner: owner, BlobKey: strings.Repeat("a", 64), Filename: "a.txt", ContentType: "text/plain; charset=utf-8", Size: 100}
+			err := f.AddAttachment(a, 300, st, func() error { return nil })
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				stored++
+			case model.ErrQuota:
+				refused++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stored != 3 || refused != 7 {
+		t.Errorf("%d stored and %d refused, want 3 and 7", stored, refused)
+	}
+
+	// the blob of a refused upload nobody else uses is deleted again
+	up, err := storage.Spool(strings.NewReader("refused"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+
+	a := model.Attachment{ID: uuid.New().String(), Owner: owner, BlobKey: up.Key, Filename: "b.txt", ContentType: "text/plain; charset=utf-8", Size: up.Size}
+	if err := f.AddAttachment(a, 300, st, func() error { return up.Put(ctx, st, a.ContentType) }); err != model.ErrQuota {
+		t.Fatalf("upload over the quota: %v", err)
+	}
+	if o, err := st.Open(ctx, up.Key); err == nil {
+		o.Close()
+		t.Error("blob of the refused upload was kept")
+	}
+}
+
+func TestPurgeAttachments(t *testing.T) {
+	conn := testDB(t)
+	f := NewFilesDatabase(conn)
+	owner := testAccount(t, f)
+	ctx := context.Background()
+
+	st, err := storage.NewLocal(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(content string) string {
+		up, err := storage.Spool(strings.NewReader(content), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer up.Close()
+
+		a := model.Attachment{ID: uuid.New().String(), Owner: owner, BlobKey: up.Key, Filename: "a.txt", ContentType: "text/plain; charset=utf-8", Size: up.Size}
+		if err := f.AddAttachment(a, 1000, st, func() error { return up.Put(ctx, st, a.ContentType) }); err != nil {
+			t.Fatal(err)
+		}
+
+		return a.ID
+	}
+
+	// two uploads of the same file, only one of them is old
+	old := add("shared")
+	add("shared")
+	alone := add("alone")
+
+	if _, err := conn.Exec(ctx, "UPDATE attachment SET created_at=now() - interval '2 days' WHERE id=ANY($1)", []string{old, alone}); err != nil {
+		t.Fatal(err)
+	}
+
+	PurgeAttachments(conn, st)
+
+	exists := func(content string) bool {
+		sum := sha256.Sum256([]byte(content))
+		o, err := st.Open(ctx, hex.EncodeToString(sum[:]))
+		if err == nil {
+			o.Close()
+		}
+		return err == nil
+	}
+
+	if !exists("shared") {
+		t.Error("blob of a fresh upload was deleted")
+	}
+	if exists("alone") {
+		t.Error("blob no attachment uses was kept")
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Lockout - when and how long keys get locked after failures
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// lockoutConfig - reads LOCKOUT_* env variables
+func lockoutConfig() Lockout {
+	l := Lockout{5, time.Minute, time.Hour, 24 * time.Hour}
+
+	if v := os.Getenv("LOCKOUT_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Println("invalid LOCKOUT_THRESHOLD, using", l.Threshold)
+		} else {
+			l.Threshold = n
+		}
+	}
+
+	for _, d := range []struct {
+		env string
+		dst *time.Duration
+	}{{"LOCKOUT_BASE", &l.Base}, {"LOCKOUT_MAX", &l.Max}, {"LOCKOUT_WINDOW", &l.Window}} {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+
+		p, err := time.ParseDuration(v)
+		if err != nil || p <= 0 {
+			log.Println("invalid", d.env+", using", *d.dst)
+			continue
+		}
+
+		*d.dst = p
+	}
+
+	return l
+}
+
+// backoff - lock duration after n failures, doubles every failure over the threshold
+func (l Lockout) backoff(n int) time.Duration {
+	d := l.Base
+	for i := l.Threshold; i < n && d < l.Max; i++ {
+		d *= 2
+	}
+
+	if d > l.Max {
+		d = l.Max
+	}
+
+	return d
+}
+
+// == brute force ==//
+
+// Locked - how long the key is still locked, 0 if it isn't
+func (a *AccountDatabase) Locked(scope string, key string) (time.Duration, error) {
+	var until time.Time
+	err := a.conn.QueryRow(context.Background(), "SELECT locked_until FROM login_attempt WHERE scope=$1 AND key=$2 AND locked_until>now()", scope, key).Scan(&until)
+
+	switch {
+	case err == pgx.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+
+	return time.Until(until), nil
+}
+
+// Failed - counts a failure and locks the key once there are too many
+// returns the lock duration and whether this failure started the lockout
+func (a *AccountDatabase) Failed(scope string, key string) (time.Duration, bool, error) {
+	ctx := context.Background()
+
+	// failures older than the window are forgotten
+	var n int
+	row := a.conn.QueryRow(ctx, "INSERT INTO login_attempt (scope, key, failures, last_failure) VALUES ($1, $2, 1, now()) ON CONFLICT (scope, key) DO UPDATE SET failures=CASE WHEN login_attempt.last_failure<$3 THEN 1 ELSE login_attempt.failures+1 END, last_failure=now() RETURNING failures", scope, key, time.Now().Add(-a.lockout.Window))
+	if err := row.Scan(&n); err != nil {
+		return 0, false, err
+	}
+
+	if n < a.lockout.Threshold {
+		return 0, false, nil
+	}
+
+	d := a.lockout.backoff(n)
+	_, err := a.conn.Exec(ctx, "UPDATE login_attempt SET locked_until=$1 WHERE scope=$2 AND key=$3", time.Now().Add(d), scope, key)
+
+	return d, n == a.lockout.Threshold, err
+}
+
+// Succeeded - forgets the failures of the key
+func (a *AccountDatabase) Succeeded(scope string, key string) error {
+	_, err := a.conn.Exec(context.Background(), "DELETE FROM login_attempt WHERE scope=$1 AND key=$2", scope, key)
+
+	return err
+}
+
+// LockoutAddress - stored email of the account a lowercase key belongs to
+func (a *AccountDatabase) LockoutAddress(key string) (string, error) {
+	var e string
+	err := a.conn.QueryRow(context.Background(), "SELECT email FROM account WHERE lower(email)=$1 LIMIT 1", key).Scan(&e)
+
+	return e, err
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLockoutAddress(t *testing.T) {
+	a := NewAccountDatabase(testDB(t))
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	e := "Ann." + id + "@Example.com"
+	if _, err := a.conn.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, 'Ann', $2, 'x', $1)", id, e); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.conn.Exec(ctx, "DELETE FROM account WHERE id=$1", id) })
+
+	// attempts are keyed by the lowercase email, the notice goes to the stored one
+	got, err := a.LockoutAddress(strings.ToLower(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != e {
+		t.Errorf("LockoutAddress = %q, want %q", got, e)
+	}
+
+	if _, err := a.LockoutAddress("nobody." + id + "@example.com"); err == nil {
+		t.Error("unknown email has an address")
+	}
+}
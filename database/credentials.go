@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgconn"
+)
+
+// == credentials ==//
+
+// ChangePassword - saves new password hash of the user
+func (a *AccountDatabase) ChangePassword(id string, p string) error {
+	_, err := a.conn.Exec(context.Background(), "UPDATE account SET password=$1 WHERE id=$2", p, id)
+
+	return err
+}
+
+// RehashPassword - replaces the hash the user logged in with, a password
+// changed in the meantime is left alone
+func (a *AccountDatabase) RehashPassword(id string, old string, p string) error {
+	_, err := a.conn.Exec(context.Background(), "UPDATE account SET password=$2 WHERE id=$1 AND password=$3", id, p, old)
+
+	return err
+}
+
+// RevokeOtherSessions - revokes every session of the user except keep
+func (a *AccountDatabase) RevokeOtherSessions(id string, keep string) error {
+	return NewSessionDatabase(a.conn).RevokeOtherSessions(id, keep)
+}
+
+// EmailChangeToken - saves hashed token of a pending email change, replacing an older one
+func (a *AccountDatabase) EmailChangeToken(id string, e string, hash string, expires time.Time) error {
+	ctx := context.Background()
+
+	tx, err := a.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = consumeTokens(tx, id, model.PurposeChangeEmail); err != nil {
+		return err
+	}
+
+	if err = issueToken(tx, id, model.PurposeChangeEmail, hash, e, expires); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ConfirmEmailChange - swaps the email of the token's user and returns the user
+func (a *AccountDatabase) ConfirmEmailChange(hash string) (string, error) {
+	ctx := context.Background()
+
+	tx, err := a.conn.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	id, e, err := consumeToken(tx, hash, model.PurposeChangeEmail)
+	switch {
+	case err == ErrTokenNotFound:
+		return "", errors.New("no email change found with token")
+	case err == ErrTokenUsed || err == ErrTokenExpired:
+		return "", err
+	case err != nil:
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE account SET email=$1 WHERE id=$2", e, id)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return "", errors.New("email already exists")
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, tx.Commit(ctx)
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRehashPassword(t *testing.T) {
+	conn := testDB(t)
+	a := NewAccountDatabase(conn)
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	if _, err := conn.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, 'Ann', $2, 'old', $1)", id, id+"@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Exec(ctx, "DELETE FROM account WHERE id=$1", id) })
+
+	// a password changed after the login isn't overwritten
+	if err := a.ChangePassword(id, "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RehashPassword(id, "old", "rehashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	var p string
+	conn.QueryRow(ctx, "SELECT password FROM account WHERE id=$1", id).Scan(&p)
+	if p != "changed" {
+		t.Fatalf("password %q, want the changed one", p)
+	}
+
+	if err := a.RehashPassword(id, "changed", "rehashed"); err != nil {
+		t.Fatal(err)
+	}
+	conn.QueryRow(ctx, "SELECT password FROM account WHERE id=$1", id).Scan(&p)
+	if p != "rehashed" {
+		t.Errorf("password %q, want the rehashed one", p)
+	}
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// testDB - connection to the migrated DATABASE_URL, the test is skipped without one
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	conn, err := pgxpool.Connect(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+
+	m, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := m.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != m.Latest() {
+		t.Skip("database is not migrated, run migrate up first")
+	}
+
+	return conn
+}
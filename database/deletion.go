@@ -0,0 +1,223 @@
+package database
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// deletion policies - what happens to questions and answers of deleted accounts
+const (
+	PolicyAnonymize = "anonymize"
+	PolicyRemove    = "remove"
+)
+
+// == account deletion ==//
+
+// RequestDeletion - schedules deletion of the user, an earlier request is kept
+func (a *AccountDatabase) RequestDeletion(id string, purgeAfter time.Time) error {
+	ctx := context.Background()
+
+	_, err := a.conn.Exec(ctx, "INSERT INTO account_deletion (account_id, purge_after) VALUES ($1, $2) ON CONFLICT (account_id) DO NOTHING", id, purgeAfter)
+	if err != nil {
+		return err
+	}
+
+	// nobody stays logged in, personal access tokens included
+	_, err = NewSessionDatabase(a.conn).RevokeAllSessions(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.conn.Exec(ctx, "UPDATE access_token SET revoked_at=now() WHERE account_id=$1 AND revoked_at IS NULL", id)
+
+	return err
+}
+
+// CancelDeletion - cancels pending deletion, tells if there was one
+func (a *AccountDatabase) CancelDeletion(id string) (bool, error) {
+	ct, err := a.conn.Exec(context.Background(), "DELETE FROM account_deletion WHERE account_id=$1 AND purged_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+
+	return ct.RowsAffected() > 0, nil
+}
+
+// ExportAccount - everything stored about the user
+func (a *AccountDatabase) ExportAccount(id string) (model.AccountExport, error) {
+	ctx := context.Background()
+	ex := model.AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Questions:  make([]model.FilesQuestion, 0),
+		Answers:    make([]model.FilesComment, 0),
+		Votes:      make([]model.ExportVote, 0),
+		Identities: make([]model.LinkedIdentity, 0),
+	}
+
+	p, err := a.GetUser(id)
+	if err != nil {
+		return ex, err
+	}
+	ex.Profile = p
+
+	rows, err := a.conn.Query(ctx, "SELECT id, question, poster, slug, created_at, updated_at FROM question WHERE poster=$1 ORDER BY created_at", id)
+	if err != nil {
+		return ex, err
+	}
+	for rows.Next() {
+		q := model.FilesQuestion{}
+		if err := rows.Scan(&q.ID, &q.Question, &q.Poster, &q.Slug, &q.Created_At, &q.Updated_At); err != nil {
+			rows.Close()
+			return ex, err
+		}
+		ex.Questions = append(ex.Questions, q)
+	}
+	rows.Close()
+
+	rows, err = a.conn.Query(ctx, "SELECT question_id, answer, commenter, created_at, updated_at FROM answer WHERE commenter=$1 ORDER BY created_at", id)
+	if err != nil {
+		return ex, err
+	}
+	for rows.Next() {
+		c := model.FilesComment{}
+		if err := rows.Scan(&c.Question_ID, &c.Answer, &c.Commenter, &c.Created_At, &c.Updated_At); err != nil {
+			rows.Close()
+			return ex, err
+		}
+		ex.Answers = append(ex.Answers, c)
+	}
+	rows.Close()
+
+	rows, err = a.conn.Query(ctx, "SELECT question_id, likes, dislike FROM vote WHERE user_id=$1", id)
+	if err != nil {
+		return ex, err
+	}
+	for rows.Next() {
+		v := model.ExportVote{}
+		if err := rows.Scan(&v.Question_ID, &v.Likes, &v.Dislike); err != nil {
+			rows.Close()
+			return ex, err
+		}
+		ex.Votes = append(ex.Votes, v)
+	}
+	rows.Close()
+
+	ex.Identities, err = NewOauthDatabase(a.conn).GetIdentities(id)
+	if err != nil {
+		return ex, err
+	}
+
+	return ex, nil
+}
+
+// deletionPolicy - DELETE_POLICY, anonymize unless set to remove
+func deletionPolicy() string {
+	if os.Getenv("DELETE_POLICY") == PolicyRemove {
+		return PolicyRemove
+	}
+
+	return PolicyAnonymize
+}
+
+// PurgeAccounts - deletes accounts whose grace period is over and
+// returns the avatar keys of the purged accounts, so their files can go too
+func PurgeAccounts(conn *pgxpool.Pool) []string {
+	policy := deletionPolicy()
+	keys := make([]string, 0)
+
+	rows, err := conn.Query(context.Background(), "SELECT account_id FROM account_deletion WHERE purged_at IS NULL AND purge_after<now()")
+	if err != nil {
+		log.Println("could not get accounts to delete:", err)
+		return keys
+	}
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		key, err := purgeAccount(conn, id, policy)
+		if err != nil {
+			log.Println("could not delete account", id+":", err)
+			continue
+		}
+
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// purgeAccount - removes the account with everything it posted, or
+// anonymizes it so questions and answers stay under "Deleted user"
+// the avatar key of the account is returned once the purge is committed
+func purgeAccount(conn *pgxpool.Pool, id string, policy string) (string, error) {
+	ctx := context.Background()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var e, key string
+	if err = tx.QueryRow(ctx, "SELECT email, coalesce(avatar, '') FROM account WHERE id=$1", id).Scan(&e, &key); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if policy == PolicyRemove {
+		// every other table cascades
+		_, err = tx.Exec(ctx, "DELETE FROM account WHERE id=$1", id)
+		if err != nil {
+			return "", err
+		}
+
+		return key, tx.Commit(ctx)
+	}
+
+	// the account stays as a tombstone nobody can log into
+	_, err = tx.Exec(ctx, "UPDATE account SET username='Deleted user', email=id || '@deleted.invalid', password=$2, unique_name='deleted-' || id, bio='', avatar=NULL, role='user' WHERE id=$1", id, model.NoPassword)
+	if err != nil {
+		return "", err
+	}
+
+	for _, q := range []string{
+		"DELETE FROM verification_token WHERE account_id=$1",
+		"DELETE FROM identity WHERE account_id=$1",
+		"DELETE FROM identity_link WHERE account_id=$1",
+		"DELETE FROM account_ban WHERE account_id=$1",
+		"DELETE FROM access_token WHERE account_id=$1",
+		"DELETE FROM user_session WHERE account_id=$1",
+		"DELETE FROM totp WHERE account_id=$1",
+		"DELETE FROM recovery_code WHERE account_id=$1",
+		"DELETE FROM mfa_challenge WHERE account_id=$1",
+		"UPDATE account_deletion SET purged_at=now() WHERE account_id=$1",
+	} {
+		if _, err = tx.Exec(ctx, q, id); err != nil {
+			return "", err
+		}
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM login_attempt WHERE key=lower($1)", e)
+	if err != nil {
+		return "", err
+	}
+
+	return key, tx.Commit(ctx)
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/google/uuid"
+)
+
+func TestDeletionRevokesTokens(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	if _, err := conn.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, 'Ann', $2, 'x', $1)", id, id+"@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Exec(ctx, "DELETE FROM account WHERE id=$1", id) })
+
+	tokens := NewTokenDatabase(conn)
+	if _, err := tokens.InsertToken(id, "ci", "read", "pat-"+id); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAccountDatabase(conn)
+	if err := a.RequestDeletion(id, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the grace period doesn't keep scripts running
+	if _, _, err := tokens.LookupToken("pat-" + id); err == nil {
+		t.Error("access token works after the deletion request")
+	}
+}
+
+func TestPurgeAnonymize(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	for _, q := range []string{
+		"INSERT INTO account (id, username, email, password, unique_name, role) VALUES ($1, 'Ann', $1 || '@example.com', 'x', $1, 'moderator')",
+		"INSERT INTO identity_link (token_hash, provider, subject, account_id, expires_at) VALUES ($1, 'github', $1, $1, now() + interval '1 hour')",
+		"INSERT INTO account_ban (account_id, kind) VALUES ($1, 'suspend')",
+		"INSERT INTO account_deletion (account_id, purge_after) VALUES ($1, now())",
+	} {
+		if _, err := conn.Exec(ctx, q, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { conn.Exec(ctx, "DELETE FROM account WHERE id=$1", id) })
+
+	if _, err := purgeAccount(conn, id, PolicyAnonymize); err != nil {
+		t.Fatal(err)
+	}
+
+	var name, role, password string
+	if err := conn.QueryRow(ctx, "SELECT username, role, password FROM account WHERE id=$1", id).Scan(&name, &role, &password); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Deleted user" || role != "user" || password != model.NoPassword {
+		t.Errorf("tombstone = %q %q %q", name, role, password)
+	}
+
+	for _, table := range []string{"identity_link", "account_ban"} {
+		var n int
+		conn.QueryRow(ctx, "SELECT count(*) FROM "+table+" WHERE account_id=$1", id).Scan(&n)
+		if n != 0 {
+			t.Errorf("%d %s rows left", n, table)
+		}
+	}
+}
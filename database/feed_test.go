@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestQuestionStatsConcurrentLikes(t *testing.T) {
+	f := NewFilesDatabase(testDB(t))
+	ctx := context.Background()
+
+	poster := testAccount(t, f)
+	q := uuid.New().String()
+	now := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	if _, err := f.conn.Exec(ctx, "INSERT INTO question (id, question, poster, slug, created_at, updated_at) VALUES ($1, 'why?', $2, $1, $3, $3)", q, poster, now); err != nil {
+		t.Fatal(err)
+	}
+
+	voters := make([]string, 10)
+	for i := range voters {
+		voters[i] = testAccount(t, f)
+	}
+
+	// every like commits at about the same time
+	var wg sync.WaitGroup
+	for _, v := range voters {
+		wg.Add(1)
+		go func(v string) {
+			defer wg.Done()
+			if err := f.Like(q, v); err != nil {
+				t.Error(err)
+			}
+		}(v)
+	}
+	wg.Wait()
+
+	var likes int
+	if err := f.conn.QueryRow(ctx, "SELECT likes FROM question_stats WHERE question_id=$1", q).Scan(&likes); err != nil {
+		t.Fatal(err)
+	}
+	if likes != len(voters) {
+		t.Errorf("%d likes counted, want %d", likes, len(voters))
+	}
+}
@@ -3,6 +3,9 @@ package database
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Hamaiz/go-rest-eg/model"
@@ -20,116 +23,149 @@ func NewFilesDatabase(conn *pgxpool.Pool) *FilesDatabase {
 	return &FilesDatabase{conn}
 }
 
-// GetSearchedQuestions - gets all the searched question
-func (f *FilesDatabase) GetSearchedQuestions(l string) ([]model.GetQuestions, error) {
+// questionColumns - one row per question with its first answer and counters
+const questionColumns = `question.id, question.question, question.poster, question.slug, question.created_at,
+	(SELECT answer.answer FROM answer WHERE answer.question_id=question.id ORDER BY answer.created_at, answer.commenter LIMIT 1),
+	question_stats.likes, question_stats.answers, question_stats.last_activity`
+
+// questionSorts - column and direction of every order of the feed
+var questionSorts = map[string]struct {
+	column string
+	desc   bool
+}{
+	model.SortNewest:   {"question.created_at", true},
+	model.SortOldest:   {"question.created_at", false},
+	model.SortLikes:    {"question_stats.likes", true},
+	model.SortActivity: {"question_stats.last_activity", true},
+}
+
+// questions - page of questions matching filter and the feed filters in their sort
+// filter arguments start at $1, ties of the sort are broken by id
+func (f *FilesDatabase) questions(filter string, args []interface{}, q model.QuestionFilter, c *model.Cursor, limit int) ([]model.GetQuestions, model.Span, error) {
 	fqs := make([]model.GetQuestions, 0)
 
-	//rows, err := f.conn.Query(context.Background(), `SELECT * FROM question WHERE question similar to '%(' || $1 || ')%'`, l)
+	sort, ok := questionSorts[q.Sort]
+	if !ok {
+		q.Sort = model.SortNewest
+		sort = questionSorts[q.Sort]
+	}
 
-	rows, err := f.conn.Query(context.Background(), "SELECT question.id, question.question, question.poster, question.Slug, question.created_at, answer.answer FROM question LEFT JOIN answer ON question.id=answer.question_id WHERE question.question similar to '%(' || $1 || ')%'", l)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
-	switch {
-	case err == pgx.ErrNoRows:
-		err = errors.New("no questions found")
-		return fqs, err
-	case err != nil:
+	conds := []string{filter}
+	if q.Answered != nil && *q.Answered {
+		conds = append(conds, "question_stats.answers > 0")
+	}
+	if q.Answered != nil && !*q.Answered {
+		conds = append(conds, "question_stats.answers = 0")
+	}
+	if q.Author != "" {
+		conds = append(conds, "question.poster=(SELECT id FROM account WHERE unique_name="+arg(q.Author)+")")
+	}
+	if q.Since != "" {
+		conds = append(conds, "question.created_at >= "+arg(q.Since))
+	}
+	if q.Until != "" {
+		conds = append(conds, "question.created_at < "+arg(q.Until))
+	}
+	if q.MinLikes > 0 {
+		conds = append(conds, "question_stats.likes >= "+arg(q.MinLikes))
+	}
+
+	cond, order, backwards, cargs := keyset(c, sort.desc, sort.column, "question.id", len(args)+1)
+	args = append(args, cargs...)
+	conds = append(conds, cond)
+
+	query := fmt.Sprintf("SELECT %s FROM question JOIN question_stats ON question_stats.question_id=question.id WHERE %s ORDER BY %s LIMIT %s",
+		questionColumns, strings.Join(conds, " AND "), order, arg(limit+1))
+
+	rows, err := f.conn.Query(context.Background(), query, args...)
+	if err != nil {
 		err = errors.New("an error occured")
-		return fqs, err
+		return fqs, model.Span{}, err
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
 		fq := model.GetQuestions{}
-		var answer interface{}
-
-		err := rows.Scan(&fq.ID, &fq.Question, &fq.Poster, &fq.Slug, &fq.Created_At, &answer)
+		var answer *string
 
+		err := rows.Scan(&fq.ID, &fq.Question, &fq.Poster, &fq.Slug, &fq.Created_At, &answer, &fq.Likes, &fq.Answers, &fq.LastActivity)
 		if err != nil {
-
 			err = errors.New("an error occured")
-			return fqs, err
-		}
-
-		var likes int
-		likes, err = f.GetLikes(fq.ID)
-		if err != nil {
-			return fqs, err
+			return fqs, model.Span{}, err
 		}
 
 		if answer == nil {
 			fq.Answer = "not answered yet"
 		} else {
-			fq.Answer = answer.(string)
+			fq.Answer = *answer
 		}
 
-		fq.Likes = likes
-
 		fqs = append(fqs, fq)
 	}
 
-	return fqs, nil
-
-}
-
-// GetQuestions - get all questions
-func (f *FilesDatabase) GetQuestions() ([]model.GetQuestions, error) {
-	fqs := make([]model.GetQuestions, 0)
-
-	rows, err := f.conn.Query(context.Background(), "SELECT question.id, question.question, question.poster, question.Slug, question.created_at, answer.answer FROM question LEFT JOIN answer ON question.id=answer.question_id")
-	switch {
-	case err == pgx.ErrNoRows:
-		err = errors.New("no questions found")
-		return fqs, err
-	case err != nil:
-		err = errors.New("an error occured")
-		return fqs, err
+	if rows.Err() != nil {
+		return fqs, model.Span{}, errors.New("an error occured")
 	}
 
-	defer rows.Close()
+	n, sp := span(len(fqs), limit, backwards, func(i, j int) { fqs[i], fqs[j] = fqs[j], fqs[i] }, func(i int) model.Cursor {
+		key := fqs[i].Created_At
+		switch q.Sort {
+		case model.SortLikes:
+			key = strconv.Itoa(fqs[i].Likes)
+		case model.SortActivity:
+			key = fqs[i].LastActivity
+		}
 
-	for rows.Next() {
-		fq := model.GetQuestions{}
-		var answer interface{}
-		err := rows.Scan(&fq.ID, &fq.Question, &fq.Poster, &fq.Slug, &fq.Created_At, &answer)
+		return model.Cursor{Key: key, ID: fqs[i].ID, Sort: q.Sort}
+	})
 
-		if err != nil {
-			err = errors.New("an error occured")
-			return fqs, err
-		}
+	return fqs[:n], sp, nil
+}
 
-		var likes int
-		likes, err = f.GetLikes(fq.ID)
-		if err != nil {
-			return fqs, err
-		}
+// GetSearchedQuestions - gets a page of the searched questions, newest first
+func (f *FilesDatabase) GetSearchedQuestions(l string, c *model.Cursor, limit int) ([]model.GetQuestions, model.Span, error) {
+	return f.questions("question.question similar to '%(' || $1 || ')%'", []interface{}{l}, model.QuestionFilter{Sort: model.SortNewest}, c, limit)
+}
 
-		if answer == nil {
-			fq.Answer = "not answered yet"
-		} else {
-			fq.Answer = answer.(string)
-		}
+// GetQuestions - gets a page of the question feed
+func (f *FilesDatabase) GetQuestions(q model.QuestionFilter, c *model.Cursor, limit int) ([]model.GetQuestions, model.Span, error) {
+	return f.questions("true", nil, q, c, limit)
+}
 
-		fq.Likes = likes
-		fqs = append(fqs, fq)
+// PostQuestion - add posts to the database along with uploads of the poster
+func (f *FilesDatabase) PostQuestion(p model.FilesQuestion, attachments []string) error {
+	ctx := context.Background()
+
+	tx, err := f.conn.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	return fqs, nil
-}
+	_, err = tx.Exec(ctx, "INSERT INTO question (id, question, poster, slug, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)", p.ID, p.Question, p.Poster, p.Slug, p.Created_At, p.Updated_At)
+	if err != nil {
+		return err
+	}
 
-// PostQuestion - add posts to the database
-func (f *FilesDatabase) PostQuestion(p model.FilesQuestion) error {
-	_, err := f.conn.Exec(context.Background(), "INSERT INTO question (id, question, poster, slug, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)", p.ID, p.Question, p.Poster, p.Slug, p.Created_At, p.Updated_At)
+	if err = attach(tx, attachments, p.Poster, "question_id=$3", p.ID); err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit(ctx)
 }
 
 // GetQuest - get only one question
 func (f *FilesDatabase) GetQuest(s string) (model.FilesSend, error) {
 	fq := model.FilesSend{}
 
-	row := f.conn.QueryRow(context.Background(), "SELECT question.id, question, slug, created_at, username, unique_name FROM question JOIN account ON question.poster=account.id WHERE question.slug=$1", s)
-	err := row.Scan(&fq.ID, &fq.Question, &fq.Slug, &fq.CreatedAt, &fq.Username, &fq.Unique_Name)
+	row := f.conn.QueryRow(context.Background(), "SELECT question.id, question, slug, created_at, username, unique_name, coalesce(avatar, '') FROM question JOIN account ON question.poster=account.id WHERE question.slug=$1", s)
+	err := row.Scan(&fq.ID, &fq.Question, &fq.Slug, &fq.CreatedAt, &fq.Username, &fq.Unique_Name, &fq.AvatarKey)
 
 	switch {
 	case err == pgx.ErrNoRows:
@@ -140,6 +176,12 @@ func (f *FilesDatabase) GetQuest(s string) (model.FilesSend, error) {
 		return fq, err
 	}
 
+	fq.Attachments, err = f.questionAttachments(fq.ID)
+	if err != nil {
+		err = errors.New("try again")
+		return fq, err
+	}
+
 	return fq, nil
 }
 
@@ -174,11 +216,26 @@ func (f *FilesDatabase) EditQuestion(s string, nq string, slug string) error {
 	return nil
 }
 
-// AddAnswer - add answer to the question
-func (f *FilesDatabase) AddAnswer(a model.FilesComment) error {
-	_, err := f.conn.Exec(context.Background(), "INSERT INTO answer (question_id, answer, commenter, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)", a.Question_ID, a.Answer, a.Commenter, a.Created_At, a.Updated_At)
+// AddAnswer - add answer to the question along with uploads of the commenter
+func (f *FilesDatabase) AddAnswer(a model.FilesComment, attachments []string) error {
+	ctx := context.Background()
 
-	return err
+	tx, err := f.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "INSERT INTO answer (question_id, answer, commenter, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)", a.Question_ID, a.Answer, a.Commenter, a.Created_At, a.Updated_At)
+	if err != nil {
+		return err
+	}
+
+	if err = attach(tx, attachments, a.Commenter, "answer_question_id=$3, answer_commenter=$2", a.Question_ID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 // GetAnswer - get answer from database
@@ -211,43 +268,94 @@ func (f *FilesDatabase) GetOneAnswer(s string) (string, error) {
 	return ans, err
 }
 
-// EditAnswer - edits the quesiton
-func (f *FilesDatabase) EditAnswer(s string, na string) error {
+// EditAnswer - edits the answer of the commenter
+func (f *FilesDatabase) EditAnswer(s string, c string, na string) error {
 	t := time.Now().UTC().Format(time.RFC3339)
-	_, err := f.conn.Exec(context.Background(), "UPDATE answer SET answer=$1, updated_at=$2 WHERE question_id=$3", na, t, s)
+	_, err := f.conn.Exec(context.Background(), "UPDATE answer SET answer=$1, updated_at=$2 WHERE question_id=$3 AND commenter=$4", na, t, s, c)
+
+	return err
+}
+
+// DeleteQuestion - deletes the question, answers and votes cascade
+func (f *FilesDatabase) DeleteQuestion(s string) error {
+	_, err := f.conn.Exec(context.Background(), "DELETE FROM question WHERE id=$1", s)
 
 	return err
 }
 
-// GetAnswers - get all answers of question
-func (f *FilesDatabase) GetAnswers(s string) ([]model.GetAnswers, error) {
+// DeleteAnswer - deletes the answer of the commenter, pgx.ErrNoRows if there is none
+func (f *FilesDatabase) DeleteAnswer(s string, c string) error {
+	ct, err := f.conn.Exec(context.Background(), "DELETE FROM answer WHERE question_id=$1 AND commenter=$2", s, c)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetAnswers - get a page of answers of question, oldest first
+func (f *FilesDatabase) GetAnswers(s string, cur *model.Cursor, limit int) ([]model.GetAnswers, model.Span, error) {
 	fcs := make([]model.GetAnswers, 0)
 
-	rows, err := f.conn.Query(context.Background(), "SELECT answer.question_id, answer.answer, answer.created_at, account.username, account.unique_name FROM answer JOIN account ON answer.commenter=account.id WHERE answer.question_id=$1", s)
+	cond, order, backwards, cargs := keyset(cur, false, "answer.created_at", "answer.commenter", 2)
+	args := append([]interface{}{s}, cargs...)
+	args = append(args, limit+1)
 
-	switch {
-	case err == pgx.ErrNoRows:
-		err = errors.New("no answer found")
-		return fcs, err
-	case err != nil:
+	rows, err := f.conn.Query(context.Background(), fmt.Sprintf("SELECT answer.question_id, answer.answer, answer.created_at, account.username, account.unique_name, coalesce(account.avatar, ''), answer.commenter FROM answer JOIN account ON answer.commenter=account.id WHERE answer.question_id=$1 AND %s ORDER BY %s LIMIT $%d", cond, order, len(args)), args...)
+	if err != nil {
 		err = errors.New("try again")
-		return fcs, err
+		return fcs, model.Span{}, err
 	}
 
+	defer rows.Close()
+
+	commenters := make([]string, 0)
 	for rows.Next() {
 		fc := model.GetAnswers{}
-		err := rows.Scan(&fc.Question_ID, &fc.Answer, &fc.Created_At, &fc.Username, &fc.Unique_Name)
+		var c string
+		err := rows.Scan(&fc.Question_ID, &fc.Answer, &fc.Created_At, &fc.Username, &fc.Unique_Name, &fc.AvatarKey, &c)
 
 		if err != nil {
 			err = errors.New("an error occured")
-			return fcs, err
+			return fcs, model.Span{}, err
 		}
 
 		fcs = append(fcs, fc)
+		commenters = append(commenters, c)
+
+	}
+	rows.Close()
+
+	if rows.Err() != nil {
+		return fcs, model.Span{}, errors.New("try again")
+	}
 
+	n, sp := span(len(fcs), limit, backwards, func(i, j int) {
+		fcs[i], fcs[j] = fcs[j], fcs[i]
+		commenters[i], commenters[j] = commenters[j], commenters[i]
+	}, func(i int) model.Cursor {
+		return model.Cursor{Key: fcs[i].Created_At, ID: commenters[i]}
+	})
+	fcs, commenters = fcs[:n], commenters[:n]
+
+	as, err := f.answerAttachments(s, commenters)
+	if err != nil {
+		err = errors.New("try again")
+		return fcs, model.Span{}, err
+	}
+
+	for i, c := range commenters {
+		fcs[i].Attachments = as[c]
+		if fcs[i].Attachments == nil {
+			fcs[i].Attachments = make([]model.Attachment, 0)
+		}
 	}
 
-	return fcs, nil
+	return fcs, sp, nil
 
 }
 
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/session"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// HTTPSessionDatabase - cookie session data for the postgres session store
+type HTTPSessionDatabase struct {
+	conn *pgxpool.Pool
+}
+
+// NewHTTPSessionDatabase - returns HTTPSessionDatabase
+func NewHTTPSessionDatabase(conn *pgxpool.Pool) *HTTPSessionDatabase {
+	return &HTTPSessionDatabase{conn}
+}
+
+// Load - data of the session if it didn't expire
+func (h *HTTPSessionDatabase) Load(id string) ([]byte, error) {
+	var data []byte
+	err := h.conn.QueryRow(context.Background(), "SELECT data FROM http_session WHERE id=$1 AND expires_at>now()", id).Scan(&data)
+
+	if err == pgx.ErrNoRows {
+		return nil, session.ErrNoSession
+	}
+
+	return data, err
+}
+
+// Save - saves data of the session for ttl
+func (h *HTTPSessionDatabase) Save(id string, data []byte, ttl time.Duration) error {
+	_, err := h.conn.Exec(context.Background(), "INSERT INTO http_session (id, data, expires_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET data=$2, expires_at=$3", id, data, time.Now().Add(ttl))
+
+	return err
+}
+
+// Delete - removes the session
+func (h *HTTPSessionDatabase) Delete(id string) error {
+	_, err := h.conn.Exec(context.Background(), "DELETE FROM http_session WHERE id=$1", id)
+
+	return err
+}
+
+// PurgeHTTPSessions - deletes expired cookie sessions
+func PurgeHTTPSessions(conn *pgxpool.Pool) {
+	_, err := conn.Exec(context.Background(), "DELETE FROM http_session WHERE expires_at<now()")
+	if err != nil {
+		log.Println("could not delete expired sessions:", err)
+	}
+}
@@ -0,0 +1,12 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/session/sessiontest"
+)
+
+// a migrated database is needed, e.g. DATABASE_URL=postgres://localhost/files_test
+func TestHTTPSessionDatabase(t *testing.T) {
+	sessiontest.Backend(t, NewHTTPSessionDatabase(testDB(t)))
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// MagicLinkEnabled - tells if administrators turned magic links on
+func (a *AccountDatabase) MagicLinkEnabled() (bool, error) {
+	return setting(a.conn, model.SettingMagicLink)
+}
+
+// MagicLinkToken - saves hashed sign-in link of the user and browser
+func (a *AccountDatabase) MagicLinkToken(id string, hash string, browser string, expires time.Time) error {
+	return issueToken(a.conn, id, model.PurposeMagicLink, hash, browser, expires)
+}
+
+// UseMagicLink - uses the link once and returns its user
+// links from another browser aren't used up, pgx.ErrNoRows when it can't be used
+func (a *AccountDatabase) UseMagicLink(hash string, browser string) (string, error) {
+	var id string
+	row := a.conn.QueryRow(context.Background(), "UPDATE verification_token SET consumed_at=now() WHERE token_hash=$1 AND purpose=$2 AND data=$3 AND consumed_at IS NULL AND expires_at>now() RETURNING account_id", hash, model.PurposeMagicLink, browser)
+	err := row.Scan(&id)
+
+	return id, err
+}
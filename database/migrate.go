@@ -0,0 +1,362 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// migrationLockKey - advisory lock id held while migrating
+const migrationLockKey = 7240119
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationName - matches 0001_name.up.sql | 0001_name.down.sql
+var migrationName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration - one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus - migration and when it was applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations - returns all the embedded migrations sorted by version
+func Migrations() ([]Migration, error) {
+	return readMigrations(migrationFiles, "migrations")
+}
+
+// readMigrations - migrations of the folder sorted by version
+func readMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, f := range files {
+		m := migrationName.FindStringSubmatch(f.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", f.Name())
+		}
+
+		v, _ := strconv.ParseInt(m[1], 10, 64)
+		b, err := fs.ReadFile(fsys, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[v]
+		if !ok {
+			mg = &Migration{Version: v, Name: m[2]}
+			byVersion[v] = mg
+		}
+
+		if mg.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", v, mg.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mg.Up = string(b)
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	ms := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", mg.Version, mg.Name)
+		}
+		ms = append(ms, *mg)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+
+	return ms, nil
+}
+
+// Migrator - applies embedded migrations to the database
+type Migrator struct {
+	conn       *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator - returns Migrator with all the embedded migrations
+func NewMigrator(conn *pgxpool.Pool) (*Migrator, error) {
+	ms, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{conn, ms}, nil
+}
+
+// Latest - latest version known to this binary
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status - every migration with applied information
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	ctx := context.Background()
+
+	applied, err := appliedMigrations(ctx, m.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	sts := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		at, ok := applied[mg.Version]
+		sts = append(sts, MigrationStatus{mg, ok, at})
+	}
+
+	return sts, nil
+}
+
+// Current - highest applied version, 0 if nothing is applied
+func (m *Migrator) Current() (int64, error) {
+	applied, err := appliedMigrations(context.Background(), m.conn)
+	if err != nil {
+		return 0, err
+	}
+
+	var v int64
+	for av := range applied {
+		if av > v {
+			v = av
+		}
+	}
+
+	return v, nil
+}
+
+// Up - applies every pending migration
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down - rolls back the last n applied migrations
+func (m *Migrator) Down(n int) error {
+	if n < 1 {
+		return errors.New("number of migrations must be at least 1")
+	}
+
+	return m.locked(func(ctx context.Context, c *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+
+			if err := rollback(ctx, c, mg); err != nil {
+				return err
+			}
+			n--
+		}
+
+		return nil
+	})
+}
+
+// To - migrates up or down until version v is the latest applied one
+func (m *Migrator) To(v int64) error {
+	if v != 0 && !m.known(v) {
+		return fmt.Errorf("unknown migration version %d", v)
+	}
+
+	return m.locked(func(ctx context.Context, c *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		// roll back everything above the target, newest first
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok || mg.Version <= v {
+				continue
+			}
+
+			if err := rollback(ctx, c, mg); err != nil {
+				return err
+			}
+		}
+
+		// apply everything up to the target, oldest first
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok || mg.Version > v {
+				continue
+			}
+
+			if err := apply(ctx, c, mg); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// known - checks if version is one of the embedded migrations
+func (m *Migrator) known(v int64) bool {
+	for _, mg := range m.migrations {
+		if mg.Version == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// locked - runs fn on a single connection holding the migration advisory lock
+func (m *Migrator) locked(fn func(ctx context.Context, c *pgxpool.Conn) error) error {
+	ctx := context.Background()
+
+	c, err := m.conn.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Release()
+
+	var ok bool
+	err = c.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&ok)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("another instance is running migrations")
+	}
+
+	defer func() {
+		if _, err := c.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			log.Println("could not release migration lock:", err)
+		}
+	}()
+
+	if err := ensureMigrationsTable(ctx, c); err != nil {
+		return err
+	}
+
+	return fn(ctx, c)
+}
+
+// queryer - pool or single connection
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// ensureMigrationsTable - creates schema_migrations if it is missing
+func ensureMigrationsTable(ctx context.Context, c *pgxpool.Conn) error {
+	_, err := c.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+
+	return err
+}
+
+// appliedMigrations - version to applied time of every applied migration
+func appliedMigrations(ctx context.Context, q queryer) (map[int64]time.Time, error) {
+	applied := make(map[int64]time.Time)
+
+	var exists bool
+	err := q.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := q.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// apply - runs the up migration and records it in one transaction
+func apply(ctx context.Context, c *pgxpool.Conn, mg Migration) error {
+	log.Printf("applying migration %d_%s...", mg.Version, mg.Name)
+
+	tx, err := c.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, mg.Up); err != nil {
+		return fmt.Errorf("migration %d_%s: %v", mg.Version, mg.Name, err)
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mg.Version, mg.Name)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// rollback - runs the down migration and forgets it in one transaction
+func rollback(ctx context.Context, c *pgxpool.Conn, mg Migration) error {
+	log.Printf("rolling back migration %d_%s...", mg.Version, mg.Name)
+
+	tx, err := c.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, mg.Down); err != nil {
+		return fmt.Errorf("rollback %d_%s: %v", mg.Version, mg.Name, err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version=$1", mg.Version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
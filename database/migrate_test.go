@@ -0,0 +1,92 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0010_ten.up.sql":    {Data: []byte("up 10")},
+		"m/0010_ten.down.sql":  {Data: []byte("down 10")},
+		"m/0002_two.up.sql":    {Data: []byte("up 2")},
+		"m/0002_two.down.sql":  {Data: []byte("down 2")},
+		"m/0001_one.down.sql":  {Data: []byte("down 1")},
+		"m/0001_one.up.sql":    {Data: []byte("up 1")},
+		"m/0003_a_b.up.sql":    {Data: []byte("up 3")},
+		"m/0003_a_b.down.sql":  {Data: []byte("down 3")},
+		"m/0100_last.up.sql":   {Data: []byte("up 100")},
+		"m/0100_last.down.sql": {Data: []byte("down 100")},
+	}
+
+	ms, err := readMigrations(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{1, "one", "up 1", "down 1"},
+		{2, "two", "up 2", "down 2"},
+		{3, "a_b", "up 3", "down 3"},
+		{10, "ten", "up 10", "down 10"},
+		{100, "last", "up 100", "down 100"},
+	}
+
+	if len(ms) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(ms), len(want))
+	}
+
+	for i := range want {
+		if ms[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, ms[i], want[i])
+		}
+	}
+}
+
+func TestReadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		err   string
+	}{
+		{"bad name", []string{"0001_one.up.sql", "0001_one.down.sql", "two.up.sql"}, "invalid migration file name"},
+		{"no direction", []string{"0001_one.sql"}, "invalid migration file name"},
+		{"not sql", []string{"0001_one.up.txt"}, "invalid migration file name"},
+		{"missing down", []string{"0001_one.up.sql"}, "needs both up and down"},
+		{"missing up", []string{"0001_one.down.sql"}, "needs both up and down"},
+		{"two names", []string{"0001_one.up.sql", "0001_uno.down.sql"}, "has two names"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, f := range tt.files {
+				fsys["m/"+f] = &fstest.MapFile{Data: []byte("SELECT 1")}
+			}
+
+			_, err := readMigrations(fsys, "m")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	ms, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	// versions have no gaps, so a missing file is noticed
+	for i, mg := range ms {
+		if mg.Version != int64(i+1) {
+			t.Errorf("migration %d_%s, want version %d", mg.Version, mg.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS google;
+DROP TABLE IF EXISTS vote;
+DROP TABLE IF EXISTS answer;
+DROP TABLE IF EXISTS question;
+DROP TABLE IF EXISTS addition;
+DROP TABLE IF EXISTS account;
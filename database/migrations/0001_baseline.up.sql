@@ -0,0 +1,53 @@
+-- baseline schema used by the account, files and oauth repositories.
+-- IF NOT EXISTS lets databases created before migrations adopt it.
+
+CREATE TABLE IF NOT EXISTS account (
+    id          text PRIMARY KEY,
+    username    text NOT NULL,
+    email       text NOT NULL UNIQUE,
+    password    text NOT NULL,
+    unique_name text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS addition (
+    confirmed  boolean NOT NULL DEFAULT false,
+    expires    timestamp,
+    token      text,
+    account_id text NOT NULL UNIQUE REFERENCES account (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS addition_token_idx ON addition (token);
+
+CREATE TABLE IF NOT EXISTS question (
+    id         text PRIMARY KEY,
+    question   text NOT NULL,
+    poster     text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    slug       text NOT NULL UNIQUE,
+    created_at text NOT NULL,
+    updated_at text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS answer (
+    question_id text NOT NULL REFERENCES question (id) ON DELETE CASCADE,
+    answer      text NOT NULL,
+    commenter   text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    created_at  text NOT NULL,
+    updated_at  text NOT NULL,
+    PRIMARY KEY (question_id, commenter)
+);
+
+CREATE TABLE IF NOT EXISTS vote (
+    question_id text NOT NULL REFERENCES question (id) ON DELETE CASCADE,
+    user_id     text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    likes       boolean NOT NULL DEFAULT false,
+    dislike     boolean NOT NULL DEFAULT false,
+    PRIMARY KEY (question_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS google (
+    google_id    text PRIMARY KEY,
+    google_token text,
+    google_email text NOT NULL,
+    google_name  text,
+    account_id   text NOT NULL REFERENCES account (id) ON DELETE CASCADE
+);
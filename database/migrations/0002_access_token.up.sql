@@ -0,0 +1,13 @@
+-- personal access tokens, only the sha256 of the token is stored
+CREATE TABLE access_token (
+    id           text PRIMARY KEY,
+    account_id   text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    name         text NOT NULL,
+    token_hash   text NOT NULL UNIQUE,
+    scope        text NOT NULL CHECK (scope IN ('read', 'write')),
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    last_used_at timestamptz,
+    revoked_at   timestamptz
+);
+
+CREATE INDEX access_token_account_id_idx ON access_token (account_id);
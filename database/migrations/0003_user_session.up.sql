@@ -0,0 +1,20 @@
+-- server side sessions, a jwt refresh token family belongs to one session
+CREATE TABLE user_session (
+    id         text PRIMARY KEY,
+    account_id text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    revoked_at timestamptz
+);
+
+CREATE INDEX user_session_account_id_idx ON user_session (account_id);
+
+-- refresh tokens rotate on every use, only the sha256 is stored
+CREATE TABLE refresh_token (
+    token_hash text PRIMARY KEY,
+    session_id text NOT NULL REFERENCES user_session (id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz
+);
+
+CREATE INDEX refresh_token_session_id_idx ON refresh_token (session_id);
@@ -0,0 +1,25 @@
+-- totp secret of the account, confirmed_at is set after the first valid code
+CREATE TABLE totp (
+    account_id   text PRIMARY KEY REFERENCES account (id) ON DELETE CASCADE,
+    secret       text NOT NULL,
+    last_step    bigint NOT NULL DEFAULT 0,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    confirmed_at timestamptz
+);
+
+-- one time recovery codes, only the sha256 is stored
+CREATE TABLE recovery_code (
+    code_hash  text PRIMARY KEY,
+    account_id text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    used_at    timestamptz
+);
+
+CREATE INDEX recovery_code_account_id_idx ON recovery_code (account_id);
+
+-- half authenticated logins waiting for the second factor
+CREATE TABLE mfa_challenge (
+    token_hash text PRIMARY KEY,
+    account_id text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    expires_at timestamptz NOT NULL,
+    attempts   integer NOT NULL DEFAULT 0
+);
@@ -0,0 +1,9 @@
+-- failed attempts per scope (login-email, login-ip, ...) and key
+CREATE TABLE login_attempt (
+    scope        text NOT NULL,
+    key          text NOT NULL,
+    failures     integer NOT NULL DEFAULT 0,
+    last_failure timestamptz NOT NULL DEFAULT now(),
+    locked_until timestamptz,
+    PRIMARY KEY (scope, key)
+);
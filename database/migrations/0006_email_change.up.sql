@@ -0,0 +1,8 @@
+-- pending email changes, the new address confirms with the token
+CREATE TABLE email_change (
+    token_hash text PRIMARY KEY,
+    account_id text NOT NULL UNIQUE REFERENCES account (id) ON DELETE CASCADE,
+    new_email  text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz NOT NULL
+);
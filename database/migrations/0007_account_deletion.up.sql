@@ -0,0 +1,9 @@
+-- accounts waiting to be deleted, purged_at is set once the grace period is over
+CREATE TABLE account_deletion (
+    account_id   text PRIMARY KEY REFERENCES account (id) ON DELETE CASCADE,
+    requested_at timestamptz NOT NULL DEFAULT now(),
+    purge_after  timestamptz NOT NULL,
+    purged_at    timestamptz
+);
+
+CREATE INDEX account_deletion_purge_idx ON account_deletion (purge_after) WHERE purged_at IS NULL;
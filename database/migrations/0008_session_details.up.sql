@@ -0,0 +1,5 @@
+-- where and when sessions are used, shown in the session list
+ALTER TABLE user_session
+    ADD COLUMN last_seen_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN ip           text NOT NULL DEFAULT '',
+    ADD COLUMN user_agent   text NOT NULL DEFAULT '';
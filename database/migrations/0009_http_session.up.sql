@@ -0,0 +1,8 @@
+-- cookie session data of the postgres session store
+CREATE TABLE http_session (
+    id         text PRIMARY KEY,
+    data       bytea NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX http_session_expires_at_idx ON http_session (expires_at);
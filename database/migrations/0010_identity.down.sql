@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS google (
+    google_id    text PRIMARY KEY,
+    google_token text,
+    google_email text NOT NULL,
+    google_name  text,
+    account_id   text NOT NULL REFERENCES account (id) ON DELETE CASCADE
+);
+
+INSERT INTO google (google_id, google_email, google_name, account_id)
+SELECT subject, email, name, account_id FROM identity WHERE provider='google'
+ON CONFLICT DO NOTHING;
+
+DROP TABLE IF EXISTS identity;
@@ -0,0 +1,20 @@
+-- external identities of every oauth provider, replaces the google table
+CREATE TABLE identity (
+    provider      text NOT NULL,
+    subject       text NOT NULL,
+    account_id    text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    email         text NOT NULL DEFAULT '',
+    name          text NOT NULL DEFAULT '',
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    last_login_at timestamptz,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX identity_account_id_idx ON identity (account_id);
+
+-- plain text google tokens are not carried over
+INSERT INTO identity (provider, subject, account_id, email, name)
+SELECT 'google', google_id, account_id, google_email, COALESCE(google_name, '') FROM google
+ON CONFLICT DO NOTHING;
+
+DROP TABLE google;
@@ -0,0 +1,11 @@
+-- identities waiting for the password of the account with the same email
+CREATE TABLE identity_link (
+    token_hash text PRIMARY KEY,
+    provider   text NOT NULL,
+    subject    text NOT NULL,
+    email      text NOT NULL DEFAULT '',
+    name       text NOT NULL DEFAULT '',
+    account_id text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    attempts   integer NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL
+);
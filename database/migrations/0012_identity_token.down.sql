@@ -0,0 +1,5 @@
+ALTER TABLE identity
+    DROP COLUMN IF EXISTS access_token,
+    DROP COLUMN IF EXISTS refresh_token,
+    DROP COLUMN IF EXISTS token_type,
+    DROP COLUMN IF EXISTS token_expires_at;
@@ -0,0 +1,6 @@
+-- provider tokens of the identity, access and refresh token are encrypted
+ALTER TABLE identity
+    ADD COLUMN access_token     text,
+    ADD COLUMN refresh_token    text,
+    ADD COLUMN token_type       text,
+    ADD COLUMN token_expires_at timestamptz;
@@ -0,0 +1,15 @@
+-- every account has one role, staff actions are kept in the audit log
+ALTER TABLE account
+    ADD COLUMN role text NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE audit_log (
+    id          bigserial PRIMARY KEY,
+    actor_id    text REFERENCES account (id) ON DELETE SET NULL,
+    action      text NOT NULL,
+    target_type text NOT NULL,
+    target_id   text NOT NULL,
+    detail      text NOT NULL DEFAULT '',
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
@@ -0,0 +1,9 @@
+-- suspended and banned accounts, a ban without expiry lasts until it's lifted
+CREATE TABLE account_ban (
+    account_id text PRIMARY KEY REFERENCES account (id) ON DELETE CASCADE,
+    kind       text NOT NULL CHECK (kind IN ('suspend', 'ban')),
+    reason     text NOT NULL DEFAULT '',
+    expires_at timestamptz,
+    created_by text REFERENCES account (id) ON DELETE SET NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
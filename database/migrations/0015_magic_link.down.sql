@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS magic_link;
+
+DROP TABLE IF EXISTS setting;
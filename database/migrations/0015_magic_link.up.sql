@@ -0,0 +1,18 @@
+-- settings administrators can change while the api is running
+CREATE TABLE setting (
+    name       text PRIMARY KEY,
+    value      text NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- hashed single use sign-in links, bound to the browser that asked for them
+CREATE TABLE magic_link (
+    token_hash   text PRIMARY KEY,
+    account_id   text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    browser_hash text NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    expires_at   timestamptz NOT NULL,
+    used_at      timestamptz
+);
+
+CREATE INDEX magic_link_account_id_idx ON magic_link (account_id);
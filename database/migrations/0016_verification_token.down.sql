@@ -0,0 +1,46 @@
+-- only hashes are stored, so outstanding verification and reset links are lost
+CREATE TABLE addition (
+    confirmed  boolean NOT NULL DEFAULT false,
+    expires    timestamp,
+    token      text,
+    account_id text NOT NULL UNIQUE REFERENCES account (id) ON DELETE CASCADE
+);
+
+CREATE INDEX addition_token_idx ON addition (token);
+
+INSERT INTO addition (confirmed, token, account_id)
+SELECT email_confirmed, '', id FROM account;
+
+CREATE TABLE email_change (
+    token_hash text PRIMARY KEY,
+    account_id text NOT NULL UNIQUE REFERENCES account (id) ON DELETE CASCADE,
+    new_email  text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz NOT NULL
+);
+
+INSERT INTO email_change (token_hash, account_id, new_email, created_at, expires_at)
+SELECT DISTINCT ON (account_id) token_hash, account_id, data, created_at, expires_at
+FROM verification_token
+WHERE purpose = 'change-email' AND consumed_at IS NULL
+ORDER BY account_id, created_at DESC;
+
+CREATE TABLE magic_link (
+    token_hash   text PRIMARY KEY,
+    account_id   text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    browser_hash text NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    expires_at   timestamptz NOT NULL,
+    used_at      timestamptz
+);
+
+CREATE INDEX magic_link_account_id_idx ON magic_link (account_id);
+
+INSERT INTO magic_link (token_hash, account_id, browser_hash, created_at, expires_at, used_at)
+SELECT token_hash, account_id, data, created_at, expires_at, consumed_at
+FROM verification_token
+WHERE purpose = 'magic-link';
+
+ALTER TABLE account DROP COLUMN IF EXISTS email_confirmed;
+
+DROP TABLE IF EXISTS verification_token;
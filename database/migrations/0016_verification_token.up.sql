@@ -0,0 +1,43 @@
+-- hashed single use tokens with a purpose replace the plaintext token of
+-- addition and the email_change and magic_link tables
+CREATE TABLE verification_token (
+    token_hash  text PRIMARY KEY,
+    account_id  text NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+    purpose     text NOT NULL CHECK (purpose IN ('verify-email', 'reset-password', 'change-email', 'magic-link')),
+    data        text NOT NULL DEFAULT '',
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    expires_at  timestamptz NOT NULL,
+    consumed_at timestamptz
+);
+
+CREATE INDEX verification_token_account_purpose_idx ON verification_token (account_id, purpose);
+
+-- accounts without an addition row were created confirmed or are tombstones
+ALTER TABLE account ADD COLUMN email_confirmed boolean NOT NULL DEFAULT false;
+
+UPDATE account SET email_confirmed = COALESCE((SELECT confirmed FROM addition WHERE addition.account_id = account.id), true);
+
+-- links already sent keep working until they would have expired, expires holds
+-- the wall clock of the app server and is read in the time zone of the database,
+-- no link lives longer than the 6 hours it was sent with
+INSERT INTO verification_token (token_hash, account_id, purpose, expires_at)
+SELECT encode(sha256(convert_to(token, 'UTF8')), 'hex'), account_id,
+       CASE WHEN confirmed THEN 'reset-password' ELSE 'verify-email' END,
+       LEAST(expires::timestamptz, now() + interval '6 hours')
+FROM addition
+WHERE token IS NOT NULL AND token <> '' AND expires::timestamptz > now()
+ON CONFLICT DO NOTHING;
+
+INSERT INTO verification_token (token_hash, account_id, purpose, data, created_at, expires_at)
+SELECT token_hash, account_id, 'change-email', new_email, created_at, expires_at
+FROM email_change
+ON CONFLICT DO NOTHING;
+
+INSERT INTO verification_token (token_hash, account_id, purpose, data, created_at, expires_at, consumed_at)
+SELECT token_hash, account_id, 'magic-link', browser_hash, created_at, expires_at, used_at
+FROM magic_link
+ON CONFLICT DO NOTHING;
+
+DROP TABLE addition;
+DROP TABLE email_change;
+DROP TABLE magic_link;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS answer_commenter_idx;
+DROP INDEX IF EXISTS question_poster_idx;
+
+ALTER TABLE account
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS bio;
@@ -0,0 +1,13 @@
+-- public profile fields, accounts from before this get the date of their first session or question
+ALTER TABLE account
+    ADD COLUMN bio        text NOT NULL DEFAULT '',
+    ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+
+UPDATE account SET created_at = LEAST(
+    created_at,
+    COALESCE((SELECT min(created_at) FROM user_session WHERE user_session.account_id = account.id), created_at),
+    COALESCE((SELECT min(created_at::timestamptz) FROM question WHERE question.poster = account.id), created_at)
+);
+
+CREATE INDEX question_poster_idx ON question (poster);
+CREATE INDEX answer_commenter_idx ON answer (commenter);
@@ -0,0 +1,2 @@
+-- key of the uploaded thumbnails, NULL shows the generated avatar
+ALTER TABLE account ADD COLUMN avatar text;
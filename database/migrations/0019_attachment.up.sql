@@ -0,0 +1,21 @@
+-- uploads of questions and answers, rows linked to neither are orphans the check job deletes after a day
+CREATE TABLE attachment (
+    id                 text PRIMARY KEY,
+    owner_id           text REFERENCES account (id) ON DELETE SET NULL,
+    blob_key           text NOT NULL,
+    filename           text NOT NULL,
+    content_type       text NOT NULL,
+    size               bigint NOT NULL,
+    question_id        text REFERENCES question (id) ON DELETE SET NULL,
+    answer_question_id text,
+    answer_commenter   text,
+    created_at         timestamptz NOT NULL DEFAULT now(),
+    FOREIGN KEY (answer_question_id, answer_commenter) REFERENCES answer (question_id, commenter) ON DELETE SET NULL,
+    CHECK (question_id IS NULL OR answer_question_id IS NULL)
+);
+
+CREATE INDEX attachment_owner_idx ON attachment (owner_id);
+CREATE INDEX attachment_blob_idx ON attachment (blob_key);
+CREATE INDEX attachment_question_idx ON attachment (question_id);
+CREATE INDEX attachment_answer_idx ON attachment (answer_question_id, answer_commenter);
+CREATE INDEX attachment_orphan_idx ON attachment (created_at) WHERE question_id IS NULL AND answer_question_id IS NULL;
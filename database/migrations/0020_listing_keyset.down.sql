@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS answer_created_idx;
+DROP INDEX IF EXISTS question_created_idx;
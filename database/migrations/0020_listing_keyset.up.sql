@@ -0,0 +1,3 @@
+-- keyset pagination of questions and of the answers of a question over (created_at, id)
+CREATE INDEX question_created_idx ON question (created_at, id);
+CREATE INDEX answer_created_idx ON answer (question_id, created_at, commenter);
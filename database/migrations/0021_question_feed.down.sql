@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS question_poster_created_idx;
+CREATE INDEX IF NOT EXISTS question_poster_idx ON question (poster);
+
+DROP TRIGGER IF EXISTS question_stats_vote ON vote;
+DROP TRIGGER IF EXISTS question_stats_answer ON answer;
+DROP TRIGGER IF EXISTS question_stats_question ON question;
+DROP FUNCTION IF EXISTS question_stats_trigger();
+DROP FUNCTION IF EXISTS refresh_question_stats(text);
+DROP TABLE IF EXISTS question_stats;
@@ -0,0 +1,63 @@
+-- counters the question feed sorts and filters on, kept up to date by triggers so they can be indexed
+-- activity is the latest edit of the question or any of its answers
+CREATE TABLE question_stats (
+    question_id   text PRIMARY KEY REFERENCES question (id) ON DELETE CASCADE,
+    likes         bigint NOT NULL DEFAULT 0,
+    answers       bigint NOT NULL DEFAULT 0,
+    last_activity text NOT NULL
+);
+
+-- counting under read committed would miss votes and answers of concurrent transactions,
+-- the stats row is locked first so each count waits for the one before it and sees its rows
+CREATE FUNCTION refresh_question_stats(qid text) RETURNS void AS $$
+BEGIN
+    PERFORM 1 FROM question_stats WHERE question_id = qid FOR UPDATE;
+
+    INSERT INTO question_stats (question_id, likes, answers, last_activity)
+    SELECT question.id,
+        (SELECT count(*) FROM vote WHERE vote.question_id = question.id AND vote.likes),
+        (SELECT count(*) FROM answer WHERE answer.question_id = question.id),
+        GREATEST(question.updated_at, (SELECT max(answer.updated_at) FROM answer WHERE answer.question_id = question.id))
+    FROM question WHERE question.id = qid
+    ON CONFLICT (question_id) DO UPDATE SET
+        likes = EXCLUDED.likes,
+        answers = EXCLUDED.answers,
+        last_activity = EXCLUDED.last_activity;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION question_stats_trigger() RETURNS trigger AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'question' THEN
+        PERFORM refresh_question_stats(NEW.id);
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP <> 'INSERT' THEN
+        PERFORM refresh_question_stats(OLD.question_id);
+    END IF;
+
+    IF TG_OP <> 'DELETE' THEN
+        PERFORM refresh_question_stats(NEW.question_id);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER question_stats_question AFTER INSERT OR UPDATE OF updated_at ON question
+    FOR EACH ROW EXECUTE PROCEDURE question_stats_trigger();
+CREATE TRIGGER question_stats_answer AFTER INSERT OR UPDATE OR DELETE ON answer
+    FOR EACH ROW EXECUTE PROCEDURE question_stats_trigger();
+CREATE TRIGGER question_stats_vote AFTER INSERT OR UPDATE OR DELETE ON vote
+    FOR EACH ROW EXECUTE PROCEDURE question_stats_trigger();
+
+SELECT refresh_question_stats(id) FROM question;
+
+CREATE INDEX question_stats_likes_idx ON question_stats (likes, question_id);
+CREATE INDEX question_stats_activity_idx ON question_stats (last_activity, question_id);
+CREATE INDEX question_stats_unanswered_idx ON question_stats (question_id) WHERE answers = 0;
+
+-- author filter in created_at order
+DROP INDEX IF EXISTS question_poster_idx;
+CREATE INDEX question_poster_created_idx ON question (poster, created_at, id);
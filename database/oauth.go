@@ -3,10 +3,12 @@ package database
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/Hamaiz/go-rest-eg/helper"
 	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
@@ -21,98 +23,238 @@ func NewOauthDatabase(conn *pgxpool.Pool) *OauthDatabase {
 	return &OauthDatabase{conn}
 }
 
-// InsertGoogle - adds user to database
-// it is a big function that take cares of all google users
-func (o *OauthDatabase) InsertGoogle(u model.GoogleData) (string, error) {
+// LoginIdentity - account of the external identity, new users get an account
+// ok is false when the email belongs to an account that has to confirm the link first
+func (o *OauthDatabase) LoginIdentity(u model.Identity) (string, bool, error) {
 	ctx := context.Background()
 
-	// check for email
-	c, id := o.CheckEmail(u.Email)
+	tx, err := o.conn.Begin(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback(ctx)
 
-	// check if google email already exists
-	g := o.CheckGoogle(u.Email)
+	// identity already known
+	var id string
+	err = tx.QueryRow(ctx, "UPDATE identity SET email=$3, name=$4, last_login_at=now() WHERE provider=$1 AND subject=$2 RETURNING account_id", u.Provider, u.Subject, u.Email, u.Name).Scan(&id)
 
-	if !c {
-		// if the user already exists
-		if !g {
-			return id, nil
-		}
+	switch {
+	case err == nil:
+		return id, true, tx.Commit(ctx)
+	case err != pgx.ErrNoRows:
+		return "", false, err
+	}
 
-		// make confirmed true
-		_, err := o.conn.Exec(ctx, "UPDATE addition SET confirmed=$1 WHERE account_id=$2", true, id)
+	if u.Email == "" {
+		return "", false, errors.New(u.Provider + " didn't share an email address")
+	}
 
-		if err != nil {
-			err = errors.New("error occured, try again")
-			return "", err
-		}
+	// an unverified email could be anyone's, it must not create or match an account
+	if !u.EmailVerified {
+		return "", false, errors.New(u.Provider + " hasn't verified your email address")
+	}
 
-		// insert google data to database
-		_, err = o.conn.Exec(ctx, "INSERT INTO google (google_id, google_token, google_email, google_name, account_id) VALUES ($1, $2, $3, $4, $5)", u.ID, u.Token, u.Email, u.Name, id)
+	// never attached silently to an existing account
+	err = tx.QueryRow(ctx, "SELECT id FROM account WHERE email=$1", u.Email).Scan(&id)
 
-		if err != nil {
-			err = errors.New("error occured, try again")
-			return "", err
-		}
+	switch {
+	case err == nil:
+		return id, false, nil
+	case err != pgx.ErrNoRows:
+		return "", false, err
+	}
 
-		return id, nil
+	id, err = createOauthAccount(ctx, tx, u)
+	if err != nil {
+		return "", false, errors.New("error occured, try again")
 	}
 
-	// get unique name
-	un := helper.UniqueName(u.Name)
+	// add identity to database
+	_, err = tx.Exec(ctx, "INSERT INTO identity (provider, subject, account_id, email, name, last_login_at) VALUES ($1, $2, $3, $4, $5, now())", u.Provider, u.Subject, id, u.Email, u.Name)
+	if err != nil {
+		return "", false, errors.New("error occured, try again")
+	}
+
+	return id, true, tx.Commit(ctx)
+}
 
-	// add user to account database
-	_, err := o.conn.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name) VALUES ($1, $2, $3, $4, $5)", u.ID, u.Name, u.Email, " ", un)
+// LinkIdentity - links the identity to the account
+func (o *OauthDatabase) LinkIdentity(account string, u model.Identity) error {
+	var owner string
+	row := o.conn.QueryRow(context.Background(), "INSERT INTO identity (provider, subject, account_id, email, name, last_login_at) VALUES ($1, $2, $3, $4, $5, now()) ON CONFLICT (provider, subject) DO UPDATE SET last_login_at=now() RETURNING account_id", u.Provider, u.Subject, account, u.Email, u.Name)
+	if err := row.Scan(&owner); err != nil {
+		return err
+	}
 
-	if err != nil {
-		err = errors.New("error occured, try again")
-		return "", err
+	if owner != account {
+		return errors.New("this " + u.Provider + " account is linked to another user")
 	}
 
-	// add user to google
-	_, err = o.conn.Exec(ctx, "INSERT INTO google (google_id, google_token, google_email, google_name, account_id) VALUES ($1, $2, $3, $4, $5)", u.ID, u.Token, u.Email, u.Name, u.ID)
+	return nil
+}
 
+// UnlinkIdentity - removes identity of the account, the last one only when there is a password
+func (o *OauthDatabase) UnlinkIdentity(account string, provider string, subject string) error {
+	ctx := context.Background()
+
+	tx, err := o.conn.Begin(ctx)
 	if err != nil {
-		err = errors.New("error occured, try again")
-		return "", err
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	t := time.Now().Local()
-	_, err = o.conn.Exec(ctx, "INSERT INTO addition (expires, token, account_id) VALUES ($1, $2, $3)", t, "", u.ID)
+	// lock the account so two unlinks can't both pass the check
+	var p string
+	err = tx.QueryRow(ctx, "SELECT password FROM account WHERE id=$1 FOR UPDATE", account).Scan(&p)
+	if err != nil {
+		return err
+	}
 
+	ct, err := tx.Exec(ctx, "DELETE FROM identity WHERE account_id=$1 AND provider=$2 AND subject=$3", account, provider, subject)
 	if err != nil {
-		err = errors.New("error occured, try again")
-		return "", err
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if p == model.NoPassword {
+		var n int
+		if err = tx.QueryRow(ctx, "SELECT count(*) FROM identity WHERE account_id=$1", account).Scan(&n); err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return errors.New("set a password before unlinking your last login")
+		}
 	}
 
-	return u.ID, nil
+	return tx.Commit(ctx)
 }
 
-// CheckEmail - checks if email exists
-func (o *OauthDatabase) CheckEmail(e string) (bool, string) {
+// HasPassword - tells if the account has a local password
+func (o *OauthDatabase) HasPassword(account string) (bool, error) {
+	p, err := o.GetPassword(account)
+
+	return p != model.NoPassword, err
+}
+
+// GetPassword - gets password hash of the user
+func (o *OauthDatabase) GetPassword(account string) (string, error) {
+	return NewAccountDatabase(o.conn).GetPassword(account)
+}
+
+// PendingLink - holds the identity until the account password confirms the link
+func (o *OauthDatabase) PendingLink(account string, u model.Identity, hash string, expires time.Time) error {
 	ctx := context.Background()
 
-	// checking if user exists
-	var id string
-	err := o.conn.QueryRow(ctx, "SELECT id FROM account WHERE email=$1", e).Scan(&id)
+	_, err := o.conn.Exec(ctx, "DELETE FROM identity_link WHERE expires_at<now()")
+	if err != nil {
+		return err
+	}
+
+	_, err = o.conn.Exec(ctx, "INSERT INTO identity_link (token_hash, provider, subject, email, name, account_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)", hash, u.Provider, u.Subject, u.Email, u.Name, account, expires)
+
+	return err
+}
+
+// UsePendingLink - counts an attempt and returns account and identity of a valid link token
+func (o *OauthDatabase) UsePendingLink(hash string, maxAttempts int) (string, model.Identity, error) {
+	var account string
+	u := model.Identity{}
+	row := o.conn.QueryRow(context.Background(), "UPDATE identity_link SET attempts=attempts+1 WHERE token_hash=$1 AND expires_at>now() AND attempts<$2 RETURNING account_id, provider, subject, email, name", hash, maxAttempts)
+	err := row.Scan(&account, &u.Provider, &u.Subject, &u.Email, &u.Name)
 
 	if err == pgx.ErrNoRows {
-		return true, ""
-	} else {
-		return false, id
+		err = errors.New("link expired, log in with the provider again")
 	}
+
+	return account, u, err
 }
 
-//CheckGoogle - check if google account exists - if there is no item it returns true
-func (o *OauthDatabase) CheckGoogle(e string) bool {
-	ctx := context.Background()
+// DeletePendingLink - removes link token once it is used
+func (o *OauthDatabase) DeletePendingLink(hash string) error {
+	_, err := o.conn.Exec(context.Background(), "DELETE FROM identity_link WHERE token_hash=$1", hash)
 
-	// checking if user exists
-	var id string
-	err := o.conn.QueryRow(ctx, "SELECT google_id FROM google WHERE google_email=$1", e).Scan(&id)
+	return err
+}
 
-	if err == pgx.ErrNoRows {
-		return true
-	} else {
-		return false
+// createOauthAccount - account without a password, confirmed by the provider
+func createOauthAccount(ctx context.Context, tx pgx.Tx, u model.Identity) (string, error) {
+	id := uuid.New().String()
+
+	n := u.Name
+	if n == "" {
+		n = strings.Split(u.Email, "@")[0]
+	}
+
+	// get unique name
+	un := helper.UniqueName(n)
+
+	// the provider verified the email
+	_, err := tx.Exec(ctx, "INSERT INTO account (id, username, email, password, unique_name, email_confirmed) VALUES ($1, $2, $3, $4, $5, true)", id, n, u.Email, model.NoPassword, un)
+
+	return id, err
+}
+
+// GetIdentities - identities linked to the account
+func (o *OauthDatabase) GetIdentities(account string) ([]model.LinkedIdentity, error) {
+	ids := make([]model.LinkedIdentity, 0)
+
+	rows, err := o.conn.Query(context.Background(), "SELECT provider, subject, email, name, created_at FROM identity WHERE account_id=$1 ORDER BY created_at", account)
+	if err != nil {
+		return ids, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		li := model.LinkedIdentity{}
+		if err := rows.Scan(&li.Provider, &li.Subject, &li.Email, &li.Name, &li.CreatedAt); err != nil {
+			return ids, err
+		}
+		ids = append(ids, li)
 	}
+
+	return ids, rows.Err()
+}
+
+// == provider tokens ==//
+
+// GetToken - encrypted token of the identity, pgx.ErrNoRows if there is none
+func (o *OauthDatabase) GetToken(provider string, subject string) (model.ProviderToken, error) {
+	t := model.ProviderToken{Provider: provider, Subject: subject}
+	row := o.conn.QueryRow(context.Background(), "SELECT access_token, COALESCE(refresh_token, ''), COALESCE(token_type, ''), token_expires_at FROM identity WHERE provider=$1 AND subject=$2 AND access_token IS NOT NULL", provider, subject)
+	err := row.Scan(&t.AccessToken, &t.RefreshToken, &t.TokenType, &t.Expiry)
+
+	return t, err
+}
+
+// SaveToken - saves encrypted token of the identity
+// providers like google only send a refresh token on the first consent, so an empty one keeps the stored one
+func (o *OauthDatabase) SaveToken(t model.ProviderToken) error {
+	_, err := o.conn.Exec(context.Background(), "UPDATE identity SET access_token=$3, refresh_token=COALESCE(NULLIF($4, ''), refresh_token), token_type=$5, token_expires_at=$6 WHERE provider=$1 AND subject=$2", t.Provider, t.Subject, t.AccessToken, t.RefreshToken, t.TokenType, t.Expiry)
+
+	return err
+}
+
+// GetTokens - every stored token, used to re-encrypt them
+func (o *OauthDatabase) GetTokens() ([]model.ProviderToken, error) {
+	ts := make([]model.ProviderToken, 0)
+
+	rows, err := o.conn.Query(context.Background(), "SELECT provider, subject, access_token, COALESCE(refresh_token, ''), COALESCE(token_type, ''), token_expires_at FROM identity WHERE access_token IS NOT NULL")
+	if err != nil {
+		return ts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := model.ProviderToken{}
+		if err := rows.Scan(&t.Provider, &t.Subject, &t.AccessToken, &t.RefreshToken, &t.TokenType, &t.Expiry); err != nil {
+			return ts, err
+		}
+		ts = append(ts, t)
+	}
+
+	return ts, rows.Err()
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/google/uuid"
+)
+
+func TestLoginIdentity(t *testing.T) {
+	conn := testDB(t)
+	o := NewOauthDatabase(conn)
+	ctx := context.Background()
+
+	email := uuid.New().String() + "@example.com"
+	id := model.Identity{Provider: "fake", Subject: uuid.New().String(), Email: email, Name: "Ann"}
+
+	cleanup := func() {
+		conn.Exec(ctx, "DELETE FROM identity WHERE provider='fake' AND email=$1", email)
+		conn.Exec(ctx, "DELETE FROM account WHERE email=$1", email)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	// unverified emails neither create nor match accounts
+	if _, _, err := o.LoginIdentity(id); err == nil {
+		t.Fatal("unverified email logged in")
+	}
+
+	var n int
+	conn.QueryRow(ctx, "SELECT count(*) FROM account WHERE email=$1", email).Scan(&n)
+	if n != 0 {
+		t.Fatal("unverified email created an account")
+	}
+
+	id.EmailVerified = true
+	account, ok, err := o.LoginIdentity(id)
+	if err != nil || !ok || account == "" {
+		t.Fatalf("verified login = %q, %v, %v", account, ok, err)
+	}
+
+	var confirmed bool
+	conn.QueryRow(ctx, "SELECT email_confirmed FROM account WHERE id=$1", account).Scan(&confirmed)
+	if !confirmed {
+		t.Error("account of a verified email isn't confirmed")
+	}
+
+	// a known identity logs in whatever its email says now
+	id.EmailVerified = false
+	again, ok, err := o.LoginIdentity(id)
+	if err != nil || !ok || again != account {
+		t.Errorf("known identity = %q, %v, %v", again, ok, err)
+	}
+
+	// another provider account with the same email has to confirm the link
+	other := model.Identity{Provider: "fake", Subject: uuid.New().String(), Email: email, Name: "Ann"}
+	if _, _, err := o.LoginIdentity(other); err == nil {
+		t.Error("unverified email matched an existing account")
+	}
+
+	other.EmailVerified = true
+	owner, ok, err := o.LoginIdentity(other)
+	if err != nil || ok || owner != account {
+		t.Errorf("verified email of an account = %q, %v, %v, want a link challenge", owner, ok, err)
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// keyset - condition and order of the page after or before the cursor over (t, id), t being the sorted column
+// arguments of the condition start at $n, pages before the cursor are read
+// backwards so the rows next to it come first and have to be reversed
+func keyset(c *model.Cursor, desc bool, t string, id string, n int) (string, string, bool, []interface{}) {
+	backwards := c != nil && c.Before
+
+	// rows shown first are the ones after the cursor
+	less := desc != backwards
+
+	dir := "ASC"
+	if less {
+		dir = "DESC"
+	}
+	order := fmt.Sprintf("%s %s, %s %s", t, dir, id, dir)
+
+	if c == nil {
+		return "true", order, false, nil
+	}
+
+	op := ">"
+	if less {
+		op = "<"
+	}
+
+	return fmt.Sprintf("(%s, %s) %s ($%d, $%d)", t, id, op, n, n+1), order, backwards, []interface{}{c.Key, c.ID}
+}
+
+// span - trims the extra row read to tell if there are more, and puts
+// rows read backwards in order, key gives the cursor of row i
+func span(n int, limit int, backwards bool, swap func(i, j int), key func(i int) model.Cursor) (int, model.Span) {
+	sp := model.Span{More: n > limit}
+	if sp.More {
+		n = limit
+	}
+
+	if backwards {
+		for l, r := 0, n-1; l < r; l, r = l+1, r-1 {
+			swap(l, r)
+		}
+	}
+
+	sp.Count = n
+	if n > 0 {
+		sp.First = key(0)
+		sp.Last = key(n - 1)
+	}
+
+	return n, sp
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+func TestKeyset(t *testing.T) {
+	after := &model.Cursor{Key: "k", ID: "i"}
+	before := &model.Cursor{Key: "k", ID: "i", Before: true}
+
+	tests := []struct {
+		name      string
+		c         *model.Cursor
+		desc      bool
+		cond      string
+		order     string
+		backwards bool
+	}{
+		{"first page", nil, false, "true", "t ASC, id ASC", false},
+		{"first page descending", nil, true, "true", "t DESC, id DESC", false},
+		{"after", after, false, "(t, id) > ($3, $4)", "t ASC, id ASC", false},
+		{"after descending", after, true, "(t, id) < ($3, $4)", "t DESC, id DESC", false},
+		{"before", before, false, "(t, id) < ($3, $4)", "t DESC, id DESC", true},
+		{"before descending", before, true, "(t, id) > ($3, $4)", "t ASC, id ASC", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, order, backwards, args := keyset(tt.c, tt.desc, "t", "id", 3)
+
+			if cond != tt.cond || order != tt.order || backwards != tt.backwards {
+				t.Errorf("keyset = %q, %q, %v, want %q, %q, %v", cond, order, backwards, tt.cond, tt.order, tt.backwards)
+			}
+
+			var want []interface{}
+			if tt.c != nil {
+				want = []interface{}{"k", "i"}
+			}
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("args = %v, want %v", args, want)
+			}
+		})
+	}
+}
+
+func TestSpan(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      []int
+		limit     int
+		backwards bool
+		want      []int
+		more      bool
+	}{
+		{"empty", nil, 3, false, []int{}, false},
+		{"short page", []int{1, 2}, 3, false, []int{1, 2}, false},
+		{"full page", []int{1, 2, 3}, 3, false, []int{1, 2, 3}, false},
+		{"one more", []int{1, 2, 3, 4}, 3, false, []int{1, 2, 3}, true},
+		{"backwards", []int{3, 2, 1}, 3, true, []int{1, 2, 3}, false},
+		{"backwards with more", []int{4, 3, 2, 1}, 3, true, []int{2, 3, 4}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := append([]int{}, tt.rows...)
+
+			n, sp := span(len(rows), tt.limit, tt.backwards, func(i, j int) { rows[i], rows[j] = rows[j], rows[i] }, func(i int) model.Cursor {
+				return model.Cursor{Key: strconv.Itoa(rows[i]), ID: "id" + strconv.Itoa(rows[i])}
+			})
+
+			got := rows[:n]
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Fatalf("rows = %v, want %v", got, tt.want)
+			}
+			if sp.Count != n || sp.More != tt.more {
+				t.Errorf("span %+v, want count %d more %v", sp, n, tt.more)
+			}
+			if n > 0 && (sp.First.Key != strconv.Itoa(got[0]) || sp.Last.Key != strconv.Itoa(got[n-1])) {
+				t.Errorf("span %+v of rows %v", sp, got)
+			}
+		})
+	}
+}
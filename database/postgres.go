@@ -7,7 +7,7 @@ import (
 	"time"
 
 	"github.com/Hamaiz/go-rest-eg/model"
-	"github.com/jackc/pgx/v4"
+	"github.com/Hamaiz/go-rest-eg/storage"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
 )
@@ -28,7 +28,8 @@ func DBConn() (*pgxpool.Pool, error) {
 }
 
 // DeleteAccount - delete accounts with expiry
-func DeleteAccount() {
+// removeAvatar deletes the stored avatar of purged accounts
+func DeleteAccount(removeAvatar func(ctx context.Context, st storage.Storage, key string) error) {
 	log.Println("connected to server")
 
 	// loading dotenv
@@ -48,54 +49,40 @@ func DeleteAccount() {
 		log.Println("an error occured: ", err)
 	}
 
+	// blobs of purged attachments and avatars are deleted from STORAGE
+	media, err := storage.Load(storage.NewSigner([]byte(os.Getenv("SESSION_KEY")), os.Getenv("URL")))
+	if err != nil {
+		log.Println("an error occured: ", err)
+	}
+
 	// time tick
 	c := time.Tick(30 * time.Minute)
 	for _ = range c {
-		// get email token struct
-		ets := make([]model.EmailDbToken, 0)
-
-		rows, err := conn.Query(context.Background(), "SELECT * FROM addition WHERE confirmed=false")
-		switch {
-		case err == pgx.ErrNoRows:
-			log.Println("there are no unverified users")
-		case err != nil:
-			log.Println("an error occured")
+		// unconfirmed accounts whose confirmation links all expired
+		_, err := conn.Exec(context.Background(), "DELETE FROM account WHERE NOT email_confirmed AND EXISTS (SELECT 1 FROM verification_token WHERE verification_token.account_id=account.id AND purpose=$1) AND NOT EXISTS (SELECT 1 FROM verification_token WHERE verification_token.account_id=account.id AND purpose=$1 AND expires_at>now())", model.PurposeVerifyEmail)
+		if err != nil {
+			log.Println("could not delete unverified users:", err)
 		}
 
-		defer rows.Close()
-
-		for rows.Next() {
-			et := model.EmailDbToken{}
-			err := rows.Scan(&et.Confirmed, &et.Expires, &et.Token, &et.Account_id)
-
-			if err != nil {
-				log.Println("an error occured")
+		// accounts whose deletion grace period is over
+		for _, key := range PurgeAccounts(conn) {
+			if media == nil {
+				continue
+			}
+			if err := removeAvatar(context.Background(), media, key); err != nil {
+				log.Println("avatar of purged account:", err)
 			}
-
-			ets = append(ets, et)
 		}
 
-		for _, e := range ets {
-			ex := e.Expires.String()
-			exp, _ := time.Parse("2006-01-02 15:04:05", ex)
-			exps := time.Now().Local().After(exp)
-
-			id := e.Account_id
-
-			if exps {
-				_, err = conn.Exec(context.Background(), "DELETE FROM addition WHERE account_id=$1", id)
+		// cookie sessions of the postgres session store
+		PurgeHTTPSessions(conn)
 
-				if err != nil {
-					log.Println("error occured")
-				}
+		// used and expired verification tokens
+		PurgeVerificationTokens(conn)
 
-				_, err = conn.Exec(context.Background(), "DELETE FROM account WHERE id=$1", id)
-
-				if err != nil {
-					log.Println("error occured")
-				}
-
-			}
+		// uploads that were never attached, or whose post is gone
+		if media != nil {
+			PurgeAttachments(conn, media)
 		}
 	}
 
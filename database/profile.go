@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ProfileDatabase - public profiles of users
+type ProfileDatabase struct {
+	conn *pgxpool.Pool
+}
+
+// NewProfileDatabase - returns ProfileDatabase
+func NewProfileDatabase(conn *pgxpool.Pool) *ProfileDatabase {
+	return &ProfileDatabase{conn}
+}
+
+// publicAccount - accounts that have a profile, purged accounts don't
+const publicAccount = "account.unique_name=$1 AND NOT EXISTS (SELECT 1 FROM account_deletion WHERE account_deletion.account_id=account.id AND account_deletion.purged_at IS NOT NULL)"
+
+// GetProfile - profile of the unique name, pgx.ErrNoRows if there is none
+func (p *ProfileDatabase) GetProfile(un string) (model.Profile, error) {
+	pr := model.Profile{}
+
+	row := p.conn.QueryRow(context.Background(), `SELECT account.unique_name, account.username, account.bio, coalesce(account.avatar, ''), account.created_at,
+		(SELECT count(*) FROM question WHERE question.poster=account.id),
+		(SELECT count(*) FROM answer WHERE answer.commenter=account.id),
+		(SELECT count(*) FROM vote JOIN question ON vote.question_id=question.id WHERE question.poster=account.id AND vote.likes)
+		FROM account WHERE `+publicAccount, un)
+	err := row.Scan(&pr.UniqueName, &pr.Name, &pr.Bio, &pr.AvatarKey, &pr.JoinedAt, &pr.Questions, &pr.Answers, &pr.Likes)
+
+	return pr, err
+}
+
+// GetProfileQuestions - newest questions of the unique name, pgx.ErrNoRows if there is no profile
+func (p *ProfileDatabase) GetProfileQuestions(un string, limit int, offset int) (model.ProfileQuestions, error) {
+	ctx := context.Background()
+	pq := model.ProfileQuestions{Questions: make([]model.ProfileQuestion, 0), Limit: limit, Offset: offset}
+
+	var id string
+	err := p.conn.QueryRow(ctx, "SELECT account.id, (SELECT count(*) FROM question WHERE question.poster=account.id) FROM account WHERE "+publicAccount, un).Scan(&id, &pq.Total)
+	if err != nil {
+		return pq, err
+	}
+
+	rows, err := p.conn.Query(ctx, `SELECT question.id, question.question, question.slug, question.created_at,
+		(SELECT count(*) FROM answer WHERE answer.question_id=question.id),
+		(SELECT count(*) FROM vote WHERE vote.question_id=question.id AND vote.likes)
+		FROM question WHERE question.poster=$1 ORDER BY question.created_at DESC, question.id LIMIT $2 OFFSET $3`, id, limit, offset)
+	if err != nil {
+		return pq, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		q := model.ProfileQuestion{}
+		if err := rows.Scan(&q.ID, &q.Question, &q.Slug, &q.CreatedAt, &q.Answers, &q.Likes); err != nil {
+			return pq, err
+		}
+		pq.Questions = append(pq.Questions, q)
+	}
+
+	return pq, rows.Err()
+}
+
+// GetProfileAnswers - newest answers of the unique name, pgx.ErrNoRows if there is no profile
+func (p *ProfileDatabase) GetProfileAnswers(un string, limit int, offset int) (model.ProfileAnswers, error) {
+	ctx := context.Background()
+	pa := model.ProfileAnswers{Answers: make([]model.ProfileAnswer, 0), Limit: limit, Offset: offset}
+
+	var id string
+	err := p.conn.QueryRow(ctx, "SELECT account.id, (SELECT count(*) FROM answer WHERE answer.commenter=account.id) FROM account WHERE "+publicAccount, un).Scan(&id, &pa.Total)
+	if err != nil {
+		return pa, err
+	}
+
+	rows, err := p.conn.Query(ctx, `SELECT answer.question_id, question.question, question.slug, answer.answer, answer.created_at
+		FROM answer JOIN question ON answer.question_id=question.id
+		WHERE answer.commenter=$1 ORDER BY answer.created_at DESC, answer.question_id LIMIT $2 OFFSET $3`, id, limit, offset)
+	if err != nil {
+		return pa, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := model.ProfileAnswer{}
+		if err := rows.Scan(&a.QuestionID, &a.Question, &a.QuestionSlug, &a.Answer, &a.CreatedAt); err != nil {
+			return pa, err
+		}
+		pa.Answers = append(pa.Answers, a)
+	}
+
+	return pa, rows.Err()
+}
+
+// UpdateProfile - changes display name and bio of the user, pgx.ErrNoRows if there is no user
+func (p *ProfileDatabase) UpdateProfile(id string, name string, bio string) error {
+	ct, err := p.conn.Exec(context.Background(), "UPDATE account SET username=$1, bio=$2 WHERE id=$3", name, bio, id)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// SetAvatar - saves the avatar key of the user, empty for the generated one, and returns the replaced key
+func (p *ProfileDatabase) SetAvatar(id string, key string) (string, error) {
+	var old string
+	err := p.conn.QueryRow(context.Background(),
+		"UPDATE account SET avatar=NULLIF($1, '') FROM account old WHERE account.id=$2 AND old.id=account.id RETURNING coalesce(old.avatar, '')", key, id).Scan(&old)
+
+	return old, err
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RoleDatabase - roles of accounts and the audit log
+type RoleDatabase struct {
+	conn *pgxpool.Pool
+}
+
+// NewRoleDatabase - returns RoleDatabase
+func NewRoleDatabase(conn *pgxpool.Pool) *RoleDatabase {
+	return &RoleDatabase{conn}
+}
+
+// GetRole - role of the user
+func (d *RoleDatabase) GetRole(id string) (string, error) {
+	var role string
+	err := d.conn.QueryRow(context.Background(), "SELECT role FROM account WHERE id=$1", id).Scan(&role)
+
+	return role, err
+}
+
+// SetRole - changes role of the user, pgx.ErrNoRows if there is no user
+func (d *RoleDatabase) SetRole(id string, role string) error {
+	ct, err := d.conn.Exec(context.Background(), "UPDATE account SET role=$1 WHERE id=$2", role, id)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// Audit - records a staff action, empty actor is the command line
+func (d *RoleDatabase) Audit(actor string, action string, targetType string, targetID string, detail string) error {
+	_, err := d.conn.Exec(context.Background(), "INSERT INTO audit_log (actor_id, action, target_type, target_id, detail) VALUES (NULLIF($1, ''), $2, $3, $4, $5)", actor, action, targetType, targetID, detail)
+
+	return err
+}
+
+// GetAuditLog - newest audit entries first
+func (d *RoleDatabase) GetAuditLog(limit int, offset int) ([]model.AuditEntry, error) {
+	es := make([]model.AuditEntry, 0)
+
+	rows, err := d.conn.Query(context.Background(), "SELECT id, actor_id, action, target_type, target_id, detail, created_at FROM audit_log ORDER BY id DESC LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return es, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := model.AuditEntry{}
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &e.Detail, &e.CreatedAt); err != nil {
+			return es, err
+		}
+		es = append(es, e)
+	}
+
+	return es, rows.Err()
+}
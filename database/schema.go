@@ -0,0 +1,301 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Column - column name and information_schema data type
+type Column struct {
+	Name string
+	Type string
+}
+
+// Table - columns a table must have
+// Positional tables are read with SELECT * so order and count must match too
+type Table struct {
+	Name       string
+	Positional bool
+	Columns    []Column
+}
+
+// Schema - what the repositories expect to find in the database
+var Schema = []Table{
+	{"account", false, []Column{
+		{"id", "text"},
+		{"username", "text"},
+		{"email", "text"},
+		{"password", "text"},
+		{"unique_name", "text"},
+		{"role", "text"},
+		{"email_confirmed", "boolean"},
+		{"bio", "text"},
+		{"created_at", "timestamp with time zone"},
+		{"avatar", "text"},
+	}},
+	// GetQuestion - SELECT * FROM question
+	{"question", true, []Column{
+		{"id", "text"},
+		{"question", "text"},
+		{"poster", "text"},
+		{"slug", "text"},
+		{"created_at", "text"},
+		{"updated_at", "text"},
+	}},
+	// GetAnswer - SELECT * FROM answer
+	{"answer", true, []Column{
+		{"question_id", "text"},
+		{"answer", "text"},
+		{"commenter", "text"},
+		{"created_at", "text"},
+		{"updated_at", "text"},
+	}},
+	{"question_stats", false, []Column{
+		{"question_id", "text"},
+		{"likes", "bigint"},
+		{"answers", "bigint"},
+		{"last_activity", "text"},
+	}},
+	{"vote", false, []Column{
+		{"question_id", "text"},
+		{"user_id", "text"},
+		{"likes", "boolean"},
+		{"dislike", "boolean"},
+	}},
+	{"identity", false, []Column{
+		{"provider", "text"},
+		{"subject", "text"},
+		{"account_id", "text"},
+		{"email", "text"},
+		{"name", "text"},
+		{"created_at", "timestamp with time zone"},
+		{"last_login_at", "timestamp with time zone"},
+		{"access_token", "text"},
+		{"refresh_token", "text"},
+		{"token_type", "text"},
+		{"token_expires_at", "timestamp with time zone"},
+	}},
+	{"identity_link", false, []Column{
+		{"token_hash", "text"},
+		{"provider", "text"},
+		{"subject", "text"},
+		{"email", "text"},
+		{"name", "text"},
+		{"account_id", "text"},
+		{"attempts", "integer"},
+		{"expires_at", "timestamp with time zone"},
+	}},
+	{"access_token", false, []Column{
+		{"id", "text"},
+		{"account_id", "text"},
+		{"name", "text"},
+		{"token_hash", "text"},
+		{"scope", "text"},
+		{"created_at", "timestamp with time zone"},
+		{"last_used_at", "timestamp with time zone"},
+		{"revoked_at", "timestamp with time zone"},
+	}},
+	{"user_session", false, []Column{
+		{"id", "text"},
+		{"account_id", "text"},
+		{"created_at", "timestamp with time zone"},
+		{"revoked_at", "timestamp with time zone"},
+		{"last_seen_at", "timestamp with time zone"},
+		{"ip", "text"},
+		{"user_agent", "text"},
+	}},
+	{"refresh_token", false, []Column{
+		{"token_hash", "text"},
+		{"session_id", "text"},
+		{"created_at", "timestamp with time zone"},
+		{"expires_at", "timestamp with time zone"},
+		{"used_at", "timestamp with time zone"},
+	}},
+	{"totp", false, []Column{
+		{"account_id", "text"},
+		{"secret", "text"},
+		{"last_step", "bigint"},
+		{"created_at", "timestamp with time zone"},
+		{"confirmed_at", "timestamp with time zone"},
+	}},
+	{"recovery_code", false, []Column{
+		{"code_hash", "text"},
+		{"account_id", "text"},
+		{"used_at", "timestamp with time zone"},
+	}},
+	{"mfa_challenge", false, []Column{
+		{"token_hash", "text"},
+		{"account_id", "text"},
+		{"expires_at", "timestamp with time zone"},
+		{"attempts", "integer"},
+	}},
+	{"login_attempt", false, []Column{
+		{"scope", "text"},
+		{"key", "text"},
+		{"failures", "integer"},
+		{"last_failure", "timestamp with time zone"},
+		{"locked_until", "timestamp with time zone"},
+	}},
+	{"account_deletion", false, []Column{
+		{"account_id", "text"},
+		{"requested_at", "timestamp with time zone"},
+		{"purge_after", "timestamp with time zone"},
+		{"purged_at", "timestamp with time zone"},
+	}},
+	{"audit_log", false, []Column{
+		{"id", "bigint"},
+		{"actor_id", "text"},
+		{"action", "text"},
+		{"target_type", "text"},
+		{"target_id", "text"},
+		{"detail", "text"},
+		{"created_at", "timestamp with time zone"},
+	}},
+	{"account_ban", false, []Column{
+		{"account_id", "text"},
+		{"kind", "text"},
+		{"reason", "text"},
+		{"expires_at", "timestamp with time zone"},
+		{"created_by", "text"},
+		{"created_at", "timestamp with time zone"},
+	}},
+	{"setting", false, []Column{
+		{"name", "text"},
+		{"value", "text"},
+		{"updated_at", "timestamp with time zone"},
+	}},
+	{"verification_token", false, []Column{
+		{"token_hash", "text"},
+		{"account_id", "text"},
+		{"purpose", "text"},
+		{"data", "text"},
+		{"created_at", "timestamp with time zone"},
+		{"expires_at", "timestamp with time zone"},
+		{"consumed_at", "timestamp with time zone"},
+	}},
+	{"attachment", false, []Column{
+		{"id", "text"},
+		{"owner_id", "text"},
+		{"blob_key", "text"},
+		{"filename", "text"},
+		{"content_type", "text"},
+		{"size", "bigint"},
+		{"question_id", "text"},
+		{"answer_question_id", "text"},
+		{"answer_commenter", "text"},
+		{"created_at", "timestamp with time zone"},
+	}},
+	{"http_session", false, []Column{
+		{"id", "text"},
+		{"data", "bytea"},
+		{"expires_at", "timestamp with time zone"},
+	}},
+}
+
+// SchemaError - every difference found between the database and Schema
+type SchemaError struct {
+	Diff []string
+}
+
+func (e *SchemaError) Error() string {
+	return "database schema does not match:\n  " + strings.Join(e.Diff, "\n  ")
+}
+
+// VerifySchema - compares information_schema with Schema and the embedded migrations
+func VerifySchema(conn *pgxpool.Pool) error {
+	ctx := context.Background()
+
+	rows, err := conn.Query(ctx, "SELECT table_name, column_name, data_type FROM information_schema.columns WHERE table_schema=current_schema() ORDER BY table_name, ordinal_position")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := make(map[string][]Column)
+	for rows.Next() {
+		var t string
+		var c Column
+		if err := rows.Scan(&t, &c.Name, &c.Type); err != nil {
+			return err
+		}
+		found[t] = append(found[t], c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var diff []string
+	for _, t := range Schema {
+		diff = append(diff, compareTable(t, found[t.Name])...)
+	}
+
+	// the columns may match while newer migrations are still pending
+	m, err := NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	cur, err := m.Current()
+	if err != nil {
+		return err
+	}
+
+	if cur < m.Latest() {
+		diff = append(diff, fmt.Sprintf("database is at migration %d, expected %d (run migrate up)", cur, m.Latest()))
+	}
+
+	if len(diff) > 0 {
+		return &SchemaError{diff}
+	}
+
+	return nil
+}
+
+// compareTable - lists differences between expected and actual columns
+func compareTable(t Table, actual []Column) []string {
+	var diff []string
+
+	if len(actual) == 0 {
+		return []string{fmt.Sprintf("%s: table is missing", t.Name)}
+	}
+
+	if t.Positional {
+		for i, c := range t.Columns {
+			if i >= len(actual) {
+				diff = append(diff, fmt.Sprintf("%s: column %d missing, expected %s %s", t.Name, i+1, c.Name, c.Type))
+				continue
+			}
+
+			if actual[i] != c {
+				diff = append(diff, fmt.Sprintf("%s: column %d is %s %s, expected %s %s", t.Name, i+1, actual[i].Name, actual[i].Type, c.Name, c.Type))
+			}
+		}
+
+		for i := len(t.Columns); i < len(actual); i++ {
+			diff = append(diff, fmt.Sprintf("%s: unexpected column %d %s %s", t.Name, i+1, actual[i].Name, actual[i].Type))
+		}
+
+		return diff
+	}
+
+	types := make(map[string]string, len(actual))
+	for _, c := range actual {
+		types[c.Name] = c.Type
+	}
+
+	for _, c := range t.Columns {
+		typ, ok := types[c.Name]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("%s: missing column %s %s", t.Name, c.Name, c.Type))
+		case typ != c.Type:
+			diff = append(diff, fmt.Sprintf("%s: column %s is %s, expected %s", t.Name, c.Name, typ, c.Type))
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareTable(t *testing.T) {
+	named := Table{"account", false, []Column{{"id", "text"}, {"bio", "text"}}}
+	positional := Table{"answer", true, []Column{{"question_id", "text"}, {"answer", "text"}}}
+
+	tests := []struct {
+		name   string
+		table  Table
+		actual []Column
+		want   []string
+	}{
+		{"match", named, []Column{{"id", "text"}, {"bio", "text"}}, nil},
+		{"missing table", named, nil, []string{"account: table is missing"}},
+		{"missing column", named, []Column{{"id", "text"}}, []string{"account: missing column bio text"}},
+		{"type drift", named, []Column{{"id", "integer"}, {"bio", "text"}}, []string{"account: column id is integer, expected text"}},
+		{"extra column", named, []Column{{"id", "text"}, {"bio", "text"}, {"avatar", "text"}}, nil},
+		{"other order", named, []Column{{"bio", "text"}, {"id", "text"}}, nil},
+		{"positional match", positional, []Column{{"question_id", "text"}, {"answer", "text"}}, nil},
+		{"positional missing column", positional, []Column{{"question_id", "text"}}, []string{"answer: column 2 missing, expected answer text"}},
+		{"positional type drift", positional, []Column{{"question_id", "text"}, {"answer", "bytea"}}, []string{"answer: column 2 is answer bytea, expected answer text"}},
+		{"positional extra column", positional, []Column{{"question_id", "text"}, {"answer", "text"}, {"votes", "bigint"}}, []string{"answer: unexpected column 3 votes bigint"}},
+		{"positional other order", positional, []Column{{"answer", "text"}, {"question_id", "text"}}, []string{
+			"answer: column 1 is answer text, expected question_id text",
+			"answer: column 2 is question_id text, expected answer text",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareTable(tt.table, tt.actual); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareTable = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrInvalidRefresh - refresh token is unknown, used, expired or revoked
+var ErrInvalidRefresh = errors.New("invalid refresh token")
+
+// SessionDatabase - server side sessions and refresh tokens
+type SessionDatabase struct {
+	conn *pgxpool.Pool
+}
+
+// NewSessionDatabase - returns SessionDatabase
+func NewSessionDatabase(conn *pgxpool.Pool) *SessionDatabase {
+	return &SessionDatabase{conn}
+}
+
+// sessionListAge - sessions unused for longer aren't listed anymore
+const sessionListAge = 30 * 24 * time.Hour
+
+// CreateSession - creates session for the user and returns its id
+func (s *SessionDatabase) CreateSession(user string, ip string, ua string) (string, error) {
+	id := uuid.New().String()
+	_, err := s.conn.Exec(context.Background(), "INSERT INTO user_session (id, account_id, ip, user_agent) VALUES ($1, $2, $3, $4)", id, user, ip, ua)
+
+	return id, err
+}
+
+// ActiveSession - returns user of the session if it isn't revoked and the user isn't banned
+// last seen is updated at most once a minute
+func (s *SessionDatabase) ActiveSession(sid string) (string, error) {
+	ctx := context.Background()
+
+	var user string
+	var seen time.Time
+	err := s.conn.QueryRow(ctx, "SELECT account_id, last_seen_at FROM user_session WHERE id=$1 AND revoked_at IS NULL AND NOT EXISTS (SELECT 1 FROM account_ban WHERE account_ban.account_id=user_session.account_id AND "+activeBan+")", sid).Scan(&user, &seen)
+	if err != nil {
+		return "", err
+	}
+
+	if time.Since(seen) > time.Minute {
+		s.conn.Exec(ctx, "UPDATE user_session SET last_seen_at=now() WHERE id=$1", sid)
+	}
+
+	return user, nil
+}
+
+// GetSessions - active sessions of the user, last used first
+func (s *SessionDatabase) GetSessions(user string) ([]model.Session, error) {
+	ss := make([]model.Session, 0)
+
+	rows, err := s.conn.Query(context.Background(), "SELECT id, created_at, last_seen_at, ip, user_agent FROM user_session WHERE account_id=$1 AND revoked_at IS NULL AND last_seen_at>$2 ORDER BY last_seen_at DESC", user, time.Now().Add(-sessionListAge))
+	if err != nil {
+		return ss, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		us := model.Session{}
+		if err := rows.Scan(&us.ID, &us.CreatedAt, &us.LastSeenAt, &us.IP, &us.UserAgent); err != nil {
+			return ss, err
+		}
+		ss = append(ss, us)
+	}
+
+	return ss, rows.Err()
+}
+
+// RevokeUserSession - revokes session of the user, pgx.ErrNoRows if there is none
+func (s *SessionDatabase) RevokeUserSession(user string, sid string) error {
+	ct, err := s.conn.Exec(context.Background(), "UPDATE user_session SET revoked_at=now() WHERE id=$1 AND account_id=$2 AND revoked_at IS NULL", sid, user)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions - revokes every session of the user except keep
+func (s *SessionDatabase) RevokeOtherSessions(user string, keep string) error {
+	_, err := s.conn.Exec(context.Background(), "UPDATE user_session SET revoked_at=now() WHERE account_id=$1 AND id<>$2 AND revoked_at IS NULL", user, keep)
+
+	return err
+}
+
+// RevokeAllSessions - logs the user out everywhere and returns how many sessions were revoked
+func (s *SessionDatabase) RevokeAllSessions(user string) (int64, error) {
+	ct, err := s.conn.Exec(context.Background(), "UPDATE user_session SET revoked_at=now() WHERE account_id=$1 AND revoked_at IS NULL", user)
+	if err != nil {
+		return 0, err
+	}
+
+	return ct.RowsAffected(), nil
+}
+
+// RevokeSession - revokes session and with it every refresh token
+func (s *SessionDatabase) RevokeSession(sid string) error {
+	_, err := s.conn.Exec(context.Background(), "UPDATE user_session SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL", sid)
+
+	return err
+}
+
+// InsertRefresh - saves hashed refresh token of the session
+func (s *SessionDatabase) InsertRefresh(sid string, hash string, expires time.Time) error {
+	_, err := s.conn.Exec(context.Background(), "INSERT INTO refresh_token (token_hash, session_id, expires_at) VALUES ($1, $2, $3)", hash, sid, expires)
+
+	return err
+}
+
+// RotateRefresh - uses refresh token and saves the next one of the family
+// a token used twice means it was stolen, so the whole session is revoked
+func (s *SessionDatabase) RotateRefresh(hash string, next string, expires time.Time) (string, string, error) {
+	ctx := context.Background()
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var sid, user string
+	var exp time.Time
+	var used, revoked *time.Time
+	row := tx.QueryRow(ctx, "SELECT refresh_token.session_id, refresh_token.expires_at, refresh_token.used_at, user_session.account_id, user_session.revoked_at FROM refresh_token JOIN user_session ON refresh_token.session_id=user_session.id WHERE refresh_token.token_hash=$1 FOR UPDATE", hash)
+	err = row.Scan(&sid, &exp, &used, &user, &revoked)
+
+	switch {
+	case err == pgx.ErrNoRows:
+		return "", "", ErrInvalidRefresh
+	case err != nil:
+		return "", "", err
+	}
+
+	if revoked != nil || time.Now().After(exp) {
+		return "", "", ErrInvalidRefresh
+	}
+
+	if used != nil {
+		_, err = tx.Exec(ctx, "UPDATE user_session SET revoked_at=now() WHERE id=$1", sid)
+		if err != nil {
+			return "", "", err
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			return "", "", err
+		}
+
+		return "", "", ErrInvalidRefresh
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE refresh_token SET used_at=now() WHERE token_hash=$1", hash)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE user_session SET last_seen_at=now() WHERE id=$1", sid)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO refresh_token (token_hash, session_id, expires_at) VALUES ($1, $2, $3)", next, sid, expires)
+	if err != nil {
+		return "", "", err
+	}
+
+	return user, sid, tx.Commit(ctx)
+}
+
+// RevokeRefresh - revokes the session the refresh token belongs to
+func (s *SessionDatabase) RevokeRefresh(hash string) error {
+	ct, err := s.conn.Exec(context.Background(), "UPDATE user_session SET revoked_at=now() WHERE revoked_at IS NULL AND id=(SELECT session_id FROM refresh_token WHERE token_hash=$1)", hash)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return ErrInvalidRefresh
+	}
+
+	return nil
+}
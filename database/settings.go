@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// setting - value of the setting, its default when it was never set
+func setting(conn *pgxpool.Pool, name string) (bool, error) {
+	var v string
+	err := conn.QueryRow(context.Background(), "SELECT value FROM setting WHERE name=$1", name).Scan(&v)
+	switch {
+	case err == pgx.ErrNoRows:
+		return model.Settings[name], nil
+	case err != nil:
+		return false, err
+	}
+
+	return strconv.ParseBool(v)
+}
+
+// GetSettings - every setting with its current value
+func (d *AdminDatabase) GetSettings() (map[string]bool, error) {
+	ss := make(map[string]bool, len(model.Settings))
+	for name := range model.Settings {
+		v, err := setting(d.conn, name)
+		if err != nil {
+			return ss, err
+		}
+		ss[name] = v
+	}
+
+	return ss, nil
+}
+
+// SetSetting - changes the setting
+func (d *AdminDatabase) SetSetting(name string, value bool) error {
+	_, err := d.conn.Exec(context.Background(), "INSERT INTO setting (name, value) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET value=excluded.value, updated_at=now()", name, strconv.FormatBool(value))
+
+	return err
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// TokenDatabase - personal access tokens
+type TokenDatabase struct {
+	conn *pgxpool.Pool
+}
+
+// NewTokenDatabase - returns TokenDatabase
+func NewTokenDatabase(conn *pgxpool.Pool) *TokenDatabase {
+	return &TokenDatabase{conn}
+}
+
+// InsertToken - saves hashed token for the user
+func (t *TokenDatabase) InsertToken(user string, name string, scope string, hash string) (model.AccessToken, error) {
+	at := model.AccessToken{ID: uuid.New().String(), Name: name, Scope: scope}
+
+	row := t.conn.QueryRow(context.Background(), "INSERT INTO access_token (id, account_id, name, token_hash, scope) VALUES ($1, $2, $3, $4, $5) RETURNING created_at", at.ID, user, name, hash, scope)
+	err := row.Scan(&at.CreatedAt)
+
+	return at, err
+}
+
+// GetTokens - all tokens of the user that are not revoked
+func (t *TokenDatabase) GetTokens(user string) ([]model.AccessToken, error) {
+	ats := make([]model.AccessToken, 0)
+
+	rows, err := t.conn.Query(context.Background(), "SELECT id, name, scope, created_at, last_used_at FROM access_token WHERE account_id=$1 AND revoked_at IS NULL ORDER BY created_at", user)
+	if err != nil {
+		return ats, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		at := model.AccessToken{}
+		err := rows.Scan(&at.ID, &at.Name, &at.Scope, &at.CreatedAt, &at.LastUsedAt)
+
+		if err != nil {
+			return ats, err
+		}
+
+		ats = append(ats, at)
+	}
+
+	return ats, rows.Err()
+}
+
+// RenameToken - changes name of the users token
+func (t *TokenDatabase) RenameToken(user string, id string, name string) error {
+	ct, err := t.conn.Exec(context.Background(), "UPDATE access_token SET name=$1 WHERE id=$2 AND account_id=$3 AND revoked_at IS NULL", name, id, user)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// RevokeToken - revokes the users token
+func (t *TokenDatabase) RevokeToken(user string, id string) error {
+	ct, err := t.conn.Exec(context.Background(), "UPDATE access_token SET revoked_at=now() WHERE id=$1 AND account_id=$2 AND revoked_at IS NULL", id, user)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// LookupToken - returns user id and scope of a valid token hash, tokens of banned users aren't valid
+func (t *TokenDatabase) LookupToken(hash string) (string, string, error) {
+	ctx := context.Background()
+
+	var id, user, scope string
+	var lastUsed *time.Time
+	row := t.conn.QueryRow(ctx, "SELECT id, account_id, scope, last_used_at FROM access_token WHERE token_hash=$1 AND revoked_at IS NULL AND NOT EXISTS (SELECT 1 FROM account_ban WHERE account_ban.account_id=access_token.account_id AND "+activeBan+")", hash)
+	err := row.Scan(&id, &user, &scope, &lastUsed)
+
+	switch {
+	case err == pgx.ErrNoRows:
+		return "", "", errors.New("invalid access token")
+	case err != nil:
+		return "", "", err
+	}
+
+	// only write last used once a minute
+	if lastUsed == nil || time.Since(*lastUsed) > time.Minute {
+		_, err = t.conn.Exec(ctx, "UPDATE access_token SET last_used_at=now() WHERE id=$1", id)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return user, scope, nil
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// == two factor ==//
+
+// SaveTOTPSecret - saves new unconfirmed secret for the user
+func (a *AccountDatabase) SaveTOTPSecret(id string, secret string) error {
+	ct, err := a.conn.Exec(context.Background(), "INSERT INTO totp (account_id, secret) VALUES ($1, $2) ON CONFLICT (account_id) DO UPDATE SET secret=excluded.secret, last_step=0, created_at=now() WHERE totp.confirmed_at IS NULL", id, secret)
+	if err != nil {
+		return err
+	}
+
+	if ct.RowsAffected() == 0 {
+		return errors.New("two-factor authentication is already enabled")
+	}
+
+	return nil
+}
+
+// GetTOTP - gets totp secret of the user
+func (a *AccountDatabase) GetTOTP(id string) (model.TOTP, error) {
+	t := model.TOTP{}
+	row := a.conn.QueryRow(context.Background(), "SELECT secret, confirmed_at IS NOT NULL, last_step FROM totp WHERE account_id=$1", id)
+	err := row.Scan(&t.Secret, &t.Confirmed, &t.LastStep)
+
+	return t, err
+}
+
+// UseTOTPStep - marks time step as used, false if it was already used
+func (a *AccountDatabase) UseTOTPStep(id string, step int64) (bool, error) {
+	ct, err := a.conn.Exec(context.Background(), "UPDATE totp SET last_step=$1 WHERE account_id=$2 AND last_step<$1", step, id)
+	if err != nil {
+		return false, err
+	}
+
+	return ct.RowsAffected() == 1, nil
+}
+
+// ConfirmTOTP - enables two factor and replaces the recovery codes
+func (a *AccountDatabase) ConfirmTOTP(id string, hashes []string) error {
+	ctx := context.Background()
+
+	tx, err := a.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "UPDATE totp SET confirmed_at=now() WHERE account_id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, id, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SaveRecoveryCodes - replaces the recovery codes of the user
+func (a *AccountDatabase) SaveRecoveryCodes(id string, hashes []string) error {
+	ctx := context.Background()
+
+	tx, err := a.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = replaceRecoveryCodes(ctx, tx, id, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// replaceRecoveryCodes - deletes old codes and inserts the new hashes
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, id string, hashes []string) error {
+	_, err := tx.Exec(ctx, "DELETE FROM recovery_code WHERE account_id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hashes {
+		_, err = tx.Exec(ctx, "INSERT INTO recovery_code (code_hash, account_id) VALUES ($1, $2)", h, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode - uses recovery code once, false if it is unknown or used
+func (a *AccountDatabase) UseRecoveryCode(id string, hash string) (bool, error) {
+	ct, err := a.conn.Exec(context.Background(), "UPDATE recovery_code SET used_at=now() WHERE code_hash=$1 AND account_id=$2 AND used_at IS NULL", hash, id)
+	if err != nil {
+		return false, err
+	}
+
+	return ct.RowsAffected() == 1, nil
+}
+
+// DeleteTOTP - disables two factor and deletes recovery codes
+func (a *AccountDatabase) DeleteTOTP(id string) error {
+	ctx := context.Background()
+
+	tx, err := a.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM totp WHERE account_id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM recovery_code WHERE account_id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CreateChallenge - saves half authenticated login
+func (a *AccountDatabase) CreateChallenge(id string, hash string, expires time.Time) error {
+	_, err := a.conn.Exec(context.Background(), "INSERT INTO mfa_challenge (token_hash, account_id, expires_at) VALUES ($1, $2, $3)", hash, id, expires)
+
+	return err
+}
+
+// UseChallenge - counts an attempt and returns the user of a valid challenge
+func (a *AccountDatabase) UseChallenge(hash string, maxAttempts int) (string, error) {
+	var id string
+	row := a.conn.QueryRow(context.Background(), "UPDATE mfa_challenge SET attempts=attempts+1 WHERE token_hash=$1 AND expires_at>now() AND attempts<$2 RETURNING account_id", hash, maxAttempts)
+	err := row.Scan(&id)
+
+	if err == pgx.ErrNoRows {
+		err = errors.New("login expired, log in again")
+	}
+
+	return id, err
+}
+
+// DeleteChallenge - removes challenge once the login is complete
+func (a *AccountDatabase) DeleteChallenge(hash string) error {
+	_, err := a.conn.Exec(context.Background(), "DELETE FROM mfa_challenge WHERE token_hash=$1 OR expires_at<now()", hash)
+
+	return err
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// verification token errors
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenUsed     = errors.New("token already used")
+	ErrTokenExpired  = errors.New("token expired")
+)
+
+// tokenConn - pool or transaction verification tokens are read and written with
+type tokenConn interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// issueToken - saves hashed token of the user for the purpose
+// data is what the purpose needs to remember, like the new email
+func issueToken(c tokenConn, id string, purpose string, hash string, data string, expires time.Time) error {
+	_, err := c.Exec(context.Background(), "INSERT INTO verification_token (token_hash, account_id, purpose, data, expires_at) VALUES ($1, $2, $3, $4, $5)", hash, id, purpose, data, expires)
+
+	return err
+}
+
+// checkToken - user of the token if it can be used, without using it
+// otherwise why it can't be used
+func checkToken(c tokenConn, hash string, purpose string) (string, error) {
+	var id string
+	var expires time.Time
+	var consumed *time.Time
+	err := c.QueryRow(context.Background(), "SELECT account_id, expires_at, consumed_at FROM verification_token WHERE token_hash=$1 AND purpose=$2", hash, purpose).Scan(&id, &expires, &consumed)
+
+	switch {
+	case err == pgx.ErrNoRows:
+		return "", ErrTokenNotFound
+	case err != nil:
+		return "", err
+	case consumed != nil:
+		return "", ErrTokenUsed
+	case !time.Now().Before(expires):
+		return "", ErrTokenExpired
+	}
+
+	return id, nil
+}
+
+// consumeToken - uses the token once and returns its user and data
+func consumeToken(c tokenConn, hash string, purpose string) (string, string, error) {
+	var id, data string
+	row := c.QueryRow(context.Background(), "UPDATE verification_token SET consumed_at=now() WHERE token_hash=$1 AND purpose=$2 AND consumed_at IS NULL AND expires_at>now() RETURNING account_id, data", hash, purpose)
+	err := row.Scan(&id, &data)
+
+	// tell why the token can't be used
+	if err == pgx.ErrNoRows {
+		if _, err = checkToken(c, hash, purpose); err == nil {
+			err = ErrTokenExpired
+		}
+	}
+
+	return id, data, err
+}
+
+// consumeTokens - uses up every other token of the user for the purpose
+func consumeTokens(c tokenConn, id string, purpose string) error {
+	_, err := c.Exec(context.Background(), "UPDATE verification_token SET consumed_at=now() WHERE account_id=$1 AND purpose=$2 AND consumed_at IS NULL", id, purpose)
+
+	return err
+}
+
+// tokenRetention - how long used and expired tokens are kept
+const tokenRetention = 24 * time.Hour
+
+// PurgeVerificationTokens - deletes tokens that were used or expired a while ago
+func PurgeVerificationTokens(conn *pgxpool.Pool) {
+	_, err := conn.Exec(context.Background(), "DELETE FROM verification_token WHERE COALESCE(consumed_at, expires_at)<$1", time.Now().Add(-tokenRetention))
+	if err != nil {
+		log.Println("could not delete old verification tokens:", err)
+	}
+}
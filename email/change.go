@@ -0,0 +1,36 @@
+package email
+
+import "html"
+
+func ChangeEmailMsg(url string) string {
+	return layoutMsg(
+		`You asked to use this address for your account. Click the
+                              button below to confirm it, the link is valid for six
+                              hours.`,
+		"Confirm Email",
+		url,
+		`If you didn't ask for this, you can safely ignore this
+                                    email.`,
+	)
+}
+
+func EmailChangeNoticeMsg(e string) string {
+	return layoutMsg(
+		`Someone asked to change the email of your account to `+html.EscapeString(e)+`.
+                              The change happens once the new address is confirmed.`,
+		"",
+		"",
+		`If it wasn't you, change your password right away, the
+                                    person knows it.`,
+	)
+}
+
+func PasswordChangedMsg() string {
+	return layoutMsg(
+		`The password of your account was changed and every other
+                              device was logged out.`,
+		"",
+		"",
+		`If it wasn't you, reset your password right away.`,
+	)
+}
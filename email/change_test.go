@@ -0,0 +1,18 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmailChangeNoticeMsgEscapes(t *testing.T) {
+	msg := EmailChangeNoticeMsg(`"<script>alert(1)</script>"@evil.test`)
+
+	if strings.Contains(msg, "<script>") {
+		t.Error("new address is not escaped")
+	}
+
+	if !strings.Contains(msg, "&lt;script&gt;") {
+		t.Error("escaped address is missing")
+	}
+}
@@ -5,7 +5,8 @@ import (
 	"os"
 )
 
-func SignUpEmail(e string, n string, url string) error {
+// send - sends html email through gmail smtp
+func send(e string, subject string, msg string) error {
 	// email credentials
 	email := os.Getenv("GM_EMAIL")
 	pass := os.Getenv("GM_PASS")
@@ -19,54 +20,46 @@ func SignUpEmail(e string, n string, url string) error {
 
 	// email configuration
 	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-	subject := "Subject: Files Signup\n"
-	msg := SignUpEmailMsg(n, url)
 
 	// message configuration
-	message := []byte(subject + mime + msg)
+	message := []byte("Subject: " + subject + "\n" + mime + msg)
 
 	// authenticating user
 	auth := smtp.PlainAuth("", email, pass, smtpHost)
 
 	// sending email
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, email, to, message)
-
-	if err != nil {
-		return err
-	}
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, email, to, message)
+}
 
-	return nil
+func SignUpEmail(e string, n string, url string) error {
+	return send(e, "Files Signup", SignUpEmailMsg(n, url))
 }
 
 func ForgotEmail(e string, url string) error {
-	// email credentials
-	email := os.Getenv("GM_EMAIL")
-	pass := os.Getenv("GM_PASS")
-
-	// sending email to
-	to := []string{e}
-
-	// smtp configuration
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-
-	// email configuration
-	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-	subject := "Subject: Files Signup\n"
-	msg := ForgotEmailMsg(url)
+	return send(e, "Files Signup", ForgotEmailMsg(url))
+}
 
-	// message configuration
-	message := []byte(subject + mime + msg)
+// LockoutEmail - tells the user that logins are paused after failed attempts
+func LockoutEmail(e string) error {
+	return send(e, "Files Account Locked", LockoutEmailMsg())
+}
 
-	// authenticating user
-	auth := smtp.PlainAuth("", email, pass, smtpHost)
+// ChangeEmailEmail - asks the new address to confirm the email change
+func ChangeEmailEmail(e string, url string) error {
+	return send(e, "Files Confirm Email", ChangeEmailMsg(url))
+}
 
-	// sending email
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, email, to, message)
+// EmailChangeNoticeEmail - tells the old address about the email change
+func EmailChangeNoticeEmail(e string, newEmail string) error {
+	return send(e, "Files Email Change", EmailChangeNoticeMsg(newEmail))
+}
 
-	if err != nil {
-		return err
-	}
+// PasswordChangedEmail - tells the user that the password was changed
+func PasswordChangedEmail(e string) error {
+	return send(e, "Files Password Changed", PasswordChangedMsg())
+}
 
-	return nil
+// MagicLinkEmail - sends the sign-in link
+func MagicLinkEmail(e string, url string) error {
+	return send(e, "Files Sign In", MagicLinkMsg(url))
 }
@@ -0,0 +1,14 @@
+package email
+
+func LockoutEmailMsg() string {
+	return layoutMsg(
+		`There were too many failed attempts to log into your account, so
+                              logging in is paused for a while. If it was you, wait
+                              a few minutes and try again or reset your password.`,
+		"",
+		"",
+		`If it wasn't you, someone may be guessing your password. Your
+                                    account is safe, but you may want to choose a
+                                    stronger password.`,
+	)
+}
@@ -0,0 +1,12 @@
+package email
+
+func MagicLinkMsg(url string) string {
+	return layoutMsg(
+		`Click the button below to sign in. The link works once, for
+                              fifteen minutes and only in the browser that asked for it.`,
+		"Sign In",
+		url,
+		`If you didn't ask for this, you can safely ignore this
+                                    email.`,
+	)
+}
@@ -1,7 +1,9 @@
 package helper
 
 import (
+	"net"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/dchest/uniuri"
@@ -52,3 +54,20 @@ func UniqueQuestion(s string) string {
 	s = s + "-" + uniuri.NewLen(8)
 	return s
 }
+
+// ClientIP - ip address of the client
+// X-Forwarded-For is only trusted when TRUST_PROXY is true
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		if f := r.Header.Get("X-Forwarded-For"); f != "" {
+			return strings.TrimSpace(strings.Split(f, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
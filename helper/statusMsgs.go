@@ -3,6 +3,7 @@ package helper
 import (
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // Not Found - 404
@@ -26,6 +27,7 @@ func ASM(w http.ResponseWriter, s int, msg string) {
 	status := map[int]statusStruct{
 		200: {http.StatusOK, "ok"},
 		201: {http.StatusCreated, "created"},
+		202: {http.StatusAccepted, "accepted"},
 		204: {http.StatusNoContent, "no content"},
 		401: {http.StatusUnauthorized, "unauthorized"},
 		403: {http.StatusForbidden, "forbidden"},
@@ -33,7 +35,9 @@ func ASM(w http.ResponseWriter, s int, msg string) {
 		405: {http.StatusMethodNotAllowed, "method not allowed"},
 		409: {http.StatusConflict, "conflict"},
 		422: {http.StatusUnprocessableEntity, "unprocessable entity"},
+		429: {http.StatusTooManyRequests, "too many requests"},
 		500: {http.StatusInternalServerError, "internal server error"},
+		502: {http.StatusBadGateway, "bad gateway"},
 	}
 
 	sm := status[s].msg
@@ -47,3 +51,14 @@ func ASM(w http.ResponseWriter, s int, msg string) {
 		w.Write([]byte(`{"status": "` + strconv.Itoa(s) + `", "message": "` + sm + `"}`))
 	}
 }
+
+// TooMany - 429 with Retry-After in seconds
+func TooMany(w http.ResponseWriter, d time.Duration) {
+	secs := int(d.Seconds())
+	if d > time.Duration(secs)*time.Second {
+		secs++
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	ASM(w, 429, "too many attempts, try again later")
+}
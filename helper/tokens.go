@@ -0,0 +1,24 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// HashToken - sha256 of a token, tokens are never stored in plain text
+func HashToken(t string) string {
+	h := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(h[:])
+}
+
+// BearerToken - token from the "Authorization: Bearer" header
+func BearerToken(r *http.Request) string {
+	a := r.Header.Get("Authorization")
+	if len(a) < 7 || !strings.EqualFold(a[:7], "bearer ") {
+		return ""
+	}
+
+	return strings.TrimSpace(a[7:])
+}
@@ -0,0 +1,104 @@
+package helper
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret - "12345678901234567890", the SHA1 key of RFC 6238 appendix B
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// RFC 6238 appendix B, the last six of the eight digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, v := range rfcVectors {
+		if got := totpCode([]byte("12345678901234567890"), v.unix/totpPeriod); got != v.code {
+			t.Errorf("code at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, v := range rfcVectors {
+		at := time.Unix(v.unix, 0)
+
+		step, ok := ValidateTOTP(rfcSecret, v.code, at)
+		if !ok || step != v.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%s) at %d = %d, %v", v.code, v.unix, step, ok)
+		}
+
+		// one step of drift on both sides
+		for _, d := range []int64{-totpPeriod, totpPeriod} {
+			if _, ok := ValidateTOTP(rfcSecret, v.code, time.Unix(v.unix+d, 0)); !ok {
+				t.Errorf("code at %d rejected %ds away", v.unix, d)
+			}
+		}
+
+		// two steps are too many, times before 1970 round towards step 0
+		for _, d := range []int64{-2 * totpPeriod, 2 * totpPeriod} {
+			if v.unix+d < 0 {
+				continue
+			}
+			if _, ok := ValidateTOTP(rfcSecret, v.code, time.Unix(v.unix+d, 0)); ok {
+				t.Errorf("code at %d accepted %ds away", v.unix, d)
+			}
+		}
+	}
+
+	at := time.Unix(59, 0)
+	if _, ok := ValidateTOTP(strings.ToLower(rfcSecret), "287 082", at); !ok {
+		t.Error("lower case secret or spaced code rejected")
+	}
+
+	for _, bad := range []string{"", "287083", "28708", "2870820", "abcdef"} {
+		if _, ok := ValidateTOTP(rfcSecret, bad, at); ok {
+			t.Errorf("code %q accepted", bad)
+		}
+	}
+
+	if _, ok := ValidateTOTP("not base32!", "287082", at); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	s := NewTOTPSecret()
+
+	key, err := totpEncoding.DecodeString(s)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes, %v", s, len(key), err)
+	}
+
+	if NewTOTPSecret() == s {
+		t.Error("secrets repeat")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	u, err := url.Parse(TOTPURI("Files", "a@b.c", "ABC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Files:a@b.c" {
+		t.Errorf("uri = %s", u)
+	}
+
+	q := u.Query()
+	if q.Get("secret") != "ABC" || q.Get("issuer") != "Files" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}
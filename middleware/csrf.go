@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+)
+
+// csrf token is sent back in the header, or the form field for plain html forms
+const (
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+	csrfMaxAge = 86400 * 7
+)
+
+// CSRF - double submit tokens signed with the key and origin checks for unsafe methods
+// requests authenticated with a bearer token can't be forged by a browser and are exempt
+type CSRF struct {
+	key     []byte
+	origins []string
+}
+
+// NewCSRF - returns CSRF, origins are the urls allowed to send unsafe requests
+// without a key tokens are signed with a random one and don't survive restarts
+func NewCSRF(key []byte, origins ...string) (*CSRF, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &CSRF{key: key}
+	for _, o := range origins {
+		if o = origin(o); o != "" {
+			c.origins = append(c.origins, o)
+		}
+	}
+
+	return c, nil
+}
+
+// origin - scheme and host of the url
+func origin(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// sign - signature of the token nonce
+func (c *CSRF) sign(nonce string) string {
+	m := hmac.New(sha256.New, c.key)
+	m.Write([]byte("csrf:" + nonce))
+
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// valid - tells if the token was signed with the key
+func (c *CSRF) valid(token string) bool {
+	i := strings.IndexByte(token, '.')
+	if i <= 0 {
+		return false
+	}
+
+	return hmac.Equal([]byte(token[i+1:]), []byte(c.sign(token[:i])))
+}
+
+// Token - csrf token of the browser, a new one is set when it has none
+func (c *CSRF) Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	if ck, err := r.Cookie(csrfCookie); err == nil && c.valid(ck.Value) {
+		return ck.Value, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+	token := nonce + "." + c.sign(nonce)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   csrfMaxAge,
+		HttpOnly: true,
+	})
+
+	return token, nil
+}
+
+// allowedOrigin - tells if the Origin, or the Referer without it, may send unsafe requests
+// same origin requests are always allowed, requests with neither header are left to the token
+func (c *CSRF) allowedOrigin(r *http.Request) bool {
+	o := r.Header.Get("Origin")
+	if o == "" {
+		ref := r.Header.Get("Referer")
+		if ref == "" {
+			return true
+		}
+		o = origin(ref)
+	} else {
+		o = origin(o)
+	}
+
+	if o == "" {
+		return false
+	}
+
+	if u, err := url.Parse(o); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, a := range c.origins {
+		if o == a {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Protect - rejects forged unsafe requests
+func (c *CSRF) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS", "TRACE":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if helper.BearerToken(r) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.allowedOrigin(r) {
+			helper.ASM(w, 403, "origin not allowed")
+			return
+		}
+
+		// without cookies there is no session for a forged request to ride on
+		if len(r.Cookies()) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ck, err := r.Cookie(csrfCookie)
+		if err != nil || !c.valid(ck.Value) {
+			helper.ASM(w, 403, "missing csrf token, get one from /account/csrf")
+			return
+		}
+
+		t := r.Header.Get(csrfHeader)
+		if t == "" {
+			t = r.FormValue(csrfField)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(t), []byte(ck.Value)) != 1 {
+			helper.ASM(w, 403, "invalid csrf token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
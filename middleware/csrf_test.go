@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestCSRF(t *testing.T, key string, origins ...string) *CSRF {
+	t.Helper()
+
+	c, err := NewCSRF([]byte(key), origins...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// issue - csrf token set on a fresh request
+func issue(t *testing.T, c *CSRF) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	tok, err := c.Token(w, httptest.NewRequest("GET", "/account/csrf", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ck := w.Result().Cookies()
+	if len(ck) != 1 || ck[0].Name != csrfCookie || ck[0].Value != tok || !ck[0].HttpOnly {
+		t.Fatalf("cookies %+v", ck)
+	}
+
+	return tok
+}
+
+func TestCSRFSignVerify(t *testing.T) {
+	c := newTestCSRF(t, "key-1")
+	tok := issue(t, c)
+
+	if !c.valid(tok) {
+		t.Fatal("own token is invalid")
+	}
+
+	i := strings.IndexByte(tok, '.')
+	for name, bad := range map[string]string{
+		"other key":     issue(t, newTestCSRF(t, "key-2")),
+		"other nonce":   "x" + tok,
+		"no signature":  tok[:i],
+		"empty nonce":   tok[i:],
+		"cut signature": tok[:len(tok)-1],
+		"empty":         "",
+	} {
+		if c.valid(bad) {
+			t.Errorf("%s token is valid", name)
+		}
+	}
+
+	// a valid cookie is kept, an invalid one is replaced
+	r := httptest.NewRequest("GET", "/account/csrf", nil)
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: tok})
+	w := httptest.NewRecorder()
+	if got, _ := c.Token(w, r); got != tok || len(w.Result().Cookies()) != 0 {
+		t.Errorf("valid cookie replaced by %q", got)
+	}
+
+	r = httptest.NewRequest("GET", "/account/csrf", nil)
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "forged.token"})
+	if got, _ := c.Token(httptest.NewRecorder(), r); got == "forged.token" || !c.valid(got) {
+		t.Errorf("invalid cookie kept: %q", got)
+	}
+
+	// tokens don't repeat
+	if issue(t, c) == tok {
+		t.Error("same token twice")
+	}
+}
+
+func TestCSRFProtect(t *testing.T) {
+	c := newTestCSRF(t, "key-1", "https://app.test/")
+	tok := issue(t, c)
+	other := issue(t, c)
+
+	h := c.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		header string
+		field  string
+		origin string
+		bearer bool
+		status int
+	}{
+		{"safe method", "GET", tok, "", "", "https://evil.test", false, 200},
+		{"header", "POST", tok, tok, "", "", false, 200},
+		{"form field", "POST", tok, "", tok, "", false, 200},
+		{"allowed origin", "POST", tok, tok, "", "https://app.test", false, 200},
+		{"same origin", "POST", tok, tok, "", "http://example.com", false, 200},
+		{"no cookies", "POST", "", "", "", "", false, 200},
+		{"bearer", "POST", tok, "", "", "https://evil.test", true, 200},
+		{"missing token", "POST", tok, "", "", "", false, 403},
+		{"other token", "DELETE", tok, other, "", "", false, 403},
+		{"forged cookie", "POST", "a.b", "a.b", "", "", false, 403},
+		{"other origin", "POST", tok, tok, "", "https://evil.test", false, 403},
+		{"opaque origin", "POST", tok, tok, "", "null", false, 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.field != "" {
+				body = strings.NewReader(url.Values{csrfField: {tt.field}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+
+			r := httptest.NewRequest(tt.method, "http://example.com/account/logout", body)
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: csrfCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(csrfHeader, tt.header)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.bearer {
+				r.Header.Set("Authorization", "Bearer pat")
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCSRFReferer(t *testing.T) {
+	c := newTestCSRF(t, "key-1", "https://app.test")
+
+	for ref, want := range map[string]bool{
+		"":                          true,
+		"https://app.test/page?a=1": true,
+		"http://example.com/x":      true,
+		"https://evil.test/page":    false,
+		"not a url":                 false,
+	} {
+		r := httptest.NewRequest("POST", "http://example.com/", nil)
+		if ref != "" {
+			r.Header.Set("Referer", ref)
+		}
+		if got := c.allowedOrigin(r); got != want {
+			t.Errorf("referer %q allowed = %v, want %v", ref, got, want)
+		}
+	}
+}
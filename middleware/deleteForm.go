@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// DeleteForm - parses form body of DELETE requests
+// net/http only reads the body for POST, PUT and PATCH
+func DeleteForm(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		if r.Method == "DELETE" && ct == "application/x-www-form-urlencoded" {
+			b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+			if err == nil {
+				r.PostForm, err = url.ParseQuery(string(b))
+			}
+
+			if err != nil {
+				r.PostForm = url.Values{}
+			}
+
+			// merges PostForm with the query string
+			r.ParseForm()
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
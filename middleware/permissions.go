@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// UserLookup - user of the request
+type UserLookup interface {
+	GetUser(r *http.Request) (string, error)
+}
+
+// RoleLookup - role of the user
+type RoleLookup interface {
+	GetRole(id string) (string, error)
+}
+
+// Permissions - checks the role of the user against route permissions
+type Permissions struct {
+	users UserLookup
+	roles RoleLookup
+}
+
+// NewPermissions - returns Permissions
+func NewPermissions(u UserLookup, r RoleLookup) *Permissions {
+	return &Permissions{u, r}
+}
+
+// Require - lets only users whose role has every permission through
+func (p *Permissions) Require(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, err := p.users.GetUser(r)
+			if err != nil {
+				helper.ASM(w, 401, "")
+				return
+			}
+
+			role, err := p.roles.GetRole(id)
+			if err != nil {
+				helper.ASM(w, 401, "")
+				return
+			}
+
+			for _, perm := range perms {
+				if !model.Can(role, perm) {
+					helper.ASM(w, 403, "missing permission "+perm)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -11,7 +11,7 @@ func UsefulHeaders(next http.Handler) http.Handler {
 		w.Header().Set("access-control-allow-origin", os.Getenv("FRONTEND"))
 		w.Header().Set("access-control-allow-methods", "*")
 		w.Header().Set("access-control-allow-credentials", "true")
-		w.Header().Set("access-control-allow-headers", "*")
+		w.Header().Set("access-control-allow-headers", "*, Authorization, Content-Type, X-CSRF-Token")
 
 		if os.Getenv("APP_ENV") == "production" {
 			w.Header().Set("Content-Security-Policy", "default-src 'self';base-uri 'self';block-all-mixed-content;font-src 'self' https: data:;frame-ancestors 'self';img-src 'self' data:;object-src 'none';script-src 'self';script-src-attr 'none';style-src 'self' https: 'unsafe-inline';upgrade-insecure-requests")
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// kinds of bans
+const (
+	BanSuspend = "suspend"
+	BanBan     = "ban"
+)
+
+// Ban - suspension or ban of an account, no expiry lasts until it's lifted
+type Ban struct {
+	Kind      string     `json:"kind"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// AdminUser - account as administrators see it
+type AdminUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	UniqueName string `json:"uniqueName"`
+	Role       string `json:"role"`
+	Confirmed  bool   `json:"confirmed"`
+	Ban        *Ban   `json:"ban"`
+}
+
+// UserPage - one page of searched users
+type UserPage struct {
+	Users  []AdminUser `json:"users"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// ContentCounts - how much the user has posted
+type ContentCounts struct {
+	Questions int `json:"questions"`
+	Answers   int `json:"answers"`
+	Votes     int `json:"votes"`
+}
+
+// Login - session started by logging in, revoked ones included
+type Login struct {
+	SessionID  string     `json:"sessionId"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"userAgent"`
+}
+
+// AdminProfile - profile, content counts and login history of an account
+type AdminProfile struct {
+	AdminUser
+	Counts     ContentCounts    `json:"counts"`
+	Logins     []Login          `json:"logins"`
+	Identities []LinkedIdentity `json:"identities"`
+}
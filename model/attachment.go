@@ -0,0 +1,25 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAttachment - an attachment isn't an unattached upload of the poster
+var ErrAttachment = errors.New("attachments must be your own uploads that aren't attached yet")
+
+// ErrQuota - the upload doesn't fit in the attachment quota of the user
+var ErrQuota = errors.New("attachment quota exceeded")
+
+// Attachment - uploaded file of a question or answer
+// the url is signed and stops working after a while
+type Attachment struct {
+	ID          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Owner       string    `json:"-"`
+	BlobKey     string    `json:"-"`
+}
@@ -0,0 +1,6 @@
+package model
+
+// CSRFToken - token unsafe requests of cookie sessions have to send back
+type CSRFToken struct {
+	Token string `json:"csrfToken"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ExportVote - vote of the user
+type ExportVote struct {
+	Question_ID string `json:"questionId"`
+	Likes       bool   `json:"like"`
+	Dislike     bool   `json:"dislike"`
+}
+
+// AccountExport - everything stored about the user
+type AccountExport struct {
+	ExportedAt time.Time        `json:"exportedAt"`
+	Profile    UserSend         `json:"profile"`
+	Questions  []FilesQuestion  `json:"questions"`
+	Answers    []FilesComment   `json:"answers"`
+	Votes      []ExportVote     `json:"votes"`
+	Identities []LinkedIdentity `json:"identities"`
+}
@@ -0,0 +1,20 @@
+package model
+
+// orders of the question feed
+const (
+	SortNewest   = "newest"
+	SortOldest   = "oldest"
+	SortLikes    = "likes"
+	SortActivity = "activity"
+)
+
+// QuestionFilter - sort and filters of the question feed, zero values don't filter
+// Since and Until are RFC3339 times in UTC like created_at, Until is exclusive
+type QuestionFilter struct {
+	Sort     string
+	Answered *bool
+	Author   string
+	Since    string
+	Until    string
+	MinLikes int
+}
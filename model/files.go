@@ -21,12 +21,15 @@ type FilesComment struct {
 
 // FilesSend - sending struct
 type FilesSend struct {
-	ID          string `json:"id"`
-	Question    string `json:"question"`
-	Slug        string `json:"slug"`
-	CreatedAt   string `json:"createdAt"`
-	Username    string `json:"username"`
-	Unique_Name string `json:"uniqueName"`
+	ID          string       `json:"id"`
+	Question    string       `json:"question"`
+	Slug        string       `json:"slug"`
+	CreatedAt   string       `json:"createdAt"`
+	Username    string       `json:"username"`
+	Unique_Name string       `json:"uniqueName"`
+	Avatar      string       `json:"avatar"`
+	AvatarKey   string       `json:"-"`
+	Attachments []Attachment `json:"attachments"`
 }
 
 // LikeModel - get like
@@ -39,20 +42,25 @@ type LikeModel struct {
 
 // GetQuestions - hold questions struct
 type GetQuestions struct {
-	ID         string `json:"id"`
-	Question   string `json:"question"`
-	Poster     string `json:"poster"`
-	Slug       string `json:"slug"`
-	Created_At string `json:"createdAt"`
-	Answer     string `json:"answer"`
-	Likes      int    `json:"likes"`
+	ID           string `json:"id"`
+	Question     string `json:"question"`
+	Poster       string `json:"poster"`
+	Slug         string `json:"slug"`
+	Created_At   string `json:"createdAt"`
+	Answer       string `json:"answer"`
+	Likes        int    `json:"likes"`
+	Answers      int    `json:"answers"`
+	LastActivity string `json:"lastActivity"`
 }
 
 // GetAnswers - hold all the answer struct
 type GetAnswers struct {
-	Question_ID string `json:"questionId"`
-	Answer      string `json:"answer"`
-	Created_At  string `json:"createdAt"`
-	Username    string `json:"username"`
-	Unique_Name string `json:"uniqueName"`
+	Question_ID string       `json:"questionId"`
+	Answer      string       `json:"answer"`
+	Created_At  string       `json:"createdAt"`
+	Username    string       `json:"username"`
+	Unique_Name string       `json:"uniqueName"`
+	Avatar      string       `json:"avatar"`
+	AvatarKey   string       `json:"-"`
+	Attachments []Attachment `json:"attachments"`
 }
@@ -1,23 +1,47 @@
 package model
 
-// GoogleData - holds data of google
-type GoogleData struct {
-	ID             string `json:"id"`
-	Email          string `json:"email"`
-	Verified_Email bool   `json:"verified_email"`
-	Name           string `json:"name"`
-	Given_Name     string `json:"given_name"`
-	Family_Name    string `json:"family_name"`
-	Picture        string `json:"picture"`
-	Locale         string `json:"locale"`
-	Token          string `json:"token"`
+import "time"
+
+// NoPassword - password of accounts created through a provider
+const NoPassword = " "
+
+// Identity - user as told by an oauth provider
+type Identity struct {
+	Provider      string `json:"provider"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	Name          string `json:"name"`
+}
+
+// LinkedIdentity - identity linked to the account
+type LinkedIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Identities - linked identities and whether the account has a local password
+type Identities struct {
+	Identities  []LinkedIdentity `json:"identities"`
+	HasPassword bool             `json:"hasPassword"`
+}
+
+// LinkChallenge - sent when the email of the identity belongs to an account
+type LinkChallenge struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	LinkToken string `json:"linkToken"`
 }
 
-// InsertGoolge - struct that holds adding user
-type InsertGoogle struct {
-	Google_Id    string `json:"google_id"`
-	Google_Token string `json:"google_token"`
-	Google_Email string `json:"google_email"`
-	Google_Name  string `json:"google_name"`
-	Account_ID   string `json:"account_id"`
+// ProviderToken - oauth token of an identity, access and refresh token are encrypted
+type ProviderToken struct {
+	Provider     string
+	Subject      string
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	Expiry       *time.Time
 }
@@ -0,0 +1,38 @@
+package model
+
+// Cursor - position in a listing, pages continue after it or, with Before, end before it
+// Key is the value the listing is sorted by, Sort the order it belongs to
+type Cursor struct {
+	Key    string `json:"k"`
+	ID     string `json:"i"`
+	Sort   string `json:"s,omitempty"`
+	Before bool   `json:"b,omitempty"`
+}
+
+// Span - cursors of the first and last rows of a page, More when rows go on
+// past it in the direction it was read
+type Span struct {
+	First Cursor
+	Last  Cursor
+	Count int
+	More  bool
+}
+
+// Page - limit and opaque cursors of the pages around, empty when there is none
+type Page struct {
+	Limit int    `json:"limit"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// QuestionsPage - page of questions
+type QuestionsPage struct {
+	Questions []GetQuestions `json:"questions"`
+	Page
+}
+
+// AnswersPage - page of answers
+type AnswersPage struct {
+	Answers []GetAnswers `json:"answers"`
+	Page
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// Profile - public profile of a user
+type Profile struct {
+	UniqueName string    `json:"uniqueName"`
+	Name       string    `json:"name"`
+	Bio        string    `json:"bio"`
+	Avatar     string    `json:"avatar"`
+	AvatarKey  string    `json:"-"`
+	JoinedAt   time.Time `json:"joinedAt"`
+	Questions  int       `json:"questions"`
+	Answers    int       `json:"answers"`
+	Likes      int       `json:"likes"`
+}
+
+// ProfileQuestion - question on a profile
+type ProfileQuestion struct {
+	ID        string `json:"id"`
+	Question  string `json:"question"`
+	Slug      string `json:"slug"`
+	CreatedAt string `json:"createdAt"`
+	Answers   int    `json:"answers"`
+	Likes     int    `json:"likes"`
+}
+
+// ProfileAnswer - answer on a profile with the question it answers
+type ProfileAnswer struct {
+	QuestionID   string `json:"questionId"`
+	Question     string `json:"question"`
+	QuestionSlug string `json:"questionSlug"`
+	Answer       string `json:"answer"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// ProfileQuestions - one page of questions of a profile
+type ProfileQuestions struct {
+	Questions []ProfileQuestion `json:"questions"`
+	Total     int               `json:"total"`
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+}
+
+// ProfileAnswers - one page of answers of a profile
+type ProfileAnswers struct {
+	Answers []ProfileAnswer `json:"answers"`
+	Total   int             `json:"total"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+}
+
+// Avatar - links to every size of the user's avatar
+type Avatar struct {
+	Avatar string         `json:"avatar"`
+	Sizes  map[int]string `json:"sizes"`
+}
@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+// roles
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// permissions
+const (
+	PermEditAnyPost    = "edit-any-post"
+	PermDeleteAnyPost  = "delete-any-post"
+	PermBanUser        = "ban-user"
+	PermViewAuditLog   = "view-audit-log"
+	PermManageUsers    = "manage-users"
+	PermManageSettings = "manage-settings"
+)
+
+// RolePermissions - what every role is allowed to do
+var RolePermissions = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermEditAnyPost, PermDeleteAnyPost, PermBanUser},
+	RoleAdmin:     {PermEditAnyPost, PermDeleteAnyPost, PermBanUser, PermViewAuditLog, PermManageUsers, PermManageSettings},
+}
+
+// roleRank - staff can only act on users with a lower rank
+var roleRank = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// Outranks - tells if role is higher than other
+func Outranks(role string, other string) bool {
+	return roleRank[role] > roleRank[other]
+}
+
+// Can - tells if the role has the permission
+func Can(role string, perm string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidRole - tells if the role exists
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// AuditEntry - staff action on an account or post
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	ActorID    *string   `json:"actorId"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"targetType"`
+	TargetID   string    `json:"targetId"`
+	Detail     string    `json:"detail"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
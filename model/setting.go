@@ -0,0 +1,11 @@
+package model
+
+// settings
+const (
+	SettingMagicLink = "magic-link"
+)
+
+// Settings - settings administrators can change and their defaults
+var Settings = map[string]bool{
+	SettingMagicLink: false,
+}
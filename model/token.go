@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// TokenPrefix - every personal access token starts with it
+const TokenPrefix = "fpat_"
+
+// token scopes
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// AccessToken - personal access token without the secret
+type AccessToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// NewAccessToken - sent once when the token is created
+type NewAccessToken struct {
+	AccessToken
+	Token string `json:"token"`
+}
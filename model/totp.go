@@ -0,0 +1,26 @@
+package model
+
+// TOTP - totp secret of the account
+type TOTP struct {
+	Secret    string
+	Confirmed bool
+	LastStep  int64
+}
+
+// TOTPEnroll - sent when enrolling an authenticator app
+type TOTPEnroll struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodes - one time recovery codes, shown once
+type RecoveryCodes struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
+// MFAChallenge - sent when the password is right but a code is needed
+type MFAChallenge struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	Challenge string `json:"challenge"`
+}
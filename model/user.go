@@ -1,7 +1,5 @@
 package model
 
-import "time"
-
 // User - login user
 type User struct {
 	Name       string `json:"name"`
@@ -21,20 +19,6 @@ type UserSend struct {
 	Name       string `json:"name"`
 	Email      string `json:"email"`
 	UnqiueName string `json:"uniquename"`
-}
-
-// EmailToken - email token struct
-type EmailToken struct {
-	Confirmed  bool
-	Expires    string
-	Token      string
-	Account_id string
-}
-
-// EmailDbToken
-type EmailDbToken struct {
-	Confirmed  bool
-	Expires    time.Time
-	Token      string
-	Account_id string
+	Avatar     string `json:"avatar"`
+	AvatarKey  string `json:"-"`
 }
@@ -0,0 +1,9 @@
+package model
+
+// purposes of verification tokens, a token only works for its purpose
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+	PurposeChangeEmail   = "change-email"
+	PurposeMagicLink     = "magic-link"
+)
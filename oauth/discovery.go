@@ -0,0 +1,75 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// discoveryClient - discovery shouldn't hang the login
+var discoveryClient = &http.Client{Timeout: 10 * time.Second}
+
+// discovery - the part of the openid configuration that is used
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// discover - fills the endpoints from the issuer once, failures are retried on the next login
+func (p *Provider) discover() error {
+	if p.Issuer == "" {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered {
+		return nil
+	}
+
+	issuer := strings.TrimSuffix(p.Issuer, "/")
+	res, err := discoveryClient.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s discovery returned %d", p.Name, res.StatusCode)
+	}
+
+	var d discovery
+	if err := json.NewDecoder(res.Body).Decode(&d); err != nil {
+		return err
+	}
+
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return errors.New(p.Name + " discovery issuer doesn't match " + p.Issuer)
+	}
+
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserInfoEndpoint == "" {
+		return errors.New(p.Name + " discovery is missing endpoints")
+	}
+
+	// configured endpoints win over discovered ones
+	if p.Config.Endpoint.AuthURL == "" {
+		p.Config.Endpoint.AuthURL = d.AuthorizationEndpoint
+	}
+	if p.Config.Endpoint.TokenURL == "" {
+		p.Config.Endpoint.TokenURL = d.TokenEndpoint
+	}
+	if p.UserInfoURL == "" {
+		p.UserInfoURL = d.UserInfoEndpoint
+	}
+	p.jwksURL = d.JWKSURI
+
+	p.discovered = true
+	return nil
+}
@@ -0,0 +1,291 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clockSkew - how far the clocks of the provider and the api may drift
+const clockSkew = time.Minute
+
+// keysRefresh - unknown key ids refetch the provider keys at most this often
+const keysRefresh = time.Minute
+
+// errIDToken - every id token problem looks the same to the user
+var errIDToken = errors.New("invalid id token")
+
+// idClaims - claims of the id token that are checked
+type idClaims struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Audience audience `json:"aud"`
+	Expires  int64    `json:"exp"`
+	IssuedAt int64    `json:"iat"`
+	Nonce    string   `json:"nonce"`
+	AZP      string   `json:"azp"`
+}
+
+// audience - aud is a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// jwk - public key of the provider
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// nonce - nonce of the login, taken from the pkce verifier so no other cookie is needed
+func nonce(verifier string) string {
+	return challenge("nonce:" + verifier)
+}
+
+// openID - tells if the provider hands out id tokens
+func (p *Provider) openID() bool {
+	if p.Issuer == "" {
+		return false
+	}
+
+	for _, s := range p.Config.Scopes {
+		if s == "openid" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyIDToken - checks signature, issuer, audience and expiry of the id token
+// the nonce is only checked when it isn't empty
+func (p *Provider) verifyIDToken(ctx context.Context, raw string, nonce string) (idClaims, error) {
+	var c idClaims
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return c, errIDToken
+	}
+
+	enc := base64.RawURLEncoding
+
+	hb, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return c, errIDToken
+	}
+
+	var h struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return c, errIDToken
+	}
+
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return c, errIDToken
+	}
+
+	key, err := p.key(ctx, h.Kid)
+	if err != nil {
+		return c, err
+	}
+
+	if !verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), sig) {
+		return c, errIDToken
+	}
+
+	pb, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return c, errIDToken
+	}
+
+	if err := json.Unmarshal(pb, &c); err != nil {
+		return c, errIDToken
+	}
+
+	now := time.Now()
+
+	switch {
+	case c.Issuer != p.Issuer && c.Issuer != strings.TrimSuffix(p.Issuer, "/"):
+		return c, errIDToken
+	case !c.Audience.has(p.Config.ClientID):
+		return c, errIDToken
+	case len(c.Audience) > 1 && c.AZP != p.Config.ClientID:
+		return c, errIDToken
+	case now.Add(-clockSkew).Unix() >= c.Expires:
+		return c, errIDToken
+	case c.IssuedAt > now.Add(clockSkew).Unix():
+		return c, errIDToken
+	case c.Subject == "":
+		return c, errIDToken
+	case nonce != "" && c.Nonce != nonce:
+		return c, errIDToken
+	}
+
+	return c, nil
+}
+
+// has - tells if the client is one of the audiences
+func (a audience) has(client string) bool {
+	for _, s := range a {
+		if s == client {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifySignature - RS256 and ES256, the algorithms providers sign id tokens with
+func verifySignature(alg string, key crypto.PublicKey, input []byte, sig []byte) bool {
+	sum := sha256.Sum256(input)
+
+	switch alg {
+	case "RS256":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil
+	case "ES256":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k, sum[:], r, s)
+	}
+
+	return false
+}
+
+// key - public key of the provider with the id, keys are fetched again for unknown ids
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+
+	if time.Since(p.keysFetched) < keysRefresh {
+		return nil, errIDToken
+	}
+
+	keys, err := fetchKeys(ctx, p.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+
+	return nil, errIDToken
+}
+
+// fetchKeys - rsa and ec keys of the jwks url by key id
+func fetchKeys(ctx context.Context, url string) (map[string]crypto.PublicKey, error) {
+	if url == "" {
+		return nil, errors.New("discovery has no jwks_uri")
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := discoveryClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks returned %d", res.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		// keys that can't be read are skipped, the provider may have newer kinds
+		if pk, err := k.public(); err == nil {
+			keys[k.Kid] = pk
+		}
+	}
+
+	return keys, nil
+}
+
+// public - the key as a go public key
+func (k jwk) public() (crypto.PublicKey, error) {
+	enc := base64.RawURLEncoding
+
+	switch k.Kty {
+	case "RSA":
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := enc.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New("unsupported curve " + k.Crv)
+		}
+		x, err := enc.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := enc.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		pk := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pk.Curve.IsOnCurve(pk.X, pk.Y) {
+			return nil, errors.New("ec key is not on the curve")
+		}
+
+		return pk, nil
+	}
+
+	return nil, errors.New("unsupported key type " + k.Kty)
+}
@@ -0,0 +1,134 @@
+package oauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/dchest/uniuri"
+)
+
+// ringKey - aes-256-gcm key and its key id
+type ringKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring - encrypts provider tokens with the first key, decrypts with any of them
+type Keyring struct {
+	keys []ringKey
+}
+
+// NewTokenKey - random "kid:base64 key" for OAUTH_TOKEN_KEYS
+func NewTokenKey() string {
+	k := make([]byte, 32)
+	rand.Read(k)
+	return uniuri.NewLen(8) + ":" + base64.StdEncoding.EncodeToString(k)
+}
+
+// LoadKeyring - keys from OAUTH_TOKEN_KEYS "kid:base64 key,kid:base64 key"
+// without keys provider tokens aren't stored
+func LoadKeyring() (*Keyring, error) {
+	return NewKeyring(os.Getenv("OAUTH_TOKEN_KEYS"))
+}
+
+// NewKeyring - parses comma separated "kid:base64 key" entries
+func NewKeyring(s string) (*Keyring, error) {
+	k := &Keyring{}
+
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		i := strings.Index(p, ":")
+		if i < 1 {
+			return nil, errors.New("OAUTH_TOKEN_KEYS entries must look like kid:key")
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(p[i+1:])
+		if err != nil || len(raw) != 32 {
+			return nil, errors.New("OAUTH_TOKEN_KEYS key " + p[:i] + " is not a base64 32 byte key")
+		}
+
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		k.keys = append(k.keys, ringKey{p[:i], aead})
+	}
+
+	return k, nil
+}
+
+// Enabled - tells if there is a key to encrypt with
+func (k *Keyring) Enabled() bool {
+	return len(k.keys) > 0
+}
+
+// Encrypt - "kid:base64 nonce+ciphertext" with the first key
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	if !k.Enabled() {
+		return "", errors.New("OAUTH_TOKEN_KEYS is empty")
+	}
+
+	key := k.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	// the key id is authenticated so ciphertexts can't move between keys
+	sealed := key.aead.Seal(nonce, nonce, []byte(plain), []byte(key.id))
+
+	return key.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt - decrypts with the key named in the ciphertext
+func (k *Keyring) Decrypt(s string) (string, error) {
+	i := strings.Index(s, ":")
+	if i < 1 {
+		return "", errors.New("malformed encrypted token")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(s[i+1:])
+	if err != nil {
+		return "", errors.New("malformed encrypted token")
+	}
+
+	for _, key := range k.keys {
+		if key.id != s[:i] {
+			continue
+		}
+
+		n := key.aead.NonceSize()
+		if len(sealed) < n {
+			return "", errors.New("malformed encrypted token")
+		}
+
+		plain, err := key.aead.Open(nil, sealed[:n], sealed[n:], []byte(key.id))
+		if err != nil {
+			return "", errors.New("token doesn't decrypt with key " + key.id)
+		}
+
+		return string(plain), nil
+	}
+
+	return "", errors.New("no key " + s[:i] + " in OAUTH_TOKEN_KEYS")
+}
+
+// Stale - tells if the ciphertext isn't encrypted with the first key
+func (k *Keyring) Stale(s string) bool {
+	return k.Enabled() && s != "" && !strings.HasPrefix(s, k.keys[0].id+":")
+}
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testRingKey(id string, b byte) string {
+	k := make([]byte, 32)
+	for i := range k {
+		k[i] = b
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(k)
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		enabled bool
+		ok      bool
+	}{
+		{"empty", "", false, true},
+		{"one", testRingKey("a", 1), true, true},
+		{"two", testRingKey("a", 1) + " , " + testRingKey("b", 2), true, true},
+		{"generated", NewTokenKey(), true, true},
+		{"no kid", ":" + base64.StdEncoding.EncodeToString(make([]byte, 32)), false, false},
+		{"not base64", "a:%%%", false, false},
+		{"short key", "a:" + base64.StdEncoding.EncodeToString(make([]byte, 16)), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.keys)
+			if (err == nil) != tt.ok {
+				t.Fatalf("error = %v, want ok %v", err, tt.ok)
+			}
+			if err == nil && k.Enabled() != tt.enabled {
+				t.Errorf("enabled = %v, want %v", k.Enabled(), tt.enabled)
+			}
+		})
+	}
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k, _ := NewKeyring(testRingKey("a", 1))
+
+	c, err := k.Encrypt("secret token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c, "a:") || strings.Contains(c, "secret") {
+		t.Errorf("ciphertext %q", c)
+	}
+
+	p, err := k.Decrypt(c)
+	if err != nil || p != "secret token" {
+		t.Errorf("Decrypt = %q, %v", p, err)
+	}
+
+	// a fresh nonce every time
+	if c2, _ := k.Encrypt("secret token"); c2 == c {
+		t.Error("same ciphertext twice")
+	}
+
+	off, _ := NewKeyring("")
+	if _, err := off.Encrypt("x"); err == nil {
+		t.Error("encrypted without a key")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, _ := NewKeyring(testRingKey("old", 1))
+	c, _ := old.Encrypt("token")
+
+	// new key first, old one kept to decrypt
+	rotated, _ := NewKeyring(testRingKey("new", 2) + "," + testRingKey("old", 1))
+
+	if p, err := rotated.Decrypt(c); err != nil || p != "token" {
+		t.Errorf("old ciphertext = %q, %v", p, err)
+	}
+	if !rotated.Stale(c) {
+		t.Error("old ciphertext isn't stale")
+	}
+
+	fresh, _ := rotated.Encrypt("token")
+	if !strings.HasPrefix(fresh, "new:") || rotated.Stale(fresh) {
+		t.Errorf("fresh ciphertext %q", fresh)
+	}
+	if rotated.Stale("") {
+		t.Error("empty ciphertext is stale")
+	}
+
+	// dropping the old key makes its ciphertexts unreadable
+	dropped, _ := NewKeyring(testRingKey("new", 2))
+	if _, err := dropped.Decrypt(c); err == nil {
+		t.Error("ciphertext of a dropped key decrypted")
+	}
+}
+
+func TestKeyringTampering(t *testing.T) {
+	k, _ := NewKeyring(testRingKey("a", 1) + "," + testRingKey("b", 2))
+	c, _ := k.Encrypt("token")
+
+	raw, _ := base64.StdEncoding.DecodeString(c[2:])
+	raw[len(raw)-1] ^= 1
+	flipped := "a:" + base64.StdEncoding.EncodeToString(raw)
+
+	for name, bad := range map[string]string{
+		"flipped bit":  flipped,
+		"moved key id": "b:" + c[2:],
+		"unknown key":  "z:" + c[2:],
+		"no key id":    c[2:],
+		"not base64":   "a:%%%",
+		"too short":    "a:" + base64.StdEncoding.EncodeToString([]byte("short")),
+	} {
+		if _, err := k.Decrypt(bad); err == nil {
+			t.Errorf("%s decrypted", name)
+		}
+	}
+}
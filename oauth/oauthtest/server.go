@@ -0,0 +1,241 @@
+// Package oauthtest is a fake openid connect provider for tests of the login flow
+package oauthtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// client the server knows, and the code and access token it hands out
+const (
+	ClientID     = "client-1"
+	ClientSecret = "secret-1"
+	Code         = "code-1"
+	AccessToken  = "access-1"
+	RefreshToken = "refresh-1"
+
+	// RefreshedToken - access token handed out for RefreshToken, without a new refresh token
+	RefreshedToken = "access-2"
+	Subject        = "u-123"
+)
+
+// Server - openid connect provider with one user
+// the id token can be changed with Claims and signed with another key through Sign
+type Server struct {
+	*httptest.Server
+	Key   *rsa.PrivateKey
+	KeyID string
+
+	// Claims - changes the id token before it is signed
+	Claims func(c map[string]interface{})
+	// Sign - key id and key the id token is signed with instead of Key
+	Sign func() (string, *rsa.PrivateKey)
+
+	mu        sync.Mutex
+	refreshes int
+	challenge string
+	nonce     string
+	userinfo  map[string]interface{}
+	emails    []map[string]interface{}
+}
+
+// NewServer - starts the provider, it is closed when the test ends
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{Key: key, KeyID: "k1"}
+	s.userinfo = map[string]interface{}{
+		"sub":            Subject,
+		"email":          "ann@example.com",
+		"email_verified": true,
+		"name":           "Ann",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 s.URL,
+			"authorization_endpoint": s.URL + "/authorize",
+			"token_endpoint":         s.URL + "/token",
+			"userinfo_endpoint":      s.URL + "/userinfo",
+			"jwks_uri":               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{RSAKey(s.KeyID, &s.Key.PublicKey)}})
+	})
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+			w.WriteHeader(401)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		json.NewEncoder(w).Encode(s.userinfo)
+	})
+
+	mux.HandleFunc("/emails", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+			w.WriteHeader(401)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		json.NewEncoder(w).Encode(s.emails)
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Refreshes - how often a refresh token was used
+func (s *Server) Refreshes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshes
+}
+
+// SetUserInfo - claims the userinfo endpoint answers with
+func (s *Server) SetUserInfo(claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userinfo = claims
+}
+
+// SetEmails - github style email list the /emails endpoint answers with
+func (s *Server) SetEmails(emails []map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emails = emails
+}
+
+// Authorize - the user agreeing on the provider, returns the code sent to the callback
+func (s *Server) Authorize(t *testing.T, authURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	s.mu.Lock()
+	s.challenge = q.Get("code_challenge")
+	s.nonce = q.Get("nonce")
+	s.mu.Unlock()
+
+	return Code
+}
+
+// token - code exchange, the pkce verifier has to match the challenge of Authorize
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case id != ClientID || secret != ClientSecret:
+		w.WriteHeader(401)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	case r.PostForm.Get("grant_type") == "refresh_token":
+		if r.PostForm.Get("refresh_token") != RefreshToken {
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		s.refreshes++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": RefreshedToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+		return
+	case r.PostForm.Get("code") != Code || base64.RawURLEncoding.EncodeToString(sum[:]) != s.challenge:
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	c := map[string]interface{}{
+		"iss":   s.URL,
+		"sub":   Subject,
+		"aud":   ClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": s.nonce,
+	}
+	if s.Claims != nil {
+		s.Claims(c)
+	}
+
+	kid, key := s.KeyID, s.Key
+	if s.Sign != nil {
+		kid, key = s.Sign()
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  AccessToken,
+		"refresh_token": RefreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+		"id_token":      SignRS256(kid, key, c),
+	})
+}
+
+// RSAKey - public key as a jwk
+func RSAKey(kid string, k *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+	}
+}
+
+// SignRS256 - jwt of the claims
+func SignRS256(kid string, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	enc := base64.RawURLEncoding
+
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	p, _ := json.Marshal(claims)
+	input := enc.EncodeToString(h) + "." + enc.EncodeToString(p)
+
+	sum := sha256.Sum256([]byte(input))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+
+	return input + "." + enc.EncodeToString(sig)
+}
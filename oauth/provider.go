@@ -0,0 +1,243 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/dchest/uniuri"
+	"golang.org/x/oauth2"
+)
+
+// Claims - names of the userinfo fields, empty ones aren't read
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Name          string
+}
+
+// Provider - oauth2 or openid connect provider
+type Provider struct {
+	Name string
+
+	// Issuer - openid connect issuer, endpoints come from discovery
+	Issuer string
+
+	// UserInfoURL - userinfo endpoint when there is no discovery
+	UserInfoURL string
+
+	// VerifiedEmails - provider only hands out verified emails
+	VerifiedEmails bool
+
+	// EmailsURL - github style list of the user's emails, the primary one is used when it is verified
+	EmailsURL string
+
+	PKCE   bool
+	Claims Claims
+	Config oauth2.Config
+
+	// AuthParams - extra parameters of the auth url, like google's access_type=offline for refresh tokens
+	AuthParams url.Values
+
+	mu         sync.Mutex
+	discovered bool
+
+	// keys - id token keys from the discovered jwks_uri
+	jwksURL     string
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// Verifier - random pkce code verifier
+func Verifier() string {
+	return uniuri.NewLen(64)
+}
+
+// challenge - S256 challenge of the verifier
+func challenge(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL - url the user is sent to, verifier is only used with PKCE
+func (p *Provider) AuthCodeURL(state string, verifier string) (string, error) {
+	if err := p.discover(); err != nil {
+		return "", err
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if p.PKCE {
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", challenge(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+
+	if p.openID() {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce(verifier)))
+	}
+
+	for k := range p.AuthParams {
+		opts = append(opts, oauth2.SetAuthURLParam(k, p.AuthParams.Get(k)))
+	}
+
+	return p.Config.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange - swaps the code for a token, openid connect providers have to send a valid id token
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string) (*oauth2.Token, error) {
+	if err := p.discover(); err != nil {
+		return nil, err
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if p.PKCE {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", verifier))
+	}
+
+	t, err := p.Config.Exchange(ctx, code, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.openID() {
+		raw, _ := t.Extra("id_token").(string)
+		if _, err := p.verifyIDToken(ctx, raw, nonce(verifier)); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Identity - reads the user from the userinfo endpoint with the token
+func (p *Provider) Identity(ctx context.Context, t *oauth2.Token) (model.Identity, error) {
+	id := model.Identity{Provider: p.Name}
+
+	if err := p.discover(); err != nil {
+		return id, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := p.get(ctx, t, "userinfo", p.UserInfoURL, &claims); err != nil {
+		return id, err
+	}
+
+	id.Subject = claim(claims, p.Claims.Subject)
+	id.Email = claim(claims, p.Claims.Email)
+	id.Name = claim(claims, p.Claims.Name)
+	id.EmailVerified = p.VerifiedEmails
+	if p.Claims.EmailVerified != "" {
+		id.EmailVerified = claim(claims, p.Claims.EmailVerified) == "true"
+	}
+
+	if id.Subject == "" {
+		return id, errors.New(p.Name + " userinfo has no " + p.Claims.Subject)
+	}
+
+	// the userinfo email of github is the public one, which is never verified
+	if p.EmailsURL != "" {
+		if err := p.primaryEmail(ctx, t, &id); err != nil {
+			return id, err
+		}
+	}
+
+	// userinfo has to be about the user the id token was issued for
+	if raw, ok := t.Extra("id_token").(string); ok && p.openID() {
+		c, err := p.verifyIDToken(ctx, raw, "")
+		if err != nil {
+			return id, err
+		}
+
+		if c.Subject != id.Subject {
+			return id, errors.New(p.Name + " userinfo and id token are about different users")
+		}
+	}
+
+	return id, nil
+}
+
+// get - decodes the json the endpoint answers with for the token, what names it in errors
+func (p *Provider) get(ctx context.Context, t *oauth2.Token, what string, endpoint string, v interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.Config.Client(ctx, t).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned %d", p.Name, what, res.StatusCode)
+	}
+
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// primaryEmail - replaces the email of the identity with the primary one from EmailsURL
+// without a verified primary email the identity stays unverified
+func (p *Provider) primaryEmail(ctx context.Context, t *oauth2.Token, id *model.Identity) error {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, t, "emails", p.EmailsURL, &emails); err != nil {
+		return err
+	}
+
+	id.EmailVerified = false
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			id.Email = e.Email
+			id.EmailVerified = true
+		}
+	}
+
+	return nil
+}
+
+// claim - claim as a string, nested claims are separated by dots
+func claim(claims map[string]interface{}, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	var v interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = m[part]
+	}
+
+	switch c := v.(type) {
+	case string:
+		return c
+	case json.Number:
+		return c.String()
+	case bool:
+		return strconv.FormatBool(c)
+	default:
+		return ""
+	}
+}
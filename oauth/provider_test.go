@@ -0,0 +1,351 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/oauth/oauthtest"
+	"golang.org/x/oauth2"
+)
+
+// provider - openid connect provider of the fake server
+func provider(f *oauthtest.Server) *Provider {
+	return &Provider{
+		Name:   "fake",
+		Issuer: f.URL,
+		PKCE:   true,
+		Claims: defaultClaims,
+		Config: oauth2.Config{
+			ClientID:     oauthtest.ClientID,
+			ClientSecret: oauthtest.ClientSecret,
+			RedirectURL:  "http://api.test/account/fake/callback",
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+	}
+}
+
+// login - runs the whole flow against the fake provider
+func login(t *testing.T, f *oauthtest.Server, p *Provider) (*oauth2.Token, error) {
+	t.Helper()
+
+	verifier := Verifier()
+	u, err := p.AuthCodeURL("state-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p.Exchange(context.Background(), f.Authorize(t, u), verifier)
+}
+
+func TestDiscovery(t *testing.T) {
+	f := oauthtest.NewServer(t)
+	p := provider(f)
+
+	u, err := p.AuthCodeURL("state-1", Verifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(u, f.URL+"/authorize?") {
+		t.Errorf("auth url %s", u)
+	}
+	if p.Config.Endpoint.TokenURL != f.URL+"/token" || p.UserInfoURL != f.URL+"/userinfo" || p.jwksURL != f.URL+"/jwks" {
+		t.Errorf("endpoints %+v %s %s", p.Config.Endpoint, p.UserInfoURL, p.jwksURL)
+	}
+
+	// configured endpoints win over discovered ones
+	p = provider(f)
+	p.UserInfoURL = "http://userinfo.test/me"
+	if err := p.discover(); err != nil {
+		t.Fatal(err)
+	}
+	if p.UserInfoURL != "http://userinfo.test/me" {
+		t.Errorf("configured userinfo url replaced by %s", p.UserInfoURL)
+	}
+
+	// the issuer of the document has to be the configured one
+	p = provider(f)
+	p.Issuer = f.URL + "/other"
+	if err := p.discover(); err == nil {
+		t.Error("discovery of another issuer accepted")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	f := oauthtest.NewServer(t)
+	p := provider(f)
+
+	verifier := Verifier()
+	u, err := p.AuthCodeURL("state-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, _ := url.ParseQuery(u[strings.Index(u, "?")+1:])
+	sum := sha256.Sum256([]byte(verifier))
+
+	want := map[string]string{
+		"state":                 "state-1",
+		"client_id":             oauthtest.ClientID,
+		"response_type":         "code",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+		"nonce":                 nonce(verifier),
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+
+	if strings.Contains(u, verifier) {
+		t.Error("verifier leaked into the url")
+	}
+
+	p.PKCE = false
+	u, _ = p.AuthCodeURL("state-1", verifier)
+	if strings.Contains(u, "code_challenge") {
+		t.Error("challenge sent without PKCE")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	f := oauthtest.NewServer(t)
+	p := provider(f)
+
+	tok, err := login(t, f, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != oauthtest.AccessToken || tok.RefreshToken != oauthtest.RefreshToken {
+		t.Errorf("token %+v", tok)
+	}
+
+	id, err := p.Identity(context.Background(), tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "fake u-123 ann@example.com true Ann"
+	if got := strings.Join([]string{id.Provider, id.Subject, id.Email, boolString(id.EmailVerified), id.Name}, " "); got != want {
+		t.Errorf("identity %q, want %q", got, want)
+	}
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	f := oauthtest.NewServer(t)
+	p := provider(f)
+
+	u, _ := p.AuthCodeURL("state-1", Verifier())
+	if _, err := p.Exchange(context.Background(), f.Authorize(t, u), Verifier()); err == nil {
+		t.Error("code exchanged with another verifier")
+	}
+}
+
+func TestIDTokenValidation(t *testing.T) {
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims func(c map[string]interface{})
+		sign   func(f *oauthtest.Server) (string, *rsa.PrivateKey)
+	}{
+		{"issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.test" }, nil},
+		{"audience", func(c map[string]interface{}) { c["aud"] = "client-2" }, nil},
+		{"audience list without azp", func(c map[string]interface{}) { c["aud"] = []string{"client-1", "client-2"} }, nil},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * clockSkew).Unix() }, nil},
+		{"issued in the future", func(c map[string]interface{}) { c["iat"] = time.Now().Add(2 * clockSkew).Unix() }, nil},
+		{"nonce", func(c map[string]interface{}) { c["nonce"] = "replayed" }, nil},
+		{"no nonce", func(c map[string]interface{}) { delete(c, "nonce") }, nil},
+		{"no subject", func(c map[string]interface{}) { delete(c, "sub") }, nil},
+		{"other key", nil, func(f *oauthtest.Server) (string, *rsa.PrivateKey) { return f.KeyID, other }},
+		{"unknown key id", nil, func(f *oauthtest.Server) (string, *rsa.PrivateKey) { return "k2", other }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := oauthtest.NewServer(t)
+			f.Claims = tt.claims
+			if tt.sign != nil {
+				f.Sign = func() (string, *rsa.PrivateKey) { return tt.sign(f) }
+			}
+
+			if _, err := login(t, f, provider(f)); err == nil {
+				t.Error("id token accepted")
+			}
+		})
+	}
+
+	t.Run("audience list with azp", func(t *testing.T) {
+		f := oauthtest.NewServer(t)
+		f.Claims = func(c map[string]interface{}) {
+			c["aud"] = []string{"client-1", "client-2"}
+			c["azp"] = "client-1"
+		}
+
+		if _, err := login(t, f, provider(f)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		f := oauthtest.NewServer(t)
+		p := provider(f)
+
+		// a token response without id token
+		raw := &oauth2.Token{AccessToken: oauthtest.AccessToken}
+		if _, err := p.verifyIDToken(context.Background(), "", ""); err == nil {
+			t.Error("empty id token accepted")
+		}
+		if _, err := p.Identity(context.Background(), raw); err != nil {
+			t.Errorf("userinfo without id token: %v", err)
+		}
+	})
+}
+
+func TestIdentitySubjectMismatch(t *testing.T) {
+	f := oauthtest.NewServer(t)
+	p := provider(f)
+
+	tok, err := login(t, f, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.SetUserInfo(map[string]interface{}{"sub": "someone-else"})
+
+	if _, err := p.Identity(context.Background(), tok); err == nil {
+		t.Error("userinfo of another user accepted")
+	}
+}
+
+func TestIdentityClaims(t *testing.T) {
+	tests := []struct {
+		name     string
+		userinfo map[string]interface{}
+		claims   Claims
+		verified bool
+		email    string
+		want     bool
+	}{
+		{"unverified", map[string]interface{}{"sub": "u-123", "email": "a@b.c", "email_verified": false}, defaultClaims, false, "a@b.c", false},
+		{"verified as string", map[string]interface{}{"sub": "u-123", "email": "a@b.c", "email_verified": "true"}, defaultClaims, false, "a@b.c", true},
+		{"missing claim", map[string]interface{}{"sub": "u-123", "email": "a@b.c"}, defaultClaims, false, "a@b.c", false},
+		{"provider verifies", map[string]interface{}{"sub": "u-123", "email": "a@b.c"}, Claims{Subject: "sub", Email: "email"}, true, "a@b.c", true},
+		{"claim wins over provider", map[string]interface{}{"sub": "u-123", "email": "a@b.c", "email_verified": false}, defaultClaims, true, "a@b.c", false},
+		{"nested", map[string]interface{}{"sub": "u-123", "profile": map[string]interface{}{"mail": "n@b.c", "ok": true}}, Claims{Subject: "sub", Email: "profile.mail", EmailVerified: "profile.ok"}, false, "n@b.c", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := oauthtest.NewServer(t)
+			f.SetUserInfo(tt.userinfo)
+
+			p := provider(f)
+			p.Claims = tt.claims
+			p.VerifiedEmails = tt.verified
+
+			tok, err := login(t, f, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := p.Identity(context.Background(), tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if id.Email != tt.email || id.EmailVerified != tt.want {
+				t.Errorf("email %q verified %v, want %q %v", id.Email, id.EmailVerified, tt.email, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumericSubject(t *testing.T) {
+	f := oauthtest.NewServer(t)
+
+	// oauth2 only providers like github have numeric ids and no id token
+	p := &Provider{
+		Name:        "plain",
+		UserInfoURL: f.URL + "/userinfo",
+		Claims:      Claims{Subject: "id", Email: "email", Name: "name"},
+		Config:      oauth2.Config{ClientID: oauthtest.ClientID},
+	}
+
+	f.SetUserInfo(map[string]interface{}{"id": 4242, "email": "a@b.c"})
+
+	id, err := p.Identity(context.Background(), &oauth2.Token{AccessToken: oauthtest.AccessToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "4242" || id.EmailVerified {
+		t.Errorf("identity %+v", id)
+	}
+}
+
+func TestEmailsURL(t *testing.T) {
+	primary := map[string]interface{}{"email": "ann@example.com", "primary": true, "verified": true}
+	unverified := map[string]interface{}{"email": "ann@example.com", "primary": true, "verified": false}
+	other := map[string]interface{}{"email": "work@example.com", "primary": false, "verified": true}
+
+	tests := []struct {
+		name     string
+		emails   []map[string]interface{}
+		email    string
+		verified bool
+	}{
+		{"verified primary", []map[string]interface{}{other, primary}, "ann@example.com", true},
+		{"unverified primary", []map[string]interface{}{unverified, other}, "public@example.com", false},
+		{"no emails", nil, "public@example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := oauthtest.NewServer(t)
+			f.SetUserInfo(map[string]interface{}{"id": 4242, "email": "public@example.com"})
+			f.SetEmails(tt.emails)
+
+			// github with the fake provider's endpoints
+			p := preset("github")
+			p.Config.ClientID = oauthtest.ClientID
+			p.UserInfoURL = f.URL + "/userinfo"
+			p.EmailsURL = f.URL + "/emails"
+
+			id, err := p.Identity(context.Background(), &oauth2.Token{AccessToken: oauthtest.AccessToken})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id.Subject != "4242" || id.Email != tt.email || id.EmailVerified != tt.verified {
+				t.Errorf("identity %+v, want %q verified %v", id, tt.email, tt.verified)
+			}
+		})
+	}
+
+	// a list that can't be read fails the login instead of trusting the public email
+	f := oauthtest.NewServer(t)
+	f.SetUserInfo(map[string]interface{}{"id": 4242, "email": "public@example.com"})
+	p := preset("github")
+	p.UserInfoURL = f.URL + "/userinfo"
+	p.EmailsURL = f.URL + "/missing"
+	if _, err := p.Identity(context.Background(), &oauth2.Token{AccessToken: oauthtest.AccessToken}); err == nil {
+		t.Error("identity without the email list")
+	}
+}
@@ -0,0 +1,209 @@
+package oauth
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// validName - provider names end up in urls and env variable names
+var validName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// defaultClaims - standard openid connect claims
+var defaultClaims = Claims{
+	Subject:       "sub",
+	Email:         "email",
+	EmailVerified: "email_verified",
+	Name:          "name",
+}
+
+// presets - known providers only need a client id and secret
+var presets = map[string]*Provider{
+	"google": {
+		Issuer:     "https://accounts.google.com",
+		PKCE:       true,
+		Claims:     defaultClaims,
+		Config:     oauth2.Config{Scopes: []string{"openid", "email", "profile"}},
+		AuthParams: url.Values{"access_type": {"offline"}},
+	},
+	"gitlab": {
+		Issuer: "https://gitlab.com",
+		PKCE:   true,
+		Claims: defaultClaims,
+		Config: oauth2.Config{Scopes: []string{"openid", "email", "profile"}},
+	},
+	"github": {
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+		Claims:      Claims{Subject: "id", Email: "email", Name: "name"},
+		Config: oauth2.Config{
+			Scopes: []string{"read:user", "user:email"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  "https://github.com/login/oauth/authorize",
+				TokenURL: "https://github.com/login/oauth/access_token",
+			},
+		},
+	},
+}
+
+// Registry - configured providers by name
+type Registry struct {
+	providers map[string]*Provider
+	names     []string
+}
+
+// NewRegistry - registry of the providers
+func NewRegistry(ps ...*Provider) *Registry {
+	r := &Registry{providers: make(map[string]*Provider)}
+
+	for _, p := range ps {
+		r.providers[p.Name] = p
+		r.names = append(r.names, p.Name)
+	}
+
+	return r
+}
+
+// Provider - provider with the name
+func (r *Registry) Provider(name string) (*Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names - names of the configured providers
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+// LoadRegistry - providers from OAUTH_PROVIDERS and their OAUTH_<NAME>_* variables
+// without OAUTH_PROVIDERS google is configured from GOOGLE_CLIENT_ID
+func LoadRegistry() (*Registry, error) {
+	list := os.Getenv("OAUTH_PROVIDERS")
+
+	if list == "" {
+		if os.Getenv("GOOGLE_CLIENT_ID") == "" {
+			return NewRegistry(), nil
+		}
+
+		p := preset("google")
+		p.Config.ClientID = os.Getenv("GOOGLE_CLIENT_ID")
+		p.Config.ClientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
+
+		return NewRegistry(p), nil
+	}
+
+	ps := make([]*Provider, 0)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		p, err := loadProvider(name)
+		if err != nil {
+			return nil, err
+		}
+
+		ps = append(ps, p)
+	}
+
+	return NewRegistry(ps...), nil
+}
+
+// preset - known provider or an empty openid connect one
+func preset(name string) *Provider {
+	p := &Provider{Name: name, PKCE: true, Claims: defaultClaims}
+
+	if k, ok := presets[name]; ok {
+		p.Issuer = k.Issuer
+		p.UserInfoURL = k.UserInfoURL
+		p.EmailsURL = k.EmailsURL
+		p.VerifiedEmails = k.VerifiedEmails
+		p.PKCE = k.PKCE
+		p.Claims = k.Claims
+		p.Config.Scopes = append([]string(nil), k.Config.Scopes...)
+		p.Config.Endpoint = k.Config.Endpoint
+		p.AuthParams = url.Values{}
+		for n, v := range k.AuthParams {
+			p.AuthParams[n] = append([]string(nil), v...)
+		}
+	}
+
+	if p.Config.Scopes == nil {
+		p.Config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p.Config.RedirectURL = os.Getenv("URL") + "account/" + name + "/callback"
+
+	return p
+}
+
+// loadProvider - preset overridden by OAUTH_<NAME>_* variables
+func loadProvider(name string) (*Provider, error) {
+	if !validName.MatchString(name) {
+		return nil, errors.New("oauth provider name " + name + " may only have a-z, 0-9 and -")
+	}
+
+	p := preset(name)
+	prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	env := func(key string, dst *string) {
+		if v := os.Getenv(prefix + key); v != "" {
+			*dst = v
+		}
+	}
+
+	env("CLIENT_ID", &p.Config.ClientID)
+	env("CLIENT_SECRET", &p.Config.ClientSecret)
+	env("REDIRECT_URL", &p.Config.RedirectURL)
+	env("ISSUER", &p.Issuer)
+	env("AUTH_URL", &p.Config.Endpoint.AuthURL)
+	env("TOKEN_URL", &p.Config.Endpoint.TokenURL)
+	env("USERINFO_URL", &p.UserInfoURL)
+	env("EMAILS_URL", &p.EmailsURL)
+	env("CLAIM_SUBJECT", &p.Claims.Subject)
+	env("CLAIM_EMAIL", &p.Claims.Email)
+	env("CLAIM_EMAIL_VERIFIED", &p.Claims.EmailVerified)
+	env("CLAIM_NAME", &p.Claims.Name)
+
+	if v := os.Getenv(prefix + "SCOPES"); v != "" {
+		p.Config.Scopes = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+
+	if v := os.Getenv(prefix + "AUTH_PARAMS"); v != "" {
+		q, err := url.ParseQuery(v)
+		if err != nil {
+			return nil, errors.New(prefix + "AUTH_PARAMS must look like access_type=offline&prompt=consent")
+		}
+		if p.AuthParams == nil {
+			p.AuthParams = url.Values{}
+		}
+		for n := range q {
+			p.AuthParams.Set(n, q.Get(n))
+		}
+	}
+
+	switch os.Getenv(prefix + "PKCE") {
+	case "true":
+		p.PKCE = true
+	case "false":
+		p.PKCE = false
+	}
+
+	if os.Getenv(prefix+"VERIFIED_EMAILS") == "true" {
+		p.VerifiedEmails = true
+	}
+
+	if p.Config.ClientID == "" {
+		return nil, errors.New(prefix + "CLIENT_ID is empty")
+	}
+
+	if p.Issuer == "" && (p.Config.Endpoint.AuthURL == "" || p.Config.Endpoint.TokenURL == "" || p.UserInfoURL == "") {
+		return nil, errors.New(prefix + "ISSUER or AUTH_URL, TOKEN_URL and USERINFO_URL are needed")
+	}
+
+	return p, nil
+}
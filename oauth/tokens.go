@@ -0,0 +1,161 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"golang.org/x/oauth2"
+)
+
+// TokenStore - persists encrypted provider tokens
+type TokenStore interface {
+	GetToken(provider string, subject string) (model.ProviderToken, error)
+	SaveToken(t model.ProviderToken) error
+}
+
+// Tokens - encrypted provider tokens of identities
+type Tokens struct {
+	providers *Registry
+	keyring   *Keyring
+	store     TokenStore
+}
+
+// NewTokens - returns Tokens
+func NewTokens(p *Registry, k *Keyring, s TokenStore) *Tokens {
+	return &Tokens{p, k, s}
+}
+
+// seal - encrypts the token of the identity
+func (t *Tokens) seal(provider string, subject string, tok *oauth2.Token) (model.ProviderToken, error) {
+	pt := model.ProviderToken{Provider: provider, Subject: subject, TokenType: tok.TokenType}
+
+	var err error
+	if pt.AccessToken, err = t.keyring.Encrypt(tok.AccessToken); err != nil {
+		return pt, err
+	}
+
+	if tok.RefreshToken != "" {
+		if pt.RefreshToken, err = t.keyring.Encrypt(tok.RefreshToken); err != nil {
+			return pt, err
+		}
+	}
+
+	if !tok.Expiry.IsZero() {
+		e := tok.Expiry
+		pt.Expiry = &e
+	}
+
+	return pt, nil
+}
+
+// open - decrypts the token of the identity
+func (t *Tokens) open(pt model.ProviderToken) (*oauth2.Token, error) {
+	tok := &oauth2.Token{TokenType: pt.TokenType}
+
+	var err error
+	if tok.AccessToken, err = t.keyring.Decrypt(pt.AccessToken); err != nil {
+		return nil, err
+	}
+
+	if pt.RefreshToken != "" {
+		if tok.RefreshToken, err = t.keyring.Decrypt(pt.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if pt.Expiry != nil {
+		tok.Expiry = *pt.Expiry
+	}
+
+	return tok, nil
+}
+
+// Save - stores the token of the identity, skipped when there is no key
+func (t *Tokens) Save(provider string, subject string, tok *oauth2.Token) error {
+	if !t.keyring.Enabled() {
+		return nil
+	}
+
+	pt, err := t.seal(provider, subject, tok)
+	if err != nil {
+		return err
+	}
+
+	return t.store.SaveToken(pt)
+}
+
+// Rotate - re-encrypts the token with the first key, tells if it was needed
+func (t *Tokens) Rotate(pt model.ProviderToken) (bool, error) {
+	if !t.keyring.Stale(pt.AccessToken) && !t.keyring.Stale(pt.RefreshToken) {
+		return false, nil
+	}
+
+	tok, err := t.open(pt)
+	if err != nil {
+		return false, err
+	}
+
+	return true, t.Save(pt.Provider, pt.Subject, tok)
+}
+
+// TokenSource - token of the identity, refreshed when it expires and saved again
+func (t *Tokens) TokenSource(ctx context.Context, provider string, subject string) (oauth2.TokenSource, error) {
+	p, ok := t.providers.Provider(provider)
+	if !ok {
+		return nil, errors.New("oauth provider " + provider + " isn't configured")
+	}
+
+	pt, err := t.store.GetToken(provider, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := t.open(pt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.discover(); err != nil {
+		return nil, err
+	}
+
+	return &savingSource{
+		base:     p.Config.TokenSource(ctx, tok),
+		last:     tok.AccessToken,
+		provider: provider,
+		subject:  subject,
+		tokens:   t,
+	}, nil
+}
+
+// savingSource - saves the token whenever the provider hands out a new one
+type savingSource struct {
+	mu       sync.Mutex
+	base     oauth2.TokenSource
+	last     string
+	provider string
+	subject  string
+	tokens   *Tokens
+}
+
+// Token - valid token of the identity
+func (s *savingSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tok, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.AccessToken != s.last {
+		if err := s.tokens.Save(s.provider, s.subject, tok); err != nil {
+			return nil, err
+		}
+		s.last = tok.AccessToken
+	}
+
+	return tok, nil
+}
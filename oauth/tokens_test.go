@@ -0,0 +1,159 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/Hamaiz/go-rest-eg/oauth/oauthtest"
+	"golang.org/x/oauth2"
+)
+
+// tokenStore - TokenStore in memory that keeps the refresh token like SaveToken does
+type tokenStore struct {
+	tokens map[string]model.ProviderToken
+	saves  int
+}
+
+func (s *tokenStore) GetToken(provider string, subject string) (model.ProviderToken, error) {
+	return s.tokens[provider+"/"+subject], nil
+}
+
+func (s *tokenStore) SaveToken(t model.ProviderToken) error {
+	old := s.tokens[t.Provider+"/"+t.Subject]
+	if t.RefreshToken == "" {
+		t.RefreshToken = old.RefreshToken
+	}
+
+	s.tokens[t.Provider+"/"+t.Subject] = t
+	s.saves++
+	return nil
+}
+
+func TestTokensSaveEncrypts(t *testing.T) {
+	k, _ := NewKeyring(testRingKey("a", 1))
+	s := &tokenStore{tokens: map[string]model.ProviderToken{}}
+	ts := NewTokens(NewRegistry(), k, s)
+
+	exp := time.Now().Add(time.Hour).Round(time.Second)
+	if err := ts.Save("fake", "u1", &oauth2.Token{AccessToken: "at", RefreshToken: "rt", TokenType: "Bearer", Expiry: exp}); err != nil {
+		t.Fatal(err)
+	}
+
+	pt := s.tokens["fake/u1"]
+	if !strings.HasPrefix(pt.AccessToken, "a:") || !strings.HasPrefix(pt.RefreshToken, "a:") {
+		t.Errorf("stored token isn't encrypted: %+v", pt)
+	}
+
+	tok, err := ts.open(pt)
+	if err != nil || tok.AccessToken != "at" || tok.RefreshToken != "rt" || !tok.Expiry.Equal(exp) {
+		t.Errorf("open = %+v, %v", tok, err)
+	}
+
+	// without keys nothing is stored
+	off, _ := NewKeyring("")
+	s2 := &tokenStore{tokens: map[string]model.ProviderToken{}}
+	if err := NewTokens(NewRegistry(), off, s2).Save("fake", "u1", &oauth2.Token{AccessToken: "at"}); err != nil || s2.saves != 0 {
+		t.Errorf("saved without a key: %d, %v", s2.saves, err)
+	}
+}
+
+func TestTokensRotate(t *testing.T) {
+	old, _ := NewKeyring(testRingKey("old", 1))
+	s := &tokenStore{tokens: map[string]model.ProviderToken{}}
+	NewTokens(NewRegistry(), old, s).Save("fake", "u1", &oauth2.Token{AccessToken: "at", RefreshToken: "rt"})
+
+	rotated, _ := NewKeyring(testRingKey("new", 2) + "," + testRingKey("old", 1))
+	ts := NewTokens(NewRegistry(), rotated, s)
+
+	changed, err := ts.Rotate(s.tokens["fake/u1"])
+	if err != nil || !changed {
+		t.Fatalf("Rotate = %v, %v", changed, err)
+	}
+
+	pt := s.tokens["fake/u1"]
+	if rotated.Stale(pt.AccessToken) || rotated.Stale(pt.RefreshToken) {
+		t.Errorf("token still uses the old key: %+v", pt)
+	}
+
+	if changed, _ := ts.Rotate(pt); changed {
+		t.Error("current token rotated again")
+	}
+}
+
+func TestTokenSourceRefreshes(t *testing.T) {
+	f := oauthtest.NewServer(t)
+	p := &Provider{
+		Name:   "fake",
+		Issuer: f.URL,
+		Claims: defaultClaims,
+		Config: oauth2.Config{ClientID: oauthtest.ClientID, ClientSecret: oauthtest.ClientSecret, Scopes: []string{"openid"}},
+	}
+
+	k, _ := NewKeyring(testRingKey("a", 1))
+	s := &tokenStore{tokens: map[string]model.ProviderToken{}}
+	ts := NewTokens(NewRegistry(p), k, s)
+
+	// expired access token with the refresh token of the first consent
+	ts.Save("fake", "u1", &oauth2.Token{AccessToken: oauthtest.AccessToken, RefreshToken: oauthtest.RefreshToken, Expiry: time.Now().Add(-time.Minute)})
+
+	src, err := ts.TokenSource(context.Background(), "fake", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := src.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != oauthtest.RefreshedToken || f.Refreshes() != 1 {
+		t.Errorf("token %q after %d refreshes", tok.AccessToken, f.Refreshes())
+	}
+
+	// the refreshed token is saved and the refresh token survives
+	saved, err := ts.open(s.tokens["fake/u1"])
+	if err != nil || saved.AccessToken != oauthtest.RefreshedToken || saved.RefreshToken != oauthtest.RefreshToken {
+		t.Errorf("saved %+v, %v", saved, err)
+	}
+
+	// valid tokens aren't refreshed again
+	if _, err := src.Token(); err != nil || f.Refreshes() != 1 {
+		t.Errorf("%d refreshes, %v", f.Refreshes(), err)
+	}
+}
+
+func TestGoogleAsksForOfflineAccess(t *testing.T) {
+	p := preset("google")
+	p.Config.Endpoint.AuthURL = "https://accounts.google.com/o/oauth2/v2/auth"
+	p.Issuer = ""
+
+	u, err := p.AuthCodeURL("state", Verifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(u, "access_type=offline") {
+		t.Errorf("auth url %s has no offline access", u)
+	}
+
+	// presets are copied, not shared
+	p.AuthParams.Set("prompt", "consent")
+	if preset("google").AuthParams.Get("prompt") != "" {
+		t.Error("preset changed through a provider")
+	}
+}
+
+func TestAuthParamsFromEnv(t *testing.T) {
+	t.Setenv("OAUTH_ACME_CLIENT_ID", "id")
+	t.Setenv("OAUTH_ACME_ISSUER", "https://acme.test")
+	t.Setenv("OAUTH_ACME_AUTH_PARAMS", "prompt=consent&access_type=offline")
+
+	p, err := loadProvider("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AuthParams.Get("prompt") != "consent" || p.AuthParams.Get("access_type") != "offline" {
+		t.Errorf("auth params %v", p.AuthParams)
+	}
+}
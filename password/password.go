@@ -0,0 +1,175 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// algorithms new hashes can use, bcrypt hashes are always verified
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// Argon2Params - cost of argon2id hashes, memory is in KiB
+type Argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2 - 64 MiB, 3 passes and 4 lanes as RFC 9106 suggests
+var DefaultArgon2 = Argon2Params{Memory: 64 * 1024, Time: 3, Threads: 4, SaltLen: 16, KeyLen: 32}
+
+// Hasher - hashes passwords with the configured algorithm and verifies every supported one
+type Hasher struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// NewHasher - argon2id hasher with default parameters
+func NewHasher() *Hasher {
+	return &Hasher{Argon2id, DefaultArgon2, bcrypt.DefaultCost}
+}
+
+// Load - hasher from PASSWORD_HASH, ARGON2_MEMORY, ARGON2_TIME, ARGON2_THREADS and BCRYPT_COST
+func Load() (*Hasher, error) {
+	h := NewHasher()
+
+	if a := os.Getenv("PASSWORD_HASH"); a != "" {
+		h.Algorithm = a
+	}
+
+	if h.Algorithm != Argon2id && h.Algorithm != Bcrypt {
+		return nil, errors.New("PASSWORD_HASH must be argon2id or bcrypt")
+	}
+
+	for _, e := range []struct {
+		name string
+		dest interface{}
+		min  uint64
+		max  uint64
+	}{
+		{"ARGON2_MEMORY", &h.Argon2.Memory, 8 * 1024, 4 * 1024 * 1024},
+		{"ARGON2_TIME", &h.Argon2.Time, 1, 100},
+		{"ARGON2_THREADS", &h.Argon2.Threads, 1, 255},
+		{"BCRYPT_COST", &h.BcryptCost, uint64(bcrypt.MinCost), uint64(bcrypt.MaxCost)},
+	} {
+		s := os.Getenv(e.name)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || n < e.min || n > e.max {
+			return nil, fmt.Errorf("%s must be a number from %d to %d", e.name, e.min, e.max)
+		}
+
+		switch d := e.dest.(type) {
+		case *uint32:
+			*d = uint32(n)
+		case *uint8:
+			*d = uint8(n)
+		case *int:
+			*d = int(n)
+		}
+	}
+
+	return h, nil
+}
+
+// Hash - PHC encoded hash of the password
+// $argon2id$v=19$m=65536,t=3,p=4$salt$key or a bcrypt $2a$ hash
+func (h *Hasher) Hash(p string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		bs, err := bcrypt.GenerateFromPassword([]byte(p), h.BcryptCost)
+		return string(bs), err
+	}
+
+	a := h.Argon2
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(p), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify - tells if the password matches the hash, and if the hash should be
+// replaced because it uses another algorithm or outdated parameters
+// hashes in an unknown format never match
+func (h *Hasher) Verify(hash string, p string) (bool, bool) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		a, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, false
+		}
+
+		k := argon2.IDKey([]byte(p), salt, a.Time, a.Memory, a.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(k, key) != 1 {
+			return false, false
+		}
+
+		return true, h.Algorithm != Argon2id || a != h.Argon2
+	}
+
+	if strings.HasPrefix(hash, "$2") {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(p)) != nil {
+			return false, false
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		return true, h.Algorithm != Bcrypt || err != nil || cost != h.BcryptCost
+	}
+
+	return false, false
+}
+
+// decodeArgon2 - parameters, salt and key of a PHC encoded argon2id hash
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	a := Argon2Params{}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return a, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var v int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &v); err != nil || v != argon2.Version {
+		return a, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &a.Memory, &a.Time, &a.Threads); err != nil || a.Time == 0 || a.Threads == 0 {
+		return a, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return a, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return a, nil, nil, errors.New("invalid argon2id key")
+	}
+
+	a.SaltLen = uint32(len(salt))
+	a.KeyLen = uint32(len(key))
+
+	return a, salt, key, nil
+}
@@ -0,0 +1,161 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap - argon2id parameters small enough for tests
+var cheap = Argon2Params{Memory: 8 * 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func testHasher(alg string) *Hasher {
+	return &Hasher{alg, cheap, bcrypt.MinCost}
+}
+
+func TestArgon2RoundTrip(t *testing.T) {
+	h := testHasher(Argon2id)
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("hash %q", hash)
+	}
+
+	a, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != cheap || len(salt) != 16 || len(key) != 32 {
+		t.Errorf("decoded %+v with %d byte salt and %d byte key", a, len(salt), len(key))
+	}
+
+	if ok, rehash := h.Verify(hash, "correct horse"); !ok || rehash {
+		t.Errorf("Verify = %v, %v", ok, rehash)
+	}
+	if ok, _ := h.Verify(hash, "correct horse "); ok {
+		t.Error("wrong password matched")
+	}
+
+	// salts are random
+	if again, _ := h.Hash("correct horse"); again == hash {
+		t.Error("same hash twice")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon, _ := testHasher(Argon2id).Hash("pw")
+	bc, _ := testHasher(Bcrypt).Hash("pw")
+
+	stronger := testHasher(Argon2id)
+	stronger.Argon2.Time = 2
+
+	costlier := testHasher(Bcrypt)
+	costlier.BcryptCost = bcrypt.MinCost + 1
+
+	tests := []struct {
+		name   string
+		h      *Hasher
+		hash   string
+		rehash bool
+	}{
+		{"same argon2id", testHasher(Argon2id), argon, false},
+		{"argon2id parameters changed", stronger, argon, true},
+		{"argon2id to bcrypt", testHasher(Bcrypt), argon, true},
+		{"same bcrypt", testHasher(Bcrypt), bc, false},
+		{"bcrypt cost changed", costlier, bc, true},
+		{"bcrypt to argon2id", testHasher(Argon2id), bc, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash := tt.h.Verify(tt.hash, "pw")
+			if !ok || rehash != tt.rehash {
+				t.Errorf("Verify = %v, %v, want true, %v", ok, rehash, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestLegacyBcrypt(t *testing.T) {
+	h := testHasher(Argon2id)
+
+	// OpenBSD test vectors, hashes of accounts from before argon2id
+	vectors := []struct {
+		hash string
+		p    string
+	}{
+		{"$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U"},
+		{"$2a$05$CCCCCCCCCCCCCCCCCCCCC.VGOzA784oUp/Z0DY336zx7pLYAy0lwK", "U*U*"},
+	}
+
+	for _, v := range vectors {
+		if ok, rehash := h.Verify(v.hash, v.p); !ok || !rehash {
+			t.Errorf("Verify(%s) = %v, %v", v.hash, ok, rehash)
+		}
+		if ok, _ := h.Verify(v.hash, v.p+"x"); ok {
+			t.Errorf("wrong password matched %s", v.hash)
+		}
+	}
+}
+
+func TestUnknownHashes(t *testing.T) {
+	h := testHasher(Argon2id)
+	good, _ := h.Hash("pw")
+	parts := strings.Split(good, "$")
+
+	for name, hash := range map[string]string{
+		"empty":         "",
+		"plain text":    "pw",
+		"no password":   "!",
+		"argon2i":       strings.Replace(good, "$argon2id$", "$argon2i$", 1),
+		"other version": strings.Replace(good, "v=19", "v=16", 1),
+		"no parameters": strings.Join([]string{"", parts[1], parts[2], "", parts[4], parts[5]}, "$"),
+		"zero time":     strings.Replace(good, "t=1", "t=0", 1),
+		"bad salt":      strings.Join([]string{"", parts[1], parts[2], parts[3], "%%", parts[5]}, "$"),
+		"empty key":     strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], ""}, "$"),
+		"extra part":    good + "$x",
+		"broken bcrypt": "$2a$05$short",
+	} {
+		if ok, rehash := h.Verify(hash, "pw"); ok || rehash {
+			t.Errorf("%s: Verify = %v, %v", name, ok, rehash)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		ok   bool
+	}{
+		{"defaults", nil, true},
+		{"bcrypt", map[string]string{"PASSWORD_HASH": "bcrypt", "BCRYPT_COST": "12"}, true},
+		{"argon2 costs", map[string]string{"ARGON2_MEMORY": "19456", "ARGON2_TIME": "2", "ARGON2_THREADS": "1"}, true},
+		{"unknown algorithm", map[string]string{"PASSWORD_HASH": "md5"}, false},
+		{"memory too low", map[string]string{"ARGON2_MEMORY": "1024"}, false},
+		{"not a number", map[string]string{"ARGON2_TIME": "three"}, false},
+		{"cost too high", map[string]string{"BCRYPT_COST": "40"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"PASSWORD_HASH", "ARGON2_MEMORY", "ARGON2_TIME", "ARGON2_THREADS", "BCRYPT_COST"} {
+				t.Setenv(k, tt.env[k])
+			}
+
+			h, err := Load()
+			if (err == nil) != tt.ok {
+				t.Fatalf("error = %v, want ok %v", err, tt.ok)
+			}
+
+			if tt.name == "argon2 costs" && (h.Argon2.Memory != 19456 || h.Argon2.Time != 2 || h.Argon2.Threads != 1) {
+				t.Errorf("argon2 %+v", h.Argon2)
+			}
+		})
+	}
+}
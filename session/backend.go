@@ -0,0 +1,122 @@
+package session
+
+import (
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// ErrNoSession - the backend has no data for the session id
+var ErrNoSession = errors.New("session not found")
+
+// Backend - keeps session data on the server, the cookie only holds the id
+type Backend interface {
+	Load(id string) ([]byte, error)
+	Save(id string, data []byte, ttl time.Duration) error
+	Delete(id string) error
+}
+
+// ServerStore - gorilla sessions.Store on top of a Backend
+type ServerStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+	backend Backend
+}
+
+// NewServerStore - signs the session id cookie with keyPairs
+func NewServerStore(b Backend, maxAge int, keyPairs ...[]byte) *ServerStore {
+	return &ServerStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: maxAge,
+		},
+		backend: b,
+	}
+}
+
+// Get - session from the request registry
+func (s *ServerStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New - loads the session of the cookie or starts a new one
+func (s *ServerStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
+	if err != nil {
+		return session, err
+	}
+
+	data, err := s.backend.Load(session.ID)
+	if err == ErrNoSession {
+		// expired on the server, a new id is given on save
+		session.ID = ""
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+
+	err = securecookie.GobEncoder{}.Deserialize(data, &session.Values)
+	if err != nil {
+		return session, err
+	}
+
+	session.IsNew = false
+	return session, nil
+}
+
+// Save - saves the values to the backend and the id to the cookie
+func (s *ServerStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.backend.Delete(session.ID); err != nil {
+				return err
+			}
+		}
+
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+
+	data, err := securecookie.GobEncoder{}.Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+
+	// browser sessions still expire on the server
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if err := s.backend.Save(session.ID, data, ttl); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
@@ -0,0 +1,28 @@
+package session_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Hamaiz/go-rest-eg/session"
+	"github.com/Hamaiz/go-rest-eg/session/sessiontest"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	sessiontest.Backend(t, session.NewMemoryBackend())
+}
+
+// redis-server is needed, e.g. REDIS_URL=redis://localhost:6379/15
+func TestRedisBackend(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL is not set")
+	}
+
+	b, err := session.NewRedisBackend(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessiontest.Backend(t, b)
+}
@@ -0,0 +1,447 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+	"github.com/dchest/uniuri"
+)
+
+// cookie names used by the jwt store
+const (
+	accessCookie  = "access_token"
+	refreshCookie = "refresh_token"
+)
+
+// RefreshStore - persists sessions and their rotating refresh tokens
+type RefreshStore interface {
+	CreateSession(user string, ip string, ua string) (string, error)
+	ActiveSession(sid string) (string, error)
+	RevokeSession(sid string) error
+	InsertRefresh(sid string, hash string, expires time.Time) error
+	RotateRefresh(hash string, next string, expires time.Time) (string, string, error)
+	RevokeRefresh(hash string) error
+}
+
+// signingKey - ed25519 key and its key id
+type signingKey struct {
+	id  string
+	key ed25519.PrivateKey
+}
+
+// claims - claims of the access token
+type claims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	Session  string `json:"sid"`
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+	ID       string `json:"jti"`
+}
+
+// activeCheck - how long a session is known to be active without asking postgres
+// access tokens of a revoked session or banned user keep working for up to activeCheck
+const activeCheck = 30 * time.Second
+
+// JWTStore - short lived signed access tokens and rotating refresh tokens
+type JWTStore struct {
+	keys       []signingKey
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	sessions   RefreshStore
+
+	// sid - when the session was last seen active
+	mu     sync.Mutex
+	active map[string]time.Time
+}
+
+// NewJWTStore - configures jwt store from env variables
+// JWT_KEYS - "kid:base64 seed,kid:base64 seed", the first key signs and the rest only verify
+func NewJWTStore(s RefreshStore) (*JWTStore, error) {
+	keys, err := parseKeys(os.Getenv("JWT_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+
+	accessTTL, err := envDuration("JWT_ACCESS_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTTL, err := envDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = os.Getenv("URL")
+	}
+
+	return &JWTStore{
+		keys:       keys,
+		issuer:     issuer,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		sessions:   s,
+		active:     make(map[string]time.Time),
+	}, nil
+}
+
+// NewJWTKey - random "kid:base64 seed" for JWT_KEYS
+func NewJWTKey() string {
+	_, k, _ := ed25519.GenerateKey(nil)
+	return uniuri.NewLen(8) + ":" + base64.StdEncoding.EncodeToString(k.Seed())
+}
+
+// parseKeys - parses JWT_KEYS
+func parseKeys(s string) ([]signingKey, error) {
+	keys := make([]signingKey, 0)
+
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		i := strings.Index(p, ":")
+		if i < 1 {
+			return nil, errors.New("JWT_KEYS entries must look like kid:seed")
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(p[i+1:])
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("JWT_KEYS key " + p[:i] + " is not a base64 ed25519 seed")
+		}
+
+		keys = append(keys, signingKey{p[:i], ed25519.NewKeyFromSeed(seed)})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("JWT_KEYS is empty")
+	}
+
+	return keys, nil
+}
+
+// envDuration - duration from env variable or default
+func envDuration(name string, d time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return d, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.New(name + " is not a valid duration")
+	}
+
+	return d, nil
+}
+
+// sign - signs claims with the first key
+func (s *JWTStore) sign(c claims) (string, error) {
+	k := s.keys[0]
+
+	h, err := json.Marshal(map[string]string{"alg": "EdDSA", "kid": k.id, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	input := enc.EncodeToString(h) + "." + enc.EncodeToString(p)
+	sig := ed25519.Sign(k.key, []byte(input))
+
+	return input + "." + enc.EncodeToString(sig), nil
+}
+
+// verify - checks signature, issuer and expiry and returns the claims
+func (s *JWTStore) verify(t string) (claims, error) {
+	var c claims
+	invalid := errors.New("invalid access token")
+
+	parts := strings.Split(t, ".")
+	if len(parts) != 3 {
+		return c, invalid
+	}
+
+	enc := base64.RawURLEncoding
+
+	hb, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return c, invalid
+	}
+
+	var h struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(hb, &h); err != nil || h.Alg != "EdDSA" {
+		return c, invalid
+	}
+
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return c, invalid
+	}
+
+	ok := false
+	for _, k := range s.keys {
+		if k.id == h.Kid {
+			ok = ed25519.Verify(k.key.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), sig)
+			break
+		}
+	}
+
+	if !ok {
+		return c, invalid
+	}
+
+	pb, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return c, invalid
+	}
+
+	if err := json.Unmarshal(pb, &c); err != nil {
+		return c, invalid
+	}
+
+	if c.Issuer != s.issuer || time.Now().Unix() >= c.Expires {
+		return c, invalid
+	}
+
+	return c, nil
+}
+
+// issue - writes new access token and refresh token of the session
+func (s *JWTStore) issue(w http.ResponseWriter, user string, sid string, refresh string) (model.TokenPair, error) {
+	now := time.Now()
+
+	at, err := s.sign(claims{
+		Issuer:   s.issuer,
+		Subject:  user,
+		Session:  sid,
+		IssuedAt: now.Unix(),
+		Expires:  now.Add(s.accessTTL).Unix(),
+		ID:       uniuri.NewLen(16),
+	})
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessCookie,
+		Value:    at,
+		Path:     "/",
+		MaxAge:   int(s.accessTTL.Seconds()),
+		HttpOnly: true,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refresh,
+		Path:     "/account/token",
+		MaxAge:   int(s.refreshTTL.Seconds()),
+		HttpOnly: true,
+	})
+
+	return model.TokenPair{
+		AccessToken:  at,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}, nil
+}
+
+// clear - removes both token cookies
+func (s *JWTStore) clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: accessCookie, Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: refreshCookie, Path: "/account/token", MaxAge: -1})
+}
+
+// claims - claims of the access token from the header or cookie
+func (s *JWTStore) claims(r *http.Request) (claims, error) {
+	t := helper.BearerToken(r)
+	if t == "" {
+		c, err := r.Cookie(accessCookie)
+		if err != nil {
+			return claims{}, errors.New("no access token found")
+		}
+		t = c.Value
+	}
+
+	c, err := s.verify(t)
+	if err != nil {
+		return c, err
+	}
+
+	if !s.isActive(c.Session) {
+		return c, errors.New("session expired")
+	}
+
+	return c, nil
+}
+
+// isActive - checks that the session wasn't revoked, cached for activeCheck
+func (s *JWTStore) isActive(sid string) bool {
+	now := time.Now()
+
+	s.mu.Lock()
+	seen, ok := s.active[sid]
+	s.mu.Unlock()
+
+	if ok && now.Sub(seen) < activeCheck {
+		return true
+	}
+
+	if _, err := s.sessions.ActiveSession(sid); err != nil {
+		s.mu.Lock()
+		delete(s.active, sid)
+		s.mu.Unlock()
+		return false
+	}
+
+	s.mu.Lock()
+	// forget sessions that weren't used for a while
+	for k, t := range s.active {
+		if now.Sub(t) > s.accessTTL {
+			delete(s.active, k)
+		}
+	}
+	s.active[sid] = now
+	s.mu.Unlock()
+
+	return true
+}
+
+// refreshToken - refresh token from the form or cookie
+func refreshToken(r *http.Request) string {
+	if t := r.FormValue("refreshToken"); t != "" {
+		return t
+	}
+
+	c, err := r.Cookie(refreshCookie)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
+
+// GetUser - user of the access token
+func (s *JWTStore) GetUser(r *http.Request) (string, error) {
+	c, err := s.claims(r)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Subject, nil
+}
+
+// SessionID - session of the access token
+func (s *JWTStore) SessionID(r *http.Request) (string, error) {
+	c, err := s.claims(r)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Session, nil
+}
+
+// AlreadyLoggedIn - tells if the access token is valid
+func (s *JWTStore) AlreadyLoggedIn(r *http.Request) bool {
+	_, err := s.claims(r)
+	return err == nil
+}
+
+// CleanSession - revokes the session and removes the cookies
+func (s *JWTStore) CleanSession(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.claims(r)
+	if err != nil {
+		return err
+	}
+
+	s.clear(w)
+
+	return s.sessions.RevokeSession(c.Session)
+}
+
+// SaveSession - starts new session with a fresh token pair
+func (s *JWTStore) SaveSession(w http.ResponseWriter, r *http.Request, id string) error {
+	sid, err := s.sessions.CreateSession(id, helper.ClientIP(r), r.UserAgent())
+	if err != nil {
+		return err
+	}
+
+	refresh := uniuri.NewLen(64)
+	err = s.sessions.InsertRefresh(sid, helper.HashToken(refresh), time.Now().Add(s.refreshTTL))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.issue(w, id, sid, refresh)
+
+	return err
+}
+
+// Refresh - swaps the refresh token for a new token pair
+func (s *JWTStore) Refresh(w http.ResponseWriter, r *http.Request) (model.TokenPair, error) {
+	t := refreshToken(r)
+	if t == "" {
+		return model.TokenPair{}, errors.New("no refresh token found")
+	}
+
+	next := uniuri.NewLen(64)
+	user, sid, err := s.sessions.RotateRefresh(helper.HashToken(t), helper.HashToken(next), time.Now().Add(s.refreshTTL))
+	if err != nil {
+		s.clear(w)
+		return model.TokenPair{}, err
+	}
+
+	return s.issue(w, user, sid, next)
+}
+
+// Revoke - revokes the session of the refresh token
+func (s *JWTStore) Revoke(w http.ResponseWriter, r *http.Request) error {
+	t := refreshToken(r)
+	if t == "" {
+		return errors.New("no refresh token found")
+	}
+
+	s.clear(w)
+
+	return s.sessions.RevokeRefresh(helper.HashToken(t))
+}
+
+// JWKS - public keys that verify access tokens
+func (s *JWTStore) JWKS() model.JWKS {
+	set := model.JWKS{Keys: make([]model.JWK, 0, len(s.keys))}
+
+	for _, k := range s.keys {
+		set.Keys = append(set.Keys, model.JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.key.Public().(ed25519.PublicKey)),
+			Kid: k.id,
+			Use: "sig",
+			Alg: "EdDSA",
+		})
+	}
+
+	return set
+}
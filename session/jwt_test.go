@@ -0,0 +1,292 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// refreshStore - RefreshStore in memory that detects reuse like the postgres one
+type refreshStore struct {
+	sessions map[string]string
+	revoked  map[string]bool
+	tokens   map[string]string
+	used     map[string]bool
+}
+
+func newRefreshStore() *refreshStore {
+	return &refreshStore{
+		sessions: map[string]string{},
+		revoked:  map[string]bool{},
+		tokens:   map[string]string{},
+		used:     map[string]bool{},
+	}
+}
+
+var errRefresh = errors.New("invalid refresh token")
+
+func (s *refreshStore) CreateSession(user string, ip string, ua string) (string, error) {
+	sid := "sid" + strconv.Itoa(len(s.sessions))
+	s.sessions[sid] = user
+	return sid, nil
+}
+
+func (s *refreshStore) ActiveSession(sid string) (string, error) {
+	u, ok := s.sessions[sid]
+	if !ok || s.revoked[sid] {
+		return "", errors.New("session expired")
+	}
+	return u, nil
+}
+
+func (s *refreshStore) RevokeSession(sid string) error {
+	s.revoked[sid] = true
+	return nil
+}
+
+func (s *refreshStore) InsertRefresh(sid string, hash string, expires time.Time) error {
+	s.tokens[hash] = sid
+	return nil
+}
+
+func (s *refreshStore) RotateRefresh(hash string, next string, expires time.Time) (string, string, error) {
+	sid, ok := s.tokens[hash]
+	if !ok || s.revoked[sid] {
+		return "", "", errRefresh
+	}
+
+	if s.used[hash] {
+		s.revoked[sid] = true
+		return "", "", errRefresh
+	}
+
+	s.used[hash] = true
+	s.tokens[next] = sid
+
+	return s.sessions[sid], sid, nil
+}
+
+func (s *refreshStore) RevokeRefresh(hash string) error {
+	if sid, ok := s.tokens[hash]; ok {
+		s.revoked[sid] = true
+	}
+	return nil
+}
+
+func testKey(id string, b byte) string {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = b
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(seed)
+}
+
+func newTestJWTStore(t *testing.T, keys string) (*JWTStore, *refreshStore) {
+	t.Helper()
+	t.Setenv("JWT_KEYS", keys)
+	t.Setenv("JWT_ISSUER", "https://api.test/")
+
+	rs := newRefreshStore()
+	s, err := NewJWTStore(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, rs
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		ok   bool
+	}{
+		{"one key", testKey("a", 1), true},
+		{"two keys", testKey("a", 1) + ", " + testKey("b", 2), true},
+		{"empty", "", false},
+		{"no kid", ":" + strings.SplitN(testKey("a", 1), ":", 2)[1], false},
+		{"not base64", "a:%%%", false},
+		{"short seed", "a:" + base64.StdEncoding.EncodeToString([]byte("short")), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseKeys(tt.keys)
+			if (err == nil) != tt.ok {
+				t.Errorf("parseKeys(%q) error = %v, want ok %v", tt.keys, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	s, _ := newTestJWTStore(t, testKey("a", 1))
+
+	now := time.Now()
+	valid := claims{Issuer: "https://api.test/", Subject: "u1", Session: "s1", IssuedAt: now.Unix(), Expires: now.Add(time.Minute).Unix(), ID: "j1"}
+
+	tok, err := s.sign(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := s.verify(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != valid {
+		t.Errorf("claims = %+v, want %+v", c, valid)
+	}
+
+	expired := valid
+	expired.Expires = now.Add(-time.Second).Unix()
+	expiredTok, _ := s.sign(expired)
+
+	issuer := valid
+	issuer.Issuer = "https://other.test/"
+	issuerTok, _ := s.sign(issuer)
+
+	parts := strings.Split(tok, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://api.test/","sub":"admin","exp":9999999999}`)) + "." + parts[2]
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"a"}`)) + "." + parts[1] + "."
+
+	other, _ := newTestJWTStore(t, testKey("a", 2))
+	otherTok, _ := other.sign(valid)
+
+	for name, bad := range map[string]string{
+		"expired":     expiredTok,
+		"issuer":      issuerTok,
+		"forged":      forged,
+		"alg none":    none,
+		"other key":   otherTok,
+		"garbage":     "not.a.token",
+		"two parts":   parts[0] + "." + parts[1],
+		"empty":       "",
+		"bad base64":  parts[0] + "." + parts[1] + ".%%%",
+		"bad payload": parts[0] + ".%%%." + parts[2],
+	} {
+		if _, err := s.verify(bad); err == nil {
+			t.Errorf("%s token verified", name)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, _ := newTestJWTStore(t, testKey("old", 1))
+	tok, err := old.sign(claims{Issuer: "https://api.test/", Subject: "u1", Expires: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// new key signs, old one still verifies what it signed
+	rotated, _ := newTestJWTStore(t, testKey("new", 2)+","+testKey("old", 1))
+	if _, err := rotated.verify(tok); err != nil {
+		t.Errorf("token of the old key: %v", err)
+	}
+
+	fresh, _ := rotated.sign(claims{Issuer: "https://api.test/", Subject: "u1", Expires: time.Now().Add(time.Minute).Unix()})
+	if !strings.HasPrefix(fresh, base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA","kid":"new"`))) {
+		t.Errorf("token not signed with the first key: %s", fresh)
+	}
+
+	// once the old key is dropped its tokens stop working
+	dropped, _ := newTestJWTStore(t, testKey("new", 2))
+	if _, err := dropped.verify(tok); err == nil {
+		t.Error("token of a dropped key verified")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	s, _ := newTestJWTStore(t, testKey("a", 1)+","+testKey("b", 2))
+
+	tok, _ := s.sign(claims{Issuer: "https://api.test/", Subject: "u1", Expires: time.Now().Add(time.Minute).Unix()})
+	parts := strings.Split(tok, ".")
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+
+	set := s.JWKS()
+	if len(set.Keys) != 2 || set.Keys[0].Kid != "a" || set.Keys[1].Kid != "b" {
+		t.Fatalf("jwks = %+v", set)
+	}
+
+	k := set.Keys[0]
+	if k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != "EdDSA" || k.Use != "sig" {
+		t.Errorf("jwk = %+v", k)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		t.Fatalf("jwk x = %q", k.X)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(x), []byte(parts[0]+"."+parts[1]), sig) {
+		t.Error("published key does not verify the token")
+	}
+}
+
+// cookies - cookies the response set, by name
+func cookies(w *httptest.ResponseRecorder) map[string]string {
+	m := map[string]string{}
+	for _, c := range w.Result().Cookies() {
+		m[c.Name] = c.Value
+	}
+	return m
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s, rs := newTestJWTStore(t, testKey("a", 1))
+
+	w := httptest.NewRecorder()
+	if err := s.SaveSession(w, httptest.NewRequest("POST", "/account/login", nil), "u1"); err != nil {
+		t.Fatal(err)
+	}
+	first := cookies(w)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+first[accessCookie])
+	if u, err := s.GetUser(r); err != nil || u != "u1" {
+		t.Fatalf("GetUser = %q, %v", u, err)
+	}
+
+	refresh := func(token string) (string, error) {
+		r := httptest.NewRequest("POST", "/account/token", strings.NewReader("refreshToken="+token))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		p, err := s.Refresh(httptest.NewRecorder(), r)
+		return p.RefreshToken, err
+	}
+
+	second, err := refresh(first[refreshCookie])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first[refreshCookie] {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	third, err := refresh(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replaying a used token revokes the whole session
+	if _, err := refresh(first[refreshCookie]); err == nil {
+		t.Fatal("used refresh token was accepted")
+	}
+	if _, err := refresh(third); err == nil {
+		t.Error("refresh token of a revoked session was accepted")
+	}
+
+	// the access token stops working once the cached check runs out
+	s.active = map[string]time.Time{}
+	if _, err := s.GetUser(r); err == nil {
+		t.Error("access token of a revoked session was accepted")
+	}
+
+	if len(rs.sessions) != 1 {
+		t.Errorf("%d sessions, want 1", len(rs.sessions))
+	}
+}
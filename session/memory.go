@@ -0,0 +1,62 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryEntry - session data and when it expires
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryBackend - sessions in process memory, lost on restart, for local development
+type MemoryBackend struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+// NewMemoryBackend - returns MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{sessions: make(map[string]memoryEntry)}
+}
+
+// Load - data of the session if it didn't expire
+func (m *MemoryBackend) Load(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.sessions[id]
+	if !ok || time.Now().After(e.expires) {
+		delete(m.sessions, id)
+		return nil, ErrNoSession
+	}
+
+	return e.data, nil
+}
+
+// Save - saves data of the session and drops expired ones
+func (m *MemoryBackend) Save(id string, data []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range m.sessions {
+		if now.After(e.expires) {
+			delete(m.sessions, k)
+		}
+	}
+
+	m.sessions[id] = memoryEntry{data, now.Add(ttl)}
+	return nil
+}
+
+// Delete - removes the session
+func (m *MemoryBackend) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
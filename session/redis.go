@@ -0,0 +1,72 @@
+package session
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisPrefix - namespace of the session keys
+const redisPrefix = "session:"
+
+// RedisBackend - sessions in redis, expired by redis itself
+type RedisBackend struct {
+	pool *redis.Pool
+}
+
+// NewRedisBackend - connects to redis url like redis://localhost:6379/0
+func NewRedisBackend(url string) (*RedisBackend, error) {
+	pool := &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 5 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url)
+		},
+	}
+
+	// fail on start instead of on the first request
+	c := pool.Get()
+	defer c.Close()
+
+	if _, err := c.Do("PING"); err != nil {
+		return nil, err
+	}
+
+	return &RedisBackend{pool}, nil
+}
+
+// Load - data of the session
+func (b *RedisBackend) Load(id string) ([]byte, error) {
+	c := b.pool.Get()
+	defer c.Close()
+
+	data, err := redis.Bytes(c.Do("GET", redisPrefix+id))
+	if err == redis.ErrNil {
+		return nil, ErrNoSession
+	}
+
+	return data, err
+}
+
+// Save - saves data of the session for ttl
+func (b *RedisBackend) Save(id string, data []byte, ttl time.Duration) error {
+	c := b.pool.Get()
+	defer c.Close()
+
+	secs := int(ttl.Seconds())
+	if secs < 1 {
+		secs = 1
+	}
+
+	_, err := c.Do("SETEX", redisPrefix+id, secs, data)
+	return err
+}
+
+// Delete - removes the session
+func (b *RedisBackend) Delete(id string) error {
+	c := b.pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("DEL", redisPrefix+id)
+	return err
+}
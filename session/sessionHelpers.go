@@ -4,37 +4,60 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/Hamaiz/go-rest-eg/helper"
 	"github.com/gorilla/sessions"
 )
 
-// GetUser - gets session_id and returns the user
-func (s AccountStore) GetUser(r *http.Request) (string, error) {
+// sessionValues - user and server side session id of the cookie
+func (s AccountStore) sessionValues(r *http.Request) (string, string, error) {
 	session, _ := s.store.Get(r, "presence")
 
-	if session.Values["session_id"] == nil {
+	id, _ := session.Values["session_id"].(string)
+	sid, _ := session.Values["sid"].(string)
+
+	if id == "" || sid == "" {
 		err := errors.New("no session id found")
-		return "", err
-	} else {
-		id := session.Values["session_id"].(string)
-		return id, nil
+		return "", "", err
 	}
+
+	// revoked sessions are logged out everywhere
+	user, err := s.sessions.ActiveSession(sid)
+	if err != nil || user != id {
+		err = errors.New("session expired")
+		return "", "", err
+	}
+
+	return id, sid, nil
+}
+
+// GetUser - gets session_id and returns the user
+func (s AccountStore) GetUser(r *http.Request) (string, error) {
+	id, _, err := s.sessionValues(r)
+	return id, err
+}
+
+// SessionID - server side id of the current session
+func (s AccountStore) SessionID(r *http.Request) (string, error) {
+	_, sid, err := s.sessionValues(r)
+	return sid, err
 }
 
 // AlreadyLoggedIn - tells if the user is logged in or not
 func (s AccountStore) AlreadyLoggedIn(r *http.Request) bool {
-	session, _ := s.store.Get(r, "presence")
-
-	if session.Values["session_id"] == nil {
-		return false
-	} else {
-		return true
-	}
+	_, _, err := s.sessionValues(r)
+	return err == nil
 }
 
 // CleanSession - cleans session
 func (s AccountStore) CleanSession(w http.ResponseWriter, r *http.Request) error {
 	session, _ := s.store.Get(r, "presence")
 
+	if sid, ok := session.Values["sid"].(string); ok {
+		if err := s.sessions.RevokeSession(sid); err != nil {
+			return err
+		}
+	}
+
 	session.Options.MaxAge = -1
 
 	err := session.Save(r, w)
@@ -47,11 +70,23 @@ func (s AccountStore) CleanSession(w http.ResponseWriter, r *http.Request) error
 
 // SaveSession - saves session
 func (s AccountStore) SaveSession(w http.ResponseWriter, r *http.Request, id string) error {
+	// server side record of the session
+	sid, err := s.sessions.CreateSession(id, helper.ClientIP(r), r.UserAgent())
+	if err != nil {
+		return err
+	}
+
 	// Create New Session
 	session, _ := s.store.Get(r, "presence")
 
+	// the id of a cookie sent before logging in is never reused,
+	// a planted session id would be logged in too
+	session.ID = ""
+	session.Values = make(map[interface{}]interface{})
+
 	// adding session to value
 	session.Values["session_id"] = id
+	session.Values["sid"] = sid
 
 	// adding session options
 	session.Options = &sessions.Options{
@@ -60,7 +95,7 @@ func (s AccountStore) SaveSession(w http.ResponseWriter, r *http.Request, id str
 		HttpOnly: true,
 	}
 
-	err := session.Save(r, w)
+	err = session.Save(r, w)
 
 	return err
 }
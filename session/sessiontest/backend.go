@@ -0,0 +1,243 @@
+// Package sessiontest checks that session backends keep the contract ServerStore relies on
+package sessiontest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Hamaiz/go-rest-eg/session"
+	"github.com/gorilla/securecookie"
+)
+
+// ttl - shortest expiry every backend keeps, redis counts in seconds
+const ttl = time.Second
+
+// newID - random session id so shared servers aren't clobbered
+func newID() string {
+	return "test-" + hex.EncodeToString(securecookie.GenerateRandomKey(8))
+}
+
+// Backend - runs the backend contract: save, get, revoke and expiry
+func Backend(t *testing.T, b session.Backend) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, b session.Backend, id string)
+	}{
+		{"missing", func(t *testing.T, b session.Backend, id string) {
+			if _, err := b.Load(id); err != session.ErrNoSession {
+				t.Errorf("Load of a missing session: %v, want ErrNoSession", err)
+			}
+		}},
+		{"save and get", func(t *testing.T, b session.Backend, id string) {
+			save(t, b, id, []byte("one"), time.Minute)
+			load(t, b, id, []byte("one"))
+		}},
+		{"overwrite", func(t *testing.T, b session.Backend, id string) {
+			save(t, b, id, []byte("one"), time.Minute)
+			save(t, b, id, []byte("two"), time.Minute)
+			load(t, b, id, []byte("two"))
+		}},
+		{"binary data", func(t *testing.T, b session.Backend, id string) {
+			data := []byte{0, 1, 2, 0xff, '\n', 0}
+			save(t, b, id, data, time.Minute)
+			load(t, b, id, data)
+		}},
+		{"revoke", func(t *testing.T, b session.Backend, id string) {
+			save(t, b, id, []byte("one"), time.Minute)
+			if err := b.Delete(id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := b.Load(id); err != session.ErrNoSession {
+				t.Errorf("Load after Delete: %v, want ErrNoSession", err)
+			}
+		}},
+		{"revoke missing", func(t *testing.T, b session.Backend, id string) {
+			if err := b.Delete(id); err != nil {
+				t.Errorf("Delete of a missing session: %v", err)
+			}
+		}},
+		{"other sessions", func(t *testing.T, b session.Backend, id string) {
+			other := newID()
+			defer b.Delete(other)
+
+			save(t, b, id, []byte("one"), time.Minute)
+			save(t, b, other, []byte("two"), time.Minute)
+			if err := b.Delete(other); err != nil {
+				t.Fatal(err)
+			}
+			load(t, b, id, []byte("one"))
+		}},
+		{"expiry", func(t *testing.T, b session.Backend, id string) {
+			save(t, b, id, []byte("one"), ttl)
+			load(t, b, id, []byte("one"))
+
+			time.Sleep(ttl + 500*time.Millisecond)
+			if _, err := b.Load(id); err != session.ErrNoSession {
+				t.Errorf("Load after expiry: %v, want ErrNoSession", err)
+			}
+		}},
+		{"store", store},
+	}
+
+	// SESSION_KEY can't be set once the subtests run in parallel
+	t.Run("login", func(t *testing.T) { login(t, b) })
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			id := newID()
+			defer b.Delete(id)
+
+			tt.run(t, b, id)
+		})
+	}
+}
+
+// store - values survive a round trip through ServerStore and are gone after logout
+func store(t *testing.T, b session.Backend, _ string) {
+	s := session.NewServerStore(b, 3600, []byte("0123456789abcdef0123456789abcdef"))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := s.New(r, "session")
+	if err != nil || !sess.IsNew {
+		t.Fatalf("New = %v, %v", sess.IsNew, err)
+	}
+	sess.Values["user"] = "u1"
+
+	w := httptest.NewRecorder()
+	if err := s.Save(r, w, sess); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Delete(sess.ID)
+
+	r = httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	loaded, err := s.New(r, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IsNew || loaded.ID != sess.ID || loaded.Values["user"] != "u1" {
+		t.Fatalf("loaded session %q new %v values %v", loaded.ID, loaded.IsNew, loaded.Values)
+	}
+
+	loaded.Options.MaxAge = -1
+	if err := s.Save(r, httptest.NewRecorder(), loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Load(sess.ID); err != session.ErrNoSession {
+		t.Errorf("Load after logout: %v, want ErrNoSession", err)
+	}
+
+	again, err := s.New(r, "session")
+	if err != nil || !again.IsNew || again.Values["user"] != nil {
+		t.Errorf("session after logout: new %v values %v, %v", again.IsNew, again.Values, err)
+	}
+}
+
+// registry - session.Registry that keeps the sessions in a map
+type registry map[string]string
+
+func (g registry) CreateSession(user string, ip string, ua string) (string, error) {
+	sid := newID()
+	g[sid] = user
+	return sid, nil
+}
+
+func (g registry) ActiveSession(sid string) (string, error) {
+	user, ok := g[sid]
+	if !ok {
+		return "", errors.New("session expired")
+	}
+	return user, nil
+}
+
+func (g registry) RevokeSession(sid string) error {
+	delete(g, sid)
+	return nil
+}
+
+// login - a session id planted before logging in never becomes logged in
+func login(t *testing.T, b session.Backend) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	t.Setenv("SESSION_KEY", string(key))
+
+	s := session.NewServerStore(b, 3600, key)
+
+	// the attacker gets a valid cookie and plants it in the victim's browser
+	r := httptest.NewRequest("GET", "/", nil)
+	planted, _ := s.New(r, "presence")
+	w := httptest.NewRecorder()
+	if err := s.Save(r, w, planted); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Delete(planted.ID)
+	cookies := w.Result().Cookies()
+
+	as, err := session.StoreConn(s, registry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = httptest.NewRequest("POST", "/account/login", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	if err := as.SaveSession(w, r, "u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the victim got a new id
+	after := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		after.AddCookie(c)
+	}
+	loaded, err := s.New(after, "presence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Delete(loaded.ID)
+	if loaded.ID == planted.ID {
+		t.Fatal("login kept the session id of the cookie")
+	}
+	if u, err := as.GetUser(after); err != nil || u != "u1" {
+		t.Errorf("GetUser after login = %q, %v", u, err)
+	}
+
+	// the planted cookie stays logged out
+	before := httptest.NewRequest("GET", "/", nil)
+	for _, c := range cookies {
+		before.AddCookie(c)
+	}
+	if u, err := as.GetUser(before); err == nil {
+		t.Errorf("planted session is logged in as %q", u)
+	}
+}
+
+func save(t *testing.T, b session.Backend, id string, data []byte, ttl time.Duration) {
+	t.Helper()
+	if err := b.Save(id, data, ttl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func load(t *testing.T, b session.Backend, id string, want []byte) {
+	t.Helper()
+	got, err := b.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Load = %q, want %q", got, want)
+	}
+}
@@ -1,15 +1,36 @@
 package session
 
 import (
+	"errors"
 	"log"
 	"os"
 
 	"github.com/globalsign/mgo"
+	"github.com/gorilla/sessions"
 	"github.com/kidstuff/mongostore"
 )
 
+// session store backends for SESSION_STORE
+const (
+	BackendMongo    = "mongo"
+	BackendPostgres = "postgres"
+	BackendRedis    = "redis"
+	BackendMemory   = "memory"
+)
+
+// sessionMaxAge - default max age of the cookie, SaveSession sets its own
+const sessionMaxAge = 3600
+
+// Registry - server side record of sessions so they can be revoked
+type Registry interface {
+	CreateSession(user string, ip string, ua string) (string, error)
+	ActiveSession(sid string) (string, error)
+	RevokeSession(sid string) error
+}
+
 type AccountStore struct {
-	store *mongostore.MongoStore
+	store    sessions.Store
+	sessions Registry
 }
 
 // DBConn - connects to mongodb
@@ -25,14 +46,29 @@ func DBConn() (*mgo.Session, error) {
 	return dbsess, nil
 }
 
-// StoreConn - connects store
-func StoreConn(dbsess *mgo.Session) *AccountStore {
-	log.Println("setting up session store...")
+// sessionKey - SESSION_KEY signs the session cookie
+func sessionKey() []byte {
+	return []byte(os.Getenv("SESSION_KEY"))
+}
 
+// NewMongoStore - cookie sessions in the mongodb sessions collection
+func NewMongoStore(dbsess *mgo.Session) sessions.Store {
 	conn := dbsess.DB("").C("sessions")
-	url := []byte(os.Getenv("SESSION_KEY"))
+	return mongostore.NewMongoStore(conn, sessionMaxAge, true, sessionKey())
+}
+
+// NewBackendStore - cookie sessions kept by the backend
+func NewBackendStore(b Backend) sessions.Store {
+	return NewServerStore(b, sessionMaxAge, sessionKey())
+}
 
-	store := mongostore.NewMongoStore(conn, 3600, true, url)
+// StoreConn - connects store
+func StoreConn(store sessions.Store, reg Registry) (*AccountStore, error) {
+	log.Println("setting up session store...")
+
+	if len(sessionKey()) == 0 {
+		return nil, errors.New("SESSION_KEY is empty")
+	}
 
-	return &AccountStore{store}
+	return &AccountStore{store, reg}, nil
 }
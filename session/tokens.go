@@ -0,0 +1,98 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Hamaiz/go-rest-eg/helper"
+	"github.com/Hamaiz/go-rest-eg/model"
+)
+
+// Store - functions every session backend has
+type Store interface {
+	GetUser(r *http.Request) (string, error)
+	AlreadyLoggedIn(r *http.Request) bool
+	CleanSession(w http.ResponseWriter, r *http.Request) error
+	SaveSession(w http.ResponseWriter, r *http.Request, id string) error
+	SessionID(r *http.Request) (string, error)
+}
+
+// TokenLookup - resolves hashed personal access tokens
+type TokenLookup interface {
+	LookupToken(hash string) (string, string, error)
+}
+
+// TokenStore - accepts personal access tokens and falls back to the session store
+type TokenStore struct {
+	Store
+	tokens TokenLookup
+}
+
+// NewTokenStore - wraps store with personal access tokens
+func NewTokenStore(s Store, t TokenLookup) *TokenStore {
+	return &TokenStore{s, t}
+}
+
+// accessToken - personal access token of the request if there is one
+func accessToken(r *http.Request) string {
+	t := helper.BearerToken(r)
+	if !strings.HasPrefix(t, model.TokenPrefix) {
+		return ""
+	}
+
+	return t
+}
+
+// GetUser - user of the access token, otherwise user of the session
+func (s *TokenStore) GetUser(r *http.Request) (string, error) {
+	t := accessToken(r)
+	if t == "" {
+		return s.Store.GetUser(r)
+	}
+
+	id, scope, err := s.tokens.LookupToken(helper.HashToken(t))
+	if err != nil {
+		return "", err
+	}
+
+	// read tokens can only be used on safe methods
+	if scope != model.ScopeWrite && !safeMethod(r.Method) {
+		return "", errors.New("access token is read only")
+	}
+
+	return id, nil
+}
+
+// AlreadyLoggedIn - tells if the access token or session is valid
+func (s *TokenStore) AlreadyLoggedIn(r *http.Request) bool {
+	if accessToken(r) == "" {
+		return s.Store.AlreadyLoggedIn(r)
+	}
+
+	_, err := s.GetUser(r)
+	return err == nil
+}
+
+// SessionID - access tokens don't belong to a session
+func (s *TokenStore) SessionID(r *http.Request) (string, error) {
+	if accessToken(r) != "" {
+		return "", errors.New("access tokens have no session")
+	}
+
+	return s.Store.SessionID(r)
+}
+
+// CleanSession - access tokens are revoked, not logged out
+func (s *TokenStore) CleanSession(w http.ResponseWriter, r *http.Request) error {
+	if accessToken(r) != "" {
+		return errors.New("access tokens can't be logged out, revoke them instead")
+	}
+
+	return s.Store.CleanSession(w, r)
+}
+
+// safeMethod - methods that don't change anything
+func safeMethod(m string) bool {
+	return m == "GET" || m == "HEAD" || m == "OPTIONS"
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Local - objects in a folder, split into subfolders by the first two characters of the key
+type Local struct {
+	dir    string
+	signer *Signer
+}
+
+// NewLocal - returns Local, creating the folder
+func NewLocal(dir string, signer *Signer) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Local{dir, signer}, nil
+}
+
+// path - file of the key
+func (l *Local) path(key string) string {
+	return filepath.Join(l.dir, key[:2], key)
+}
+
+// Put - writes the object, readers never see a partial file
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	dir := filepath.Dir(l.path(key))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, ".put-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), l.path(key))
+}
+
+// localObject - open file of an object
+type localObject struct {
+	*os.File
+	info Info
+}
+
+func (o *localObject) Info() Info {
+	return o.info
+}
+
+// Open - opens the object, ErrNotFound if there is none
+// files don't keep a content type, it's sniffed the way uploads are checked
+func (l *Local) Open(ctx context.Context, key string) (Object, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	f, err := os.Open(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &localObject{f, Info{Size: st.Size(), ContentType: http.DetectContentType(head[:n]), ModTime: st.ModTime()}}, nil
+}
+
+// Delete - removes the object, deleting a missing one is not an error
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	err := os.Remove(l.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// SignedURL - /media url of the object
+func (l *Local) SignedURL(key string, ttl time.Duration) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	return l.signer.URL(key, ttl), nil
+}
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// payload hash of requests that don't sign their body
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config - bucket of an s3 compatible service such as minio
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3 - objects in an s3 bucket, addressed path style so minio works without dns
+type S3 struct {
+	endpoint *url.URL
+	config   S3Config
+	client   *http.Client
+}
+
+// NewS3 - returns S3, the region defaults to us-east-1
+func NewS3(c S3Config) (*S3, error) {
+	if c.Endpoint == "" || c.Bucket == "" || c.AccessKey == "" || c.SecretKey == "" {
+		return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are needed")
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("S3_ENDPOINT must be a url like http://localhost:9000")
+	}
+
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+
+	return &S3{u, c, &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+// path - path of the key in the bucket
+func (s *S3) path(key string) string {
+	return s.endpoint.Path + "/" + awsEscape(s.config.Bucket, false) + "/" + awsEscape(key, false)
+}
+
+// request - signed request for the key
+func (s *S3) request(ctx context.Context, method string, key string, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	u.Path = s.path(key)
+	u.RawPath = u.Path
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	req.Header.Set("x-amz-date", now.Format("20060102T150405Z"))
+	req.Header.Set("x-amz-content-sha256", unsignedPayload)
+
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": unsignedPayload,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+
+	names, signature := s.sign(method, u.Path, url.Values{}, headers, now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, s.scope(now), names, signature))
+
+	return req, nil
+}
+
+// do - sends the request, statuses other than 2xx become errors
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case res.StatusCode == 404:
+		res.Body.Close()
+		return nil, ErrNotFound
+	case res.StatusCode >= 300:
+		b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		res.Body.Close()
+		return nil, fmt.Errorf("s3 %s: %s %s", req.Method, res.Status, b)
+	}
+
+	return res, nil
+}
+
+// Put - uploads the object
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	req, err := s.request(ctx, "PUT", key, r)
+	if err != nil {
+		return err
+	}
+
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Open - reads the size of the object, its bytes are fetched with ranged GETs as they are read
+func (s *S3) Open(ctx context.Context, key string) (Object, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	req, err := s.request(ctx, "HEAD", key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	info := Info{Size: res.ContentLength, ContentType: res.Header.Get("Content-Type")}
+	info.ModTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+
+	return &s3Object{s: s, ctx: ctx, key: key, info: info}, nil
+}
+
+// Delete - removes the object, deleting a missing one is not an error
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	req, err := s.request(ctx, "DELETE", key, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(req)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// SignedURL - presigned GET straight from the bucket, s3 allows at most 7 days
+func (s *S3) SignedURL(key string, ttl time.Duration) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	if ttl > 7*24*time.Hour {
+		ttl = 7 * 24 * time.Hour
+	}
+
+	u := *s.endpoint
+	u.Path = s.path(key)
+	u.RawPath = u.Path
+	u.RawQuery = s.presign("GET", u.Host, u.Path, time.Now().UTC(), ttl).Encode()
+
+	return u.String(), nil
+}
+
+// presign - query of a presigned request
+func (s *S3) presign(method string, host string, path string, now time.Time, ttl time.Duration) url.Values {
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.config.AccessKey+"/"+s.scope(now))
+	q.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	_, signature := s.sign(method, path, q, map[string]string{"host": host}, now)
+	q.Set("X-Amz-Signature", signature)
+
+	return q
+}
+
+// scope - credential scope of the day
+func (s *S3) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.config.Region + "/s3/aws4_request"
+}
+
+// sign - signature version 4 of the request, returns the signed header names and the signature
+func (s *S3) sign(method string, path string, q url.Values, headers map[string]string, now time.Time) (string, string) {
+	names := make([]string, 0, len(headers))
+	for n := range headers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var ch strings.Builder
+	for _, n := range names {
+		ch.WriteString(n + ":" + strings.TrimSpace(headers[n]) + "\n")
+	}
+
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	query := make([]string, 0, len(keys))
+	for _, k := range keys {
+		query = append(query, awsEscape(k, true)+"="+awsEscape(q.Get(k), true))
+	}
+
+	payload := unsignedPayload
+	if h, ok := headers["x-amz-content-sha256"]; ok {
+		payload = h
+	}
+
+	canonical := strings.Join([]string{
+		method,
+		path,
+		strings.Join(query, "&"),
+		ch.String(),
+		strings.Join(names, ";"),
+		payload,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + s.scope(now) + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return strings.Join(names, ";"), hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(s))
+
+	return m.Sum(nil)
+}
+
+// awsEscape - uri encoding of signature version 4, slashes are kept in paths
+func awsEscape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// s3Object - object read with ranged GETs, seeking drops the open response
+type s3Object struct {
+	s      *S3
+	ctx    context.Context
+	key    string
+	info   Info
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Info() Info {
+	return o.info
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.info.Size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		req, err := o.s.request(o.ctx, "GET", o.key, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+
+		res, err := o.s.do(req)
+		if err != nil {
+			return 0, err
+		}
+		o.body = res.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.info.Size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seek before the start of the object")
+	}
+
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+
+	return o.body.Close()
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// errors of every backend
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+	ErrTooLarge   = errors.New("object is too large")
+)
+
+// keys are the sha256 of the content
+var keyPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// ValidKey - tells if the key is a content address
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Info - what is known about a stored object, ContentType can be empty
+type Info struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Object - stored bytes that can be read from any offset
+type Object interface {
+	io.ReadSeeker
+	io.Closer
+	Info() Info
+}
+
+// Storage - where uploaded bytes are kept
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// Upload - content spooled to a temporary file, its key is known before it's stored
+type Upload struct {
+	Key  string
+	Size int64
+	f    *os.File
+}
+
+// Spool - copies at most max bytes to a temporary file, close the upload when done
+func Spool(r io.Reader, max int64) (*Upload, error) {
+	f, err := ioutil.TempFile("", "upload-")
+	if err != nil {
+		return nil, err
+	}
+
+	u := &Upload{f: f}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, max+1))
+	if err == nil && n > max {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		u.Close()
+		return nil, err
+	}
+
+	u.Key = hex.EncodeToString(h.Sum(nil))
+	u.Size = n
+
+	return u, nil
+}
+
+// Put - saves the spooled content in the storage under its key
+func (u *Upload) Put(ctx context.Context, s Storage, contentType string) error {
+	if _, err := u.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return s.Put(ctx, u.Key, u.f, u.Size, contentType)
+}
+
+// Close - removes the temporary file
+func (u *Upload) Close() error {
+	err := u.f.Close()
+	os.Remove(u.f.Name())
+
+	return err
+}
+
+// Store - saves the content under its sha256 key, at most max bytes
+// the content is spooled to a temporary file as the key has to be known first
+func Store(ctx context.Context, s Storage, r io.Reader, contentType string, max int64) (string, int64, error) {
+	u, err := Spool(r, max)
+	if err != nil {
+		return "", 0, err
+	}
+	defer u.Close()
+
+	return u.Key, u.Size, u.Put(ctx, s, contentType)
+}
+
+// Load - storage chosen with STORAGE, local (default) or s3
+func Load(signer *Signer) (Storage, error) {
+	// a nil *Local or *S3 must not end up in the interface
+	switch os.Getenv("STORAGE") {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "uploads/media"
+		}
+
+		l, err := NewLocal(dir, signer)
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
+	case "s3":
+		s, err := NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	return nil, errors.New("STORAGE must be local or s3")
+}
+
+// Signer - signs /media urls so they only work until they expire
+type Signer struct {
+	key  []byte
+	base string
+}
+
+// NewSigner - returns Signer, base is the url of the api ending with a slash
+func NewSigner(key []byte, base string) *Signer {
+	return &Signer{key, base}
+}
+
+// signature - signature of the key until the unix time
+func (s *Signer) signature(key string, expires int64) string {
+	m := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(m, "media:%s:%d", key, expires)
+
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// URL - /media url of the key that works for ttl
+// expiry is rounded up to the hour so the same url is handed out, and cached, for a while
+func (s *Signer) URL(key string, ttl time.Duration) string {
+	exp := time.Now().Add(ttl).Truncate(time.Hour).Add(time.Hour).Unix()
+
+	return fmt.Sprintf("%smedia/%s?expires=%d&signature=%s", s.base, key, exp, s.signature(key, exp))
+}
+
+// Verify - time the url of the key expires at, false if it's forged or expired
+func (s *Signer) Verify(key string, expires string, signature string) (time.Time, bool) {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, exp))) {
+		return time.Time{}, false
+	}
+
+	t := time.Unix(exp, 0)
+
+	return t, time.Now().Before(t)
+}
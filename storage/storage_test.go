@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// png - magic bytes of a png, enough for the type to be sniffed
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// testStorage - the contract every backend keeps
+func testStorage(t *testing.T, st Storage) {
+	ctx := context.Background()
+
+	key, n, err := Store(ctx, st, bytes.NewReader(png), "image/png", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Delete(ctx, key) })
+
+	if !ValidKey(key) || n != int64(len(png)) {
+		t.Fatalf("Store = %q, %d", key, n)
+	}
+
+	o, err := st.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := o.Info()
+	if info.Size != int64(len(png)) || info.ContentType != "image/png" || info.ModTime.IsZero() {
+		t.Errorf("info %+v", info)
+	}
+
+	b, err := ioutil.ReadAll(o)
+	if err != nil || !bytes.Equal(b, png) {
+		t.Errorf("read %q, %v", b, err)
+	}
+
+	// ranges are read by seeking
+	if _, err := o.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b = make([]byte, 4)
+	if _, err := io.ReadFull(o, b); err != nil || string(b) != "\r\n\x1a\n" {
+		t.Errorf("read at 4 %q, %v", b, err)
+	}
+	o.Close()
+
+	if _, err := st.SignedURL(key, time.Hour); err != nil {
+		t.Error(err)
+	}
+
+	if err := st.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Open(ctx, key); err != ErrNotFound {
+		t.Errorf("deleted object opened: %v", err)
+	}
+	if err := st.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing object: %v", err)
+	}
+
+	for _, bad := range []string{"", "../etc/passwd", strings.Repeat("A", 64)} {
+		if _, err := st.Open(ctx, bad); err != ErrInvalidKey {
+			t.Errorf("Open(%q) = %v", bad, err)
+		}
+		if err := st.Put(ctx, bad, bytes.NewReader(png), int64(len(png)), ""); err != ErrInvalidKey {
+			t.Errorf("Put(%q) = %v", bad, err)
+		}
+	}
+
+	if _, _, err := Store(ctx, st, bytes.NewReader(png), "image/png", 4); err != ErrTooLarge {
+		t.Errorf("Store over the limit = %v", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	l, err := NewLocal(t.TempDir(), NewSigner([]byte("key"), "http://api.test/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStorage(t, l)
+
+	// the content type is sniffed, nothing else is kept with the file
+	ctx := context.Background()
+	key, _, err := Store(ctx, l, strings.NewReader("hello"), "text/plain; charset=utf-8", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := l.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	if ct := o.Info().ContentType; ct != "text/plain; charset=utf-8" {
+		t.Errorf("content type %q", ct)
+	}
+	if b, _ := ioutil.ReadAll(o); string(b) != "hello" {
+		t.Errorf("read %q after sniffing", b)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	s := NewSigner([]byte("key"), "http://api.test/")
+	key := strings.Repeat("a", 64)
+
+	u := s.URL(key, time.Hour)
+	if !strings.HasPrefix(u, "http://api.test/media/"+key+"?expires=") {
+		t.Fatalf("url %s", u)
+	}
+
+	q := u[strings.Index(u, "?")+1:]
+	var exp, sig string
+	for _, p := range strings.Split(q, "&") {
+		kv := strings.SplitN(p, "=", 2)
+		switch kv[0] {
+		case "expires":
+			exp = kv[1]
+		case "signature":
+			sig = kv[1]
+		}
+	}
+
+	at, ok := s.Verify(key, exp, sig)
+	if !ok || at.Before(time.Now().Add(time.Hour)) {
+		t.Errorf("Verify = %v, %v", at, ok)
+	}
+
+	if _, ok := s.Verify(strings.Repeat("b", 64), exp, sig); ok {
+		t.Error("signature of another key verified")
+	}
+	if _, ok := NewSigner([]byte("other"), "http://api.test/").Verify(key, exp, sig); ok {
+		t.Error("signature of another signer verified")
+	}
+	if _, ok := s.Verify(key, "1", s.signature(key, 1)); ok {
+		t.Error("expired url verified")
+	}
+	if _, ok := s.Verify(key, "soon", sig); ok {
+		t.Error("bad expiry verified")
+	}
+}